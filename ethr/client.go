@@ -0,0 +1,87 @@
+// Package ethr provides tools for resolving the did:ethr method format, for
+// dids managed by the ERC-1056 ethereum identity registry:
+// https://github.com/decentralized-identity/ethr-did-resolver/blob/master/doc/did-method-spec.md
+// Copyright 2021 Textile
+// Copyright 2018 ConsenSys AG
+package ethr
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/textileio/go-did-resolver/internal/httpclient"
+)
+
+// DefaultTimeout is the time limit of JSON-RPC calls of an HTTPClient without
+// its own http client.
+const DefaultTimeout = httpclient.DefaultTimeout
+
+// Client is a basic client interface for interacting with an ethereum node.
+type Client interface {
+	// Call performs a JSON-RPC call of method with params, and decodes the
+	// result into result.
+	Call(method string, params []interface{}, result interface{}) error
+}
+
+// rpcRequest is a JSON-RPC 2.0 request.
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      uint64        `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+// rpcResponse is a JSON-RPC 2.0 response.
+type rpcResponse struct {
+	ID     uint64          `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is a JSON-RPC 2.0 error object.
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// HTTPClient interfaces with an ethereum node via its JSON-RPC http endpoint.
+type HTTPClient struct {
+	URL string
+	// HTTP makes the JSON-RPC calls, if set.
+	HTTP *http.Client
+	id   uint64
+}
+
+// Call performs a JSON-RPC call over http.
+func (client *HTTPClient) Call(method string, params []interface{}, result interface{}) error {
+	body, err := json.Marshal(rpcRequest{
+		JSONRPC: "2.0",
+		ID:      atomic.AddUint64(&client.id, 1),
+		Method:  method,
+		Params:  params,
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := httpclient.Or(client.HTTP).Post(client.URL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to call '%s': '%s'", method, resp.Status)
+	}
+	var response rpcResponse
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return err
+	}
+	if response.Error != nil {
+		return fmt.Errorf("json-rpc error %d: %s", response.Error.Code, response.Error.Message)
+	}
+	return json.Unmarshal(response.Result, result)
+}
+
+var _ Client = (*HTTPClient)(nil)
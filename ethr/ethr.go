@@ -0,0 +1,328 @@
+// Package ethr provides tools for resolving the did:ethr method format, for
+// dids managed by the ERC-1056 ethereum identity registry:
+// https://github.com/decentralized-identity/ethr-did-resolver/blob/master/doc/did-method-spec.md
+// Copyright 2021 Textile
+// Copyright 2018 ConsenSys AG
+package ethr
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	mbase "github.com/multiformats/go-multibase"
+	codec "github.com/multiformats/go-multicodec"
+	"github.com/ockam-network/did"
+	"github.com/textileio/go-did-resolver/multikey"
+	"github.com/textileio/go-did-resolver/resolver"
+	"golang.org/x/crypto/sha3"
+)
+
+// DefaultRegistry is the address of the ERC-1056 registry deployed on
+// mainnet and most test networks.
+const DefaultRegistry = "0xdca7ef03e98e0dc2b855be647c39abe984fcf21b"
+
+// nullAddress is the owner of a deactivated identity.
+const nullAddress = "0x0000000000000000000000000000000000000000"
+
+// Network is an ethereum network with an ERC-1056 registry.
+type Network struct {
+	// Name is the network name used in dids, such as "goerli". The network
+	// may always be referred to by its hex encoded ChainID, such as "0x5".
+	// The "mainnet" network is used for dids without a network name.
+	Name     string
+	ChainID  uint64
+	Registry string
+	// Client is required to resolve dids on the network.
+	Client Client
+}
+
+// Resolver resolves did:ethr identifiers on a set of networks.
+type Resolver struct {
+	networks map[string]Network
+}
+
+// New creates and returns a new ethr Resolver for the given networks.
+func New(networks ...Network) *Resolver {
+	r := &Resolver{
+		networks: make(map[string]Network),
+	}
+	for _, network := range networks {
+		if network.Registry == "" {
+			network.Registry = DefaultRegistry
+		}
+		if network.Name != "" {
+			r.networks[network.Name] = network
+		}
+		r.networks[fmt.Sprintf("0x%x", network.ChainID)] = network
+	}
+	return r
+}
+
+// Method returns the method that this resolver is capable of resolving.
+func (r *Resolver) Method() string {
+	return "ethr"
+}
+
+// Resolve is the primary resolution method for this resolver.
+// A versionId query parameter resolves the document as of that block number.
+func (r *Resolver) Resolve(did string, parsed *did.DID, res resolver.Resolver) (*resolver.Document, error) {
+	if parsed.Method != r.Method() {
+		return nil, fmt.Errorf("unknown did method: '%s'", parsed.Method)
+	}
+	name, identifier := "mainnet", parsed.ID
+	if i := strings.LastIndex(parsed.ID, ":"); i >= 0 {
+		name, identifier = parsed.ID[:i], parsed.ID[i+1:]
+	}
+	network, ok := r.networks[name]
+	if !ok && name == "mainnet" {
+		network, ok = r.networks["0x1"]
+	}
+	if !ok {
+		return nil, resolver.NewError(resolver.NotFound, "unknown network: '%s'", name)
+	}
+	if network.Client == nil {
+		return nil, resolver.NewError(resolver.InternalError, "no client for network: '%s'", name)
+	}
+	address, key, err := parseIdentifier(identifier)
+	if err != nil {
+		return nil, err
+	}
+	query, err := url.ParseQuery(parsed.Query)
+	if err != nil {
+		return nil, err
+	}
+	var version uint64
+	if v := query.Get("versionId"); v != "" {
+		version, err = strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid versionId: '%s'", v)
+		}
+	}
+	events, err := history(network.Client, network.Registry, address)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().Unix()
+	if version != 0 {
+		now, err = blockTimestamp(network.Client, version)
+		if err != nil {
+			return nil, err
+		}
+		for i, event := range events {
+			if event.BlockNumber > version {
+				events = events[:i]
+				break
+			}
+		}
+	}
+	return buildDocument(fmt.Sprintf("did:ethr:%s", parsed.ID), network, address, key, events, now)
+}
+
+// parseIdentifier returns the address of an ethereum address or compressed
+// secp256k1 public key identifier.
+func parseIdentifier(identifier string) (string, *multikey.Key, error) {
+	bytes, err := hex.DecodeString(strings.TrimPrefix(identifier, "0x"))
+	if err != nil || !strings.HasPrefix(identifier, "0x") {
+		return "", nil, fmt.Errorf("invalid identifier: '%s'", identifier)
+	}
+	switch len(bytes) {
+	case 20:
+		return strings.ToLower(identifier), nil, nil
+	case 33:
+		key := &multikey.Key{Code: codec.Secp256k1Pub, Bytes: bytes}
+		x, y, err := key.Point()
+		if err != nil {
+			return "", nil, err
+		}
+		// The address is the last 20 bytes of the hash of the uncompressed key.
+		hash := sha3.NewLegacyKeccak256()
+		hash.Write(x.FillBytes(make([]byte, 32)))
+		hash.Write(y.FillBytes(make([]byte, 32)))
+		return "0x" + hex.EncodeToString(hash.Sum(nil)[12:]), key, nil
+	default:
+		return "", nil, fmt.Errorf("invalid identifier: '%s'", identifier)
+	}
+}
+
+// attributeTypes maps the algorithm of a did/pub attribute to its
+// verification method type.
+var attributeTypes = map[string]string{
+	"Secp256k1": "EcdsaSecp256k1VerificationKey2019",
+	"Ed25519":   "Ed25519VerificationKey2018",
+	"X25519":    "X25519KeyAgreementKey2019",
+}
+
+// entry is the verification method or service added by a delegate or
+// attribute event, and the relationship it is used for.
+type entry struct {
+	vm           resolver.VerificationMethod
+	service      *resolver.ServiceEndpoint
+	relationship string
+}
+
+// buildDocument applies registry events in order to build the document.
+func buildDocument(id string, network Network, address string, key *multikey.Key, events []Event, now int64) (*resolver.Document, error) {
+	owner := address
+	accountID := func(address string) string {
+		return fmt.Sprintf("eip155:%d:%s", network.ChainID, address)
+	}
+	// Delegates and attributes are keyed by their content, so that later
+	// events can revoke them.
+	var order []string
+	entries := make(map[string]*entry)
+	seen := make(map[string]bool)
+	count := 0
+	for _, event := range events {
+		if event.Kind == "DIDOwnerChanged" {
+			owner = event.Owner
+			continue
+		}
+		var k string
+		if event.Kind == "DIDDelegateChanged" {
+			k = event.DelegateType + event.Delegate
+		} else {
+			k = event.Name + string(event.Value)
+		}
+		count++
+		if event.ValidTo.Cmp(big.NewInt(now)) <= 0 {
+			delete(entries, k)
+			continue
+		}
+		e, err := newEntry(id, count, event, accountID)
+		if err != nil {
+			return nil, err
+		}
+		if e == nil {
+			continue
+		}
+		if !seen[k] {
+			seen[k] = true
+			order = append(order, k)
+		}
+		entries[k] = e
+	}
+
+	doc := &resolver.Document{
+		Context: []string{
+			"https://www.w3.org/ns/did/v1",
+			"https://w3id.org/security/suites/secp256k1recovery-2020/v2",
+		},
+		ID: id,
+	}
+	if owner == nullAddress {
+		// Deactivated
+		return doc, nil
+	}
+	controllerID := id + "#controller"
+	doc.VerificationMethod = []resolver.VerificationMethod{
+		{
+			ID:                  controllerID,
+			Type:                "EcdsaSecp256k1RecoveryMethod2020",
+			Controller:          id,
+			BlockchainAccountID: accountID(owner),
+		},
+	}
+	doc.Authentication = []resolver.VerificationMethod{resolver.Reference(controllerID)}
+	doc.AssertionMethod = []resolver.VerificationMethod{resolver.Reference(controllerID)}
+	if key != nil && owner == address {
+		keyID := id + "#controllerKey"
+		encoded, err := mbase.Encode(mbase.Base58BTC, key.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		doc.VerificationMethod = append(doc.VerificationMethod, resolver.VerificationMethod{
+			ID:              keyID,
+			Type:            "EcdsaSecp256k1VerificationKey2019",
+			Controller:      id,
+			PublicKeyBase58: encoded[1:],
+		})
+		doc.Authentication = append(doc.Authentication, resolver.Reference(keyID))
+		doc.AssertionMethod = append(doc.AssertionMethod, resolver.Reference(keyID))
+	}
+	for _, k := range order {
+		e, ok := entries[k]
+		if !ok {
+			continue
+		}
+		if e.service != nil {
+			doc.Service = append(doc.Service, *e.service)
+			continue
+		}
+		ref := resolver.Reference(e.vm.ID)
+		doc.VerificationMethod = append(doc.VerificationMethod, e.vm)
+		switch e.relationship {
+		case "sigAuth":
+			doc.Authentication = append(doc.Authentication, ref)
+			doc.AssertionMethod = append(doc.AssertionMethod, ref)
+		case "veriKey":
+			doc.AssertionMethod = append(doc.AssertionMethod, ref)
+		case "enc":
+			doc.KeyAgreement = append(doc.KeyAgreement, ref)
+		}
+	}
+	return doc, nil
+}
+
+// newEntry creates the verification method or service for a delegate or
+// attribute event. Unknown attributes are ignored.
+func newEntry(id string, count int, event Event, accountID func(string) string) (*entry, error) {
+	e := &entry{}
+	if event.Kind == "DIDDelegateChanged" {
+		if event.DelegateType != "veriKey" && event.DelegateType != "sigAuth" {
+			return nil, nil
+		}
+		e.relationship = event.DelegateType
+		e.vm = resolver.VerificationMethod{
+			ID:                  fmt.Sprintf("%s#delegate-%d", id, count),
+			Type:                "EcdsaSecp256k1RecoveryMethod2020",
+			Controller:          id,
+			BlockchainAccountID: accountID(event.Delegate),
+		}
+		return e, nil
+	}
+	parts := strings.Split(event.Name, "/")
+	if len(parts) < 3 || parts[0] != "did" {
+		return nil, nil
+	}
+	switch parts[1] {
+	case "pub":
+		// did/pub/<algorithm>/<purpose>/<encoding>
+		if len(parts) < 4 {
+			return nil, nil
+		}
+		vmType, ok := attributeTypes[parts[2]]
+		if !ok {
+			return nil, nil
+		}
+		// The value is the raw key, the encoding only hints at how to
+		// present it.
+		encoded, err := mbase.Encode(mbase.Base58BTC, event.Value)
+		if err != nil {
+			return nil, err
+		}
+		e.relationship = parts[3]
+		e.vm = resolver.VerificationMethod{
+			ID:              fmt.Sprintf("%s#delegate-%d", id, count),
+			Type:            vmType,
+			Controller:      id,
+			PublicKeyBase58: encoded[1:],
+		}
+		return e, nil
+	case "svc":
+		// did/svc/<type>
+		e.service = &resolver.ServiceEndpoint{
+			ID:              fmt.Sprintf("%s#service-%d", id, count),
+			Type:            parts[2],
+			ServiceEndpoint: string(event.Value),
+		}
+		return e, nil
+	}
+	return nil, nil
+}
+
+var _ resolver.Resolver = (*Resolver)(nil)
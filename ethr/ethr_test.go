@@ -0,0 +1,438 @@
+// Package ethr provides tools for resolving the did:ethr method format, for
+// dids managed by the ERC-1056 ethereum identity registry:
+// https://github.com/decentralized-identity/ethr-did-resolver/blob/master/doc/did-method-spec.md
+// Copyright 2021 Textile
+// Copyright 2018 ConsenSys AG
+package ethr
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ockam-network/did"
+	"github.com/textileio/go-did-resolver/internal/httpclient"
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+const (
+	identity = "0xb9c5714089478a327f09197987f16f9e5d936e8a"
+	delegate = "0x7e5f4552091a69125d5dfcb7b8c2659029395bdf"
+	never    = 1 << 40
+)
+
+// node is a stand-in for an ethereum node serving a single identity.
+type node struct {
+	// logs are the registry logs by block number.
+	logs map[uint64][]log
+	last uint64
+}
+
+func (n *node) add(number uint64, topic string, words ...[]byte) {
+	var data []byte
+	for _, word := range words {
+		data = append(data, word...)
+	}
+	data = append(data, uintWord(n.last)...)
+	n.logs[number] = append(n.logs[number], log{
+		BlockNumber: fmt.Sprintf("0x%x", number),
+		Topics:      []string{topic, "0x" + padAddress(identity)},
+		Data:        "0x" + hex.EncodeToString(data),
+	})
+	n.last = number
+}
+
+func (n *node) ownerChanged(number uint64, owner string) {
+	n.add(number, ownerChangedTopic, addressWord(owner))
+}
+
+func (n *node) delegateChanged(number uint64, delegateType, delegate string, validTo uint64) {
+	n.add(number, delegateChangedTopic, stringWord(delegateType), addressWord(delegate), uintWord(validTo))
+}
+
+func (n *node) attributeChanged(number uint64, name string, value []byte, validTo uint64) {
+	// The value is dynamic, so it is appended after the previousChange word.
+	padded := make([]byte, (len(value)+31)/32*32)
+	copy(padded, value)
+	var data []byte
+	data = append(data, stringWord(name)...)
+	data = append(data, uintWord(4*32)...)
+	data = append(data, uintWord(validTo)...)
+	data = append(data, uintWord(n.last)...)
+	data = append(data, uintWord(uint64(len(value)))...)
+	data = append(data, padded...)
+	n.logs[number] = append(n.logs[number], log{
+		BlockNumber: fmt.Sprintf("0x%x", number),
+		Topics:      []string{attributeChangedTopic, "0x" + padAddress(identity)},
+		Data:        "0x" + hex.EncodeToString(data),
+	})
+	n.last = number
+}
+
+func (n *node) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID     uint64            `json:"id"`
+		Method string            `json:"method"`
+		Params []json.RawMessage `json:"params"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var result interface{}
+	switch req.Method {
+	case "eth_call":
+		var call map[string]string
+		json.Unmarshal(req.Params[0], &call)
+		last := uint64(0)
+		if strings.HasSuffix(call["data"], padAddress(identity)) {
+			last = n.last
+		}
+		result = "0x" + hex.EncodeToString(uintWord(last))
+	case "eth_getLogs":
+		var filter map[string]interface{}
+		json.Unmarshal(req.Params[0], &filter)
+		number, _ := parseQuantity(filter["fromBlock"].(string))
+		result = n.logs[number.Uint64()]
+	case "eth_getBlockByNumber":
+		var number string
+		json.Unmarshal(req.Params[0], &number)
+		// Blocks are a second apart.
+		result = block{Timestamp: number}
+	default:
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":    req.ID,
+			"error": rpcError{Code: -32601, Message: "method not found"},
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{"id": req.ID, "result": result})
+}
+
+func uintWord(n uint64) []byte {
+	return new(big.Int).SetUint64(n).FillBytes(make([]byte, 32))
+}
+
+func addressWord(address string) []byte {
+	word, _ := hex.DecodeString(padAddress(address))
+	return word
+}
+
+func stringWord(str string) []byte {
+	word := make([]byte, 32)
+	copy(word, str)
+	return word
+}
+
+func resolve(t *testing.T, n *node, id string) (*resolver.Document, error) {
+	server := httptest.NewServer(n)
+	t.Cleanup(server.Close)
+	parsed, err := did.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := &HTTPClient{URL: server.URL}
+	r := New(
+		Network{Name: "mainnet", ChainID: 1, Client: client},
+		Network{Name: "goerli", ChainID: 5, Client: client},
+	)
+	return r.Resolve(id, parsed, r)
+}
+
+func newNode() *node {
+	return &node{logs: make(map[uint64][]log)}
+}
+
+func controller(id string, chainID int, owner string) []resolver.VerificationMethod {
+	return []resolver.VerificationMethod{
+		{
+			ID:                  id + "#controller",
+			Type:                "EcdsaSecp256k1RecoveryMethod2020",
+			Controller:          id,
+			BlockchainAccountID: fmt.Sprintf("eip155:%d:%s", chainID, owner),
+		},
+	}
+}
+
+var context = []string{
+	"https://www.w3.org/ns/did/v1",
+	"https://w3id.org/security/suites/secp256k1recovery-2020/v2",
+}
+
+func TestUnknownMethod(t *testing.T) {
+	_, err := resolve(t, newNode(), "did:borg:"+identity)
+	if err == nil || err.Error() != "unknown did method: 'borg'" {
+		t.Error("expected Resolve to return error")
+	}
+}
+
+func TestUnknownNetwork(t *testing.T) {
+	_, err := resolve(t, newNode(), "did:ethr:rinkeby:"+identity)
+	if err == nil || err.Error() != "unknown network: 'rinkeby'" {
+		t.Error("expected Resolve to return error")
+	}
+	var rerr *resolver.Error
+	if !errors.As(err, &rerr) || rerr.Code != resolver.NotFound {
+		t.Errorf("expected notFound error: %v", err)
+	}
+}
+
+func TestInvalidIdentifier(t *testing.T) {
+	for _, id := range []string{
+		"did:ethr:b9c5714089478a327f09197987f16f9e5d936e8a",
+		"did:ethr:0xb9c5714089478a327f09197987f16f9e5d936e",
+		"did:ethr:0xzz",
+	} {
+		if _, err := resolve(t, newNode(), id); err == nil {
+			t.Errorf("expected Resolve to return error: %s", id)
+		}
+	}
+}
+
+func TestResolveAddress(t *testing.T) {
+	id := "did:ethr:" + identity
+	doc, err := resolve(t, newNode(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &resolver.Document{
+		Context:            context,
+		ID:                 id,
+		VerificationMethod: controller(id, 1, identity),
+		Authentication:     []resolver.VerificationMethod{resolver.Reference(id + "#controller")},
+		AssertionMethod:    []resolver.VerificationMethod{resolver.Reference(id + "#controller")},
+	}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Errorf("unexpected document: %+v", doc)
+	}
+}
+
+func TestResolveChainID(t *testing.T) {
+	n := newNode()
+	for _, id := range []string{"did:ethr:0x5:" + identity, "did:ethr:goerli:" + identity} {
+		doc, err := resolve(t, n, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc.VerificationMethod[0].BlockchainAccountID != "eip155:5:"+identity {
+			t.Errorf("unexpected account id: %s", doc.VerificationMethod[0].BlockchainAccountID)
+		}
+	}
+}
+
+func TestResolvePublicKey(t *testing.T) {
+	// The compressed public key for private key 1, whose address is the
+	// delegate address.
+	key := "0x0279be667ef9dcbbac55a06295ce870b07029bfcdb2dce28d959f2815b16f81798"
+	id := "did:ethr:" + key
+	doc, err := resolve(t, newNode(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vms := controller(id, 1, delegate)
+	vms = append(vms, resolver.VerificationMethod{
+		ID:              id + "#controllerKey",
+		Type:            "EcdsaSecp256k1VerificationKey2019",
+		Controller:      id,
+		PublicKeyBase58: "jesTu2BpszP8DKSoi1R5G6ggjHrsrVnboLdx6V47vkoR",
+	})
+	refs := []resolver.VerificationMethod{
+		resolver.Reference(id + "#controller"),
+		resolver.Reference(id + "#controllerKey"),
+	}
+	expected := &resolver.Document{
+		Context:            context,
+		ID:                 id,
+		VerificationMethod: vms,
+		Authentication:     refs,
+		AssertionMethod:    refs,
+	}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Errorf("unexpected document: %+v", doc)
+	}
+}
+
+func TestResolveHistory(t *testing.T) {
+	n := newNode()
+	n.delegateChanged(10, "sigAuth", delegate, never)
+	n.attributeChanged(11, "did/pub/Ed25519/veriKey/base64", make([]byte, 32), never)
+	n.attributeChanged(11, "did/svc/HubService", []byte("https://hubs.uport.me"), never)
+	n.attributeChanged(12, "did/pub/X25519/enc/base64", []byte{1}, never)
+	// Revoked
+	n.attributeChanged(13, "did/pub/X25519/enc/base64", []byte{1}, 0)
+
+	id := "did:ethr:" + identity
+	doc, err := resolve(t, n, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vms := controller(id, 1, identity)
+	vms = append(vms,
+		resolver.VerificationMethod{
+			ID:                  id + "#delegate-1",
+			Type:                "EcdsaSecp256k1RecoveryMethod2020",
+			Controller:          id,
+			BlockchainAccountID: "eip155:1:" + delegate,
+		},
+		resolver.VerificationMethod{
+			ID:              id + "#delegate-2",
+			Type:            "Ed25519VerificationKey2018",
+			Controller:      id,
+			PublicKeyBase58: "11111111111111111111111111111111",
+		},
+	)
+	expected := &resolver.Document{
+		Context:            context,
+		ID:                 id,
+		VerificationMethod: vms,
+		Authentication: []resolver.VerificationMethod{
+			resolver.Reference(id + "#controller"),
+			resolver.Reference(id + "#delegate-1"),
+		},
+		AssertionMethod: []resolver.VerificationMethod{
+			resolver.Reference(id + "#controller"),
+			resolver.Reference(id + "#delegate-1"),
+			resolver.Reference(id + "#delegate-2"),
+		},
+		Service: []resolver.ServiceEndpoint{
+			{
+				ID:              id + "#service-3",
+				Type:            "HubService",
+				ServiceEndpoint: "https://hubs.uport.me",
+			},
+		},
+	}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Errorf("unexpected document: %+v", doc)
+	}
+
+	// At block 12 the X25519 key has not yet been revoked.
+	doc, err = resolve(t, n, id+"?versionId=12")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.KeyAgreement) != 1 || doc.KeyAgreement[0].ID != id+"#delegate-4" {
+		t.Errorf("unexpected key agreement: %+v", doc.KeyAgreement)
+	}
+}
+
+func TestResolveDeactivated(t *testing.T) {
+	n := newNode()
+	n.ownerChanged(10, delegate)
+	n.ownerChanged(11, nullAddress)
+	id := "did:ethr:" + identity
+	doc, err := resolve(t, n, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &resolver.Document{Context: context, ID: id}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Errorf("unexpected document: %+v", doc)
+	}
+
+	doc, err = resolve(t, n, id+"?versionId=10")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.VerificationMethod[0].BlockchainAccountID != "eip155:1:"+delegate {
+		t.Errorf("unexpected owner: %s", doc.VerificationMethod[0].BlockchainAccountID)
+	}
+}
+
+func TestMissingClient(t *testing.T) {
+	id := "did:ethr:" + identity
+	parsed, err := did.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New(Network{Name: "mainnet", ChainID: 1})
+	_, err = r.Resolve(id, parsed, r)
+	if err == nil || err.Error() != "no client for network: 'mainnet'" {
+		t.Errorf("expected Resolve to return error, got: %v", err)
+	}
+}
+
+func TestDecodeAttributeOverflow(t *testing.T) {
+	attribute := func(offset, length, previous []byte) log {
+		var data []byte
+		data = append(data, stringWord("did/pub/Secp256k1/veriKey/hex")...)
+		data = append(data, offset...)
+		data = append(data, uintWord(never)...)
+		data = append(data, previous...)
+		data = append(data, length...)
+		data = append(data, make([]byte, 32)...)
+		return log{
+			BlockNumber: "0x1",
+			Topics:      []string{attributeChangedTopic, "0x" + padAddress(identity)},
+			Data:        "0x" + hex.EncodeToString(data),
+		}
+	}
+	// high is a word of 2^64 + 4*32, whose low 64 bits are a valid offset.
+	high := uintWord(4 * 32)
+	high[23] = 1
+	tests := []struct {
+		name     string
+		offset   []byte
+		length   []byte
+		previous []byte
+	}{
+		{"offset", uintWord(0xfffffffffffffff0), uintWord(1), uintWord(0)},
+		{"offset past end", uintWord(6 * 32), uintWord(1), uintWord(0)},
+		{"offset high bytes", high, uintWord(32), uintWord(0)},
+		{"length", uintWord(4 * 32), uintWord(0xfffffffffffffff0), uintWord(0)},
+		{"length past end", uintWord(4 * 32), uintWord(33), uintWord(0)},
+		{"length high bytes", uintWord(4 * 32), high, uintWord(0)},
+		{"previous change high bytes", uintWord(4 * 32), uintWord(32), high},
+	}
+	for _, tt := range tests {
+		_, err := decodeLog(attribute(tt.offset, tt.length, tt.previous))
+		if err == nil || err.Error() != "invalid DIDAttributeChanged event" {
+			t.Errorf("%s: expected decodeLog to return error, got: %v", tt.name, err)
+		}
+	}
+	event, err := decodeLog(attribute(uintWord(4*32), uintWord(32), uintWord(0)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(event.Value) != 32 {
+		t.Errorf("unexpected value length: %d", len(event.Value))
+	}
+
+	owner := log{
+		BlockNumber: "0x1",
+		Topics:      []string{ownerChangedTopic, "0x" + padAddress(identity)},
+		Data:        "0x" + padAddress(identity) + hex.EncodeToString(high),
+	}
+	if _, err := decodeLog(owner); err == nil || err.Error() != "invalid DIDOwnerChanged event" {
+		t.Errorf("expected decodeLog to return error, got: %v", err)
+	}
+}
+
+func TestHTTPClientTransport(t *testing.T) {
+	n := newNode()
+	n.ownerChanged(10, delegate)
+	server := httptest.NewServer(n)
+	defer server.Close()
+	transport := &httpclient.CountingTransport{}
+	client := &HTTPClient{URL: server.URL, HTTP: &http.Client{Transport: transport}}
+	id := "did:ethr:" + identity
+	parsed, err := did.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New(Network{Name: "mainnet", ChainID: 1, Client: client})
+	if _, err := r.Resolve(id, parsed, r); err != nil {
+		t.Fatal(err)
+	}
+	// One call for the last change, and one for the logs of its block.
+	if transport.Requests() != 2 {
+		t.Errorf("expected the transport to be used, got %d requests", transport.Requests())
+	}
+}
@@ -0,0 +1,244 @@
+// Package ethr provides tools for resolving the did:ethr method format, for
+// dids managed by the ERC-1056 ethereum identity registry:
+// https://github.com/decentralized-identity/ethr-did-resolver/blob/master/doc/did-method-spec.md
+// Copyright 2021 Textile
+// Copyright 2018 ConsenSys AG
+package ethr
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"golang.org/x/crypto/sha3"
+)
+
+var (
+	changedSelector = keccak("changed(address)")[:4]
+
+	ownerChangedTopic     = "0x" + hex.EncodeToString(keccak("DIDOwnerChanged(address,address,uint256)"))
+	delegateChangedTopic  = "0x" + hex.EncodeToString(keccak("DIDDelegateChanged(address,bytes32,address,uint256,uint256)"))
+	attributeChangedTopic = "0x" + hex.EncodeToString(keccak("DIDAttributeChanged(address,bytes32,bytes,uint256,uint256)"))
+)
+
+// keccak returns the legacy keccak-256 hash of str, as used by ethereum.
+func keccak(str string) []byte {
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write([]byte(str))
+	return hash.Sum(nil)
+}
+
+// Event is a decoded ERC-1056 registry event.
+type Event struct {
+	// Kind is one of "DIDOwnerChanged", "DIDDelegateChanged" or
+	// "DIDAttributeChanged".
+	Kind        string
+	BlockNumber uint64
+	// Owner is set for DIDOwnerChanged events.
+	Owner string
+	// DelegateType and Delegate are set for DIDDelegateChanged events.
+	DelegateType string
+	Delegate     string
+	// Name and Value are set for DIDAttributeChanged events.
+	Name  string
+	Value []byte
+	// ValidTo is the unix time until which a delegate or attribute is valid.
+	ValidTo        *big.Int
+	PreviousChange uint64
+}
+
+// log is an ethereum log entry, as returned by eth_getLogs.
+type log struct {
+	BlockNumber string   `json:"blockNumber"`
+	Topics      []string `json:"topics"`
+	Data        string   `json:"data"`
+}
+
+// block is an ethereum block header, as returned by eth_getBlockByNumber.
+type block struct {
+	Timestamp string `json:"timestamp"`
+}
+
+// padAddress left pads an address to a 32 byte abi word, hex encoded.
+func padAddress(address string) string {
+	return strings.Repeat("0", 24) + strings.ToLower(strings.TrimPrefix(address, "0x"))
+}
+
+// parseQuantity parses a hex encoded JSON-RPC quantity.
+func parseQuantity(str string) (*big.Int, error) {
+	n, ok := new(big.Int).SetString(strings.TrimPrefix(str, "0x"), 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid quantity: '%s'", str)
+	}
+	return n, nil
+}
+
+// changed calls the registry to find the block of the last change to identity.
+func changed(client Client, registry, identity string) (uint64, error) {
+	var result string
+	call := map[string]string{
+		"to":   registry,
+		"data": "0x" + hex.EncodeToString(changedSelector) + padAddress(identity),
+	}
+	if err := client.Call("eth_call", []interface{}{call, "latest"}, &result); err != nil {
+		return 0, err
+	}
+	n, err := parseQuantity(result)
+	if err != nil {
+		return 0, err
+	}
+	return n.Uint64(), nil
+}
+
+// blockTimestamp returns the timestamp of a block.
+func blockTimestamp(client Client, number uint64) (int64, error) {
+	var result block
+	if err := client.Call("eth_getBlockByNumber", []interface{}{fmt.Sprintf("0x%x", number), false}, &result); err != nil {
+		return 0, err
+	}
+	n, err := parseQuantity(result.Timestamp)
+	if err != nil {
+		return 0, err
+	}
+	return n.Int64(), nil
+}
+
+// history walks back through the linked list of registry events for identity,
+// and returns them in chronological order.
+func history(client Client, registry, identity string) ([]Event, error) {
+	previous, err := changed(client, registry, identity)
+	if err != nil {
+		return nil, err
+	}
+	var events []Event
+	for previous != 0 {
+		var logs []log
+		filter := map[string]interface{}{
+			"address":   registry,
+			"fromBlock": fmt.Sprintf("0x%x", previous),
+			"toBlock":   fmt.Sprintf("0x%x", previous),
+			"topics":    []interface{}{nil, "0x" + padAddress(identity)},
+		}
+		if err := client.Call("eth_getLogs", []interface{}{filter}, &logs); err != nil {
+			return nil, err
+		}
+		block := previous
+		previous = 0
+		// Logs within a block are in order, walk them backwards too.
+		for i := len(logs) - 1; i >= 0; i-- {
+			event, err := decodeLog(logs[i])
+			if err != nil {
+				return nil, err
+			}
+			if event == nil {
+				continue
+			}
+			events = append(events, *event)
+			if event.PreviousChange < block {
+				previous = event.PreviousChange
+			}
+		}
+	}
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	return events, nil
+}
+
+// decodeLog decodes a registry log entry, returning nil for unknown events.
+func decodeLog(l log) (*Event, error) {
+	if len(l.Topics) == 0 {
+		return nil, nil
+	}
+	number, err := parseQuantity(l.BlockNumber)
+	if err != nil {
+		return nil, err
+	}
+	data, err := hex.DecodeString(strings.TrimPrefix(l.Data, "0x"))
+	if err != nil {
+		return nil, err
+	}
+	if len(data)%32 != 0 {
+		return nil, fmt.Errorf("invalid log data length: %d", len(data))
+	}
+	words := make([][]byte, len(data)/32)
+	for i := range words {
+		words[i] = data[i*32 : (i+1)*32]
+	}
+	event := &Event{BlockNumber: number.Uint64()}
+	switch l.Topics[0] {
+	case ownerChangedTopic:
+		// owner, previousChange
+		if len(words) != 2 {
+			return nil, fmt.Errorf("invalid DIDOwnerChanged event")
+		}
+		event.Kind = "DIDOwnerChanged"
+		event.Owner = wordAddress(words[0])
+		previous, ok := wordUint64(words[1])
+		if !ok {
+			return nil, fmt.Errorf("invalid DIDOwnerChanged event")
+		}
+		event.PreviousChange = previous
+	case delegateChangedTopic:
+		// delegateType, delegate, validTo, previousChange
+		if len(words) != 4 {
+			return nil, fmt.Errorf("invalid DIDDelegateChanged event")
+		}
+		event.Kind = "DIDDelegateChanged"
+		event.DelegateType = wordString(words[0])
+		event.Delegate = wordAddress(words[1])
+		event.ValidTo = new(big.Int).SetBytes(words[2])
+		previous, ok := wordUint64(words[3])
+		if !ok {
+			return nil, fmt.Errorf("invalid DIDDelegateChanged event")
+		}
+		event.PreviousChange = previous
+	case attributeChangedTopic:
+		// name, value offset, validTo, previousChange, value length, value
+		if len(words) < 5 {
+			return nil, fmt.Errorf("invalid DIDAttributeChanged event")
+		}
+		event.Kind = "DIDAttributeChanged"
+		event.Name = wordString(words[0])
+		event.ValidTo = new(big.Int).SetBytes(words[2])
+		previous, ok := wordUint64(words[3])
+		if !ok {
+			return nil, fmt.Errorf("invalid DIDAttributeChanged event")
+		}
+		event.PreviousChange = previous
+		// The offset and length come from the node, so are compared to what
+		// remains of the data rather than added, which could overflow.
+		size := uint64(len(data))
+		offset, ok := wordUint64(words[1])
+		if !ok || offset > size-32 {
+			return nil, fmt.Errorf("invalid DIDAttributeChanged event")
+		}
+		length, ok := wordUint64(data[offset : offset+32])
+		if !ok || length > size-offset-32 {
+			return nil, fmt.Errorf("invalid DIDAttributeChanged event")
+		}
+		event.Value = data[offset+32 : offset+32+length]
+	default:
+		return nil, nil
+	}
+	return event, nil
+}
+
+// wordUint64 returns the unsigned integer in an abi word, and whether it fits
+// in a uint64.
+func wordUint64(word []byte) (uint64, bool) {
+	n := new(big.Int).SetBytes(word)
+	return n.Uint64(), n.IsUint64()
+}
+
+// wordAddress returns the hex encoded address in an abi word.
+func wordAddress(word []byte) string {
+	return "0x" + hex.EncodeToString(word[12:])
+}
+
+// wordString returns the null padded bytes32 string in an abi word.
+func wordString(word []byte) string {
+	return string(bytes.TrimRight(word, "\x00"))
+}
@@ -1,25 +1,28 @@
 module github.com/textileio/go-did-resolver
 
-go 1.15
+go 1.18
 
 require (
-	github.com/go-delve/delve v1.6.0 // indirect
+	filippo.io/edwards25519 v1.0.0
+	github.com/decred/dcrd/dcrec/secp256k1/v4 v4.2.0
 	github.com/ipfs/go-cid v0.0.7
 	github.com/jorrizza/ed2curve25519 v0.1.0
-	github.com/magefile/mage v1.11.0 // indirect
-	github.com/mattn/go-colorable v0.1.8 // indirect
-	github.com/mattn/go-runewidth v0.0.10 // indirect
 	github.com/multiformats/go-multibase v0.0.3
 	github.com/multiformats/go-multicodec v0.2.0
+	github.com/multiformats/go-multihash v0.0.13
 	github.com/multiformats/go-varint v0.0.6
 	github.com/ockam-network/did v0.1.4-0.20210103172416-02ae01ce06d8
-	github.com/peterh/liner v1.2.1 // indirect
-	github.com/rivo/uniseg v0.2.0 // indirect
-	github.com/russross/blackfriday v1.6.0 // indirect
-	github.com/russross/blackfriday/v2 v2.1.0 // indirect
-	github.com/sirupsen/logrus v1.8.0 // indirect
-	github.com/spf13/cobra v1.1.3 // indirect
-	go.starlark.net v0.0.0-20210223155950-e043a3d3c984 // indirect
-	golang.org/x/arch v0.0.0-20210222215009-a3652b17bebe // indirect
-	golang.org/x/sys v0.0.0-20210223095934-7937bea0104d // indirect
+	golang.org/x/crypto v0.23.0
+	golang.org/x/net v0.25.0
+)
+
+require (
+	github.com/minio/blake2b-simd v0.0.0-20160723061019-3f5f724cb5b1 // indirect
+	github.com/minio/sha256-simd v0.1.1-0.20190913151208-6de447530771 // indirect
+	github.com/mr-tron/base58 v1.1.3 // indirect
+	github.com/multiformats/go-base32 v0.0.3 // indirect
+	github.com/multiformats/go-base36 v0.1.0 // indirect
+	github.com/spaolacci/murmur3 v1.1.0 // indirect
+	golang.org/x/sys v0.20.0 // indirect
+	golang.org/x/text v0.15.0 // indirect
 )
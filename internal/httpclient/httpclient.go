@@ -0,0 +1,42 @@
+// Package httpclient provides the http client shared by the resolvers that
+// request dids from a node, directory or api, unless they are given their own.
+// Copyright 2021 Textile
+package httpclient
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultTimeout is the time limit of requests made with Default.
+const DefaultTimeout = 10 * time.Second
+
+// Default is the client shared by resolvers without their own. Its transport
+// is http.DefaultTransport, so connections are pooled across resolvers.
+var Default = &http.Client{Timeout: DefaultTimeout}
+
+// Or returns client, or Default if client is nil.
+func Or(client *http.Client) *http.Client {
+	if client == nil {
+		return Default
+	}
+	return client
+}
+
+// CountingTransport is an http.RoundTripper that counts its requests, which
+// tests use to check that a given client is used.
+type CountingTransport struct {
+	requests int32
+}
+
+// RoundTrip counts req and makes it with http.DefaultTransport.
+func (c *CountingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	atomic.AddInt32(&c.requests, 1)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// Requests returns the number of requests made.
+func (c *CountingTransport) Requests() int {
+	return int(atomic.LoadInt32(&c.requests))
+}
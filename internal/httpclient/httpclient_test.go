@@ -0,0 +1,30 @@
+package httpclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOr(t *testing.T) {
+	if Or(nil) != Default || Default.Timeout != DefaultTimeout {
+		t.Error("expected the default client")
+	}
+	transport := &CountingTransport{}
+	client := &http.Client{Transport: transport}
+	if Or(client) != client {
+		t.Error("expected the given client")
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	for i := 0; i < 2; i++ {
+		resp, err := Or(client).Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+	if transport.Requests() != 2 {
+		t.Errorf("unexpected requests: %d", transport.Requests())
+	}
+}
@@ -0,0 +1,117 @@
+// Package ion provides tools for resolving the did:ion method format, and
+// Sidetree long-form dids in general:
+// https://identity.foundation/sidetree/spec/
+// Copyright 2021 Textile
+package ion
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/textileio/go-did-resolver/internal/httpclient"
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+// DefaultTimeout is the time limit of node requests of an HTTPClient without
+// an HTTP client.
+const DefaultTimeout = httpclient.DefaultTimeout
+
+// Client is a basic client interface for interacting with a Sidetree node.
+type Client interface {
+	// Resolve returns the published document of a short-form did. It should
+	// return a resolver.NotFound error for unpublished dids.
+	Resolve(did string) (*resolver.Document, resolver.DocumentMetadata, error)
+}
+
+// resolutionResult is the response of a Sidetree node resolution request.
+type resolutionResult struct {
+	Document json.RawMessage           `json:"didDocument"`
+	Metadata resolver.DocumentMetadata `json:"didDocumentMetadata"`
+}
+
+// HTTPClient interfaces with a Sidetree node via its REST api.
+type HTTPClient struct {
+	// URL is the base url of the node, such as "https://ion.example.com".
+	URL string
+	// HTTP fetches from the node, if set.
+	HTTP *http.Client
+}
+
+// Resolve fetches a did from the node's identifiers endpoint.
+func (client *HTTPClient) Resolve(did string) (*resolver.Document, resolver.DocumentMetadata, error) {
+	resp, err := httpclient.Or(client.HTTP).Get(strings.TrimSuffix(client.URL, "/") + "/identifiers/" + did)
+	if err != nil {
+		return nil, resolver.DocumentMetadata{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, resolver.DocumentMetadata{}, resolver.NewError(resolver.NotFound, "did not found: '%s'", did)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, resolver.DocumentMetadata{}, fmt.Errorf("unable to resolve '%s': '%s'", did, resp.Status)
+	}
+	var result resolutionResult
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, resolver.DocumentMetadata{}, err
+	}
+	doc, err := decodeDocument(result.Document)
+	if err != nil {
+		return nil, resolver.DocumentMetadata{}, err
+	}
+	return doc, result.Metadata, nil
+}
+
+// decodeDocument decodes a document returned by a Sidetree node. Nodes use an
+// "@base" context with relative ids, which are made absolute here.
+func decodeDocument(data []byte) (*resolver.Document, error) {
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+	var contexts []interface{}
+	if err := json.Unmarshal(members["@context"], &contexts); err != nil {
+		return nil, fmt.Errorf("invalid document context: %v", err)
+	}
+	delete(members, "@context")
+	data, err := json.Marshal(members)
+	if err != nil {
+		return nil, err
+	}
+	var doc resolver.Document
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	base := doc.ID
+	for _, context := range contexts {
+		switch context := context.(type) {
+		case string:
+			doc.Context = append(doc.Context, context)
+		case map[string]interface{}:
+			if b, ok := context["@base"].(string); ok {
+				base = b
+			}
+		}
+	}
+	for i := range doc.VerificationMethod {
+		doc.VerificationMethod[i].ID = resolver.AbsoluteID(base, doc.VerificationMethod[i].ID)
+	}
+	for _, relationship := range [][]resolver.VerificationMethod{
+		doc.Authentication,
+		doc.AssertionMethod,
+		doc.CapabilityInvocation,
+		doc.CapabilityDelegation,
+		doc.KeyAgreement,
+	} {
+		for i := range relationship {
+			relationship[i].ID = resolver.AbsoluteID(base, relationship[i].ID)
+		}
+	}
+	for i := range doc.Service {
+		doc.Service[i].ID = resolver.AbsoluteID(base, doc.Service[i].ID)
+	}
+	return &doc, nil
+}
+
+var _ Client = (*HTTPClient)(nil)
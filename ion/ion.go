@@ -0,0 +1,90 @@
+// Package ion provides tools for resolving the did:ion method format, and
+// Sidetree long-form dids in general:
+// https://identity.foundation/sidetree/spec/
+// Copyright 2021 Textile
+package ion
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/ockam-network/did"
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+// Resolver resolves did:ion identifiers. Long-form dids are resolved from
+// their embedded create operation, short-form dids require a Client.
+type Resolver struct {
+	client Client
+}
+
+// New creates and returns a new ion Resolver. The client is optional, and is
+// used to resolve short-form dids and published long-form dids.
+func New(client Client) *Resolver {
+	return &Resolver{client: client}
+}
+
+// Method returns the method that this resolver is capable of resolving.
+func (r *Resolver) Method() string {
+	return "ion"
+}
+
+// Resolve is the primary resolution method for this resolver.
+func (r *Resolver) Resolve(did string, parsed *did.DID, res resolver.Resolver) (*resolver.Document, error) {
+	doc, _, err := r.ResolveWithMetadata(did, parsed, res, nil)
+	return doc, err
+}
+
+// ResolveWithMetadata resolves the did, and returns its canonical and
+// equivalent short-form ids as document metadata.
+func (r *Resolver) ResolveWithMetadata(did string, parsed *did.DID, res resolver.Resolver, options *resolver.ResolutionOptions) (*resolver.Document, resolver.DocumentMetadata, error) {
+	if parsed.Method != r.Method() {
+		return nil, resolver.DocumentMetadata{}, fmt.Errorf("unknown did method: '%s'", parsed.Method)
+	}
+	// The id is an optional network, the suffix, and an optional long-form.
+	parts := strings.Split(parsed.ID, ":")
+	var encoded string
+	if n := len(parts); n > 1 && !isSuffix(parts[n-1]) {
+		encoded, parts = parts[n-1], parts[:n-1]
+	}
+	suffix := parts[len(parts)-1]
+	if !isSuffix(suffix) {
+		return nil, resolver.DocumentMetadata{}, fmt.Errorf("invalid did suffix: '%s'", suffix)
+	}
+	short := "did:ion:" + strings.Join(parts, ":")
+
+	if r.client != nil {
+		doc, metadata, err := r.client.Resolve(short)
+		var rerr *resolver.Error
+		switch {
+		case err == nil:
+			metadata.CanonicalID = short
+			if len(metadata.EquivalentID) == 0 {
+				metadata.EquivalentID = []string{short}
+			}
+			return doc, metadata, nil
+		case encoded == "" || !errors.As(err, &rerr) || rerr.Code != resolver.NotFound:
+			return nil, resolver.DocumentMetadata{}, err
+		}
+		// Unpublished long-form dids are resolved from their create operation.
+	} else if encoded == "" {
+		return nil, resolver.DocumentMetadata{}, resolver.NewError(resolver.NotFound, "short-form did requires a sidetree node: '%s'", short)
+	}
+
+	delta, err := decodeLongForm(suffix, encoded)
+	if err != nil {
+		return nil, resolver.DocumentMetadata{}, err
+	}
+	var state DocumentState
+	if err := state.apply(delta); err != nil {
+		return nil, resolver.DocumentMetadata{}, err
+	}
+	doc, err := state.document(fmt.Sprintf("did:ion:%s", parsed.ID))
+	if err != nil {
+		return nil, resolver.DocumentMetadata{}, err
+	}
+	return doc, resolver.DocumentMetadata{EquivalentID: []string{short}}, nil
+}
+
+var _ resolver.MetadataResolver = (*Resolver)(nil)
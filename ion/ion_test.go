@@ -0,0 +1,356 @@
+// Package ion provides tools for resolving the did:ion method format, and
+// Sidetree long-form dids in general:
+// https://identity.foundation/sidetree/spec/
+// Copyright 2021 Textile
+package ion
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	mh "github.com/multiformats/go-multihash"
+	"github.com/textileio/go-did-resolver/internal/httpclient"
+	"github.com/textileio/go-did-resolver/jcs"
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+const (
+	recoveryCommitment = "EiBfOZdMtU6OBw8Pk879QtZ-2J-9FbbjSZyoaA_bqD4zhA"
+	updateCommitment   = "EiDKIkwqO69IPG3pOlHkdb86nYt0aNxSHZu2r-bhEznjdA"
+)
+
+var signingKey = PublicKey{
+	ID:           "signing-key",
+	Type:         "EcdsaSecp256k1VerificationKey2019",
+	PublicKeyJwk: json.RawMessage(`{"kty":"EC","crv":"secp256k1","x":"Bvo3HzH7dpxwKyeX8mPXKZ5DWPuu3p79hP1Aqe4uHgA","y":"RGE7r8S1LNmdC8ZfUujUTuN9tqWyzwzPMqXjXwuXsS0"}`),
+	Purposes:     []string{"authentication", "assertionMethod"},
+}
+
+var linkedDomains = Service{
+	ID:              "linked-domain",
+	Type:            "LinkedDomains",
+	ServiceEndpoint: json.RawMessage(`"https://bar.example.com"`),
+}
+
+// hash returns the base64url encoded sha2-256 multihash of the canonicalized
+// value.
+func hash(t *testing.T, value interface{}) string {
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	canonical, err := jcs.Canonicalize(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(canonical)
+	encoded, err := mh.Encode(digest[:], mh.SHA2_256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return encoding.EncodeToString(encoded)
+}
+
+// longForm creates the short and long-form did for the patches.
+func longForm(t *testing.T, patches ...Patch) (string, string) {
+	delta := Delta{Patches: patches, UpdateCommitment: updateCommitment}
+	suffixData := SuffixData{DeltaHash: hash(t, delta), RecoveryCommitment: recoveryCommitment}
+	data, err := json.Marshal(map[string]interface{}{"suffixData": suffixData, "delta": delta})
+	if err != nil {
+		t.Fatal(err)
+	}
+	short := "did:ion:" + hash(t, suffixData)
+	return short, short + ":" + encoding.EncodeToString(data)
+}
+
+func resolve(t *testing.T, client Client, id string) (*resolver.Document, resolver.DocumentMetadata, error) {
+	parsed, err := resolver.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New(client)
+	return r.ResolveWithMetadata(id, parsed, r, nil)
+}
+
+func TestUnknownMethod(t *testing.T) {
+	_, long := longForm(t)
+	_, _, err := resolve(t, nil, strings.Replace(long, "did:ion", "did:borg", 1))
+	if err == nil || err.Error() != "unknown did method: 'borg'" {
+		t.Error("expected Resolve to return error")
+	}
+}
+
+func TestResolveLongForm(t *testing.T) {
+	short, long := longForm(t, Patch{
+		Action:     "add-public-keys",
+		PublicKeys: []PublicKey{signingKey},
+	}, Patch{
+		Action:   "add-services",
+		Services: []Service{linkedDomains},
+	})
+	r := resolver.New([]resolver.Resolver{New(nil)}, true)
+	_, doc, metadata, err := r.Resolve(long, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID := long + "#signing-key"
+	expected := &resolver.Document{
+		Context: []string{"https://www.w3.org/ns/did/v1"},
+		ID:      long,
+		VerificationMethod: []resolver.VerificationMethod{
+			{
+				ID:         keyID,
+				Type:       "EcdsaSecp256k1VerificationKey2019",
+				Controller: long,
+				PublicKeyJwk: &resolver.JWK{
+					Kty: "EC",
+					Crv: "secp256k1",
+					X:   "Bvo3HzH7dpxwKyeX8mPXKZ5DWPuu3p79hP1Aqe4uHgA",
+					Y:   "RGE7r8S1LNmdC8ZfUujUTuN9tqWyzwzPMqXjXwuXsS0",
+				},
+			},
+		},
+		Authentication:  []resolver.VerificationMethod{resolver.Reference(keyID)},
+		AssertionMethod: []resolver.VerificationMethod{resolver.Reference(keyID)},
+		Service: []resolver.ServiceEndpoint{
+			{
+				ID:              long + "#linked-domain",
+				Type:            "LinkedDomains",
+				ServiceEndpoint: "https://bar.example.com",
+			},
+		},
+	}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Errorf("unexpected document: %+v", doc)
+	}
+	if !reflect.DeepEqual(metadata.EquivalentID, []string{short}) || metadata.CanonicalID != "" {
+		t.Errorf("unexpected metadata: %+v", metadata)
+	}
+}
+
+// TestResolveSpecVector resolves the long-form did of the Sidetree
+// specification, which is not built with the hashing code of this package.
+// See https://identity.foundation/sidetree/spec/#long-form-did-uris
+func TestResolveSpecVector(t *testing.T) {
+	short := "did:ion:EiDyOQbbZAa3aiRzeCkV7LOx3SERjjH93EXoIM3UoN4oWg"
+	long := short + ":" +
+		"eyJkZWx0YSI6eyJwYXRjaGVzIjpbeyJhY3Rpb24iOiJyZXBsYWNlIiwiZG9jdW1lbnQiOnsicHVibGljS2V5cyI6W3siaWQiOiJw" +
+		"dWJsaWNLZXlNb2RlbDFJZCIsInB1YmxpY0tleUp3ayI6eyJjcnYiOiJzZWNwMjU2azEiLCJrdHkiOiJFQyIsIngiOiJ0WFNLQl9y" +
+		"dWJYUzdzQ2pYcXVwVkpFelRjVzNNc2ptRXZxMVlwWG45NlpnIiwieSI6ImRPaWNYcWJqRnhvR0otSzAtR0oxa0hZSnFpY19EX09N" +
+		"dVV3a1E3T2w2bmsifSwicHVycG9zZXMiOlsiYXV0aGVudGljYXRpb24iLCJrZXlBZ3JlZW1lbnQiXSwidHlwZSI6IkVjZHNhU2Vj" +
+		"cDI1NmsxVmVyaWZpY2F0aW9uS2V5MjAxOSJ9XSwic2VydmljZXMiOlt7ImlkIjoic2VydmljZTFJZCIsInNlcnZpY2VFbmRwb2lu" +
+		"dCI6Imh0dHA6Ly93d3cuc2VydmljZTEuY29tIiwidHlwZSI6InNlcnZpY2UxVHlwZSJ9XX19XSwidXBkYXRlQ29tbWl0bWVudCI6" +
+		"IkVpREtJa3dxTzY5SVBHM3BPbEhrZGI4Nm5ZdDBhTnhTSFp1MnItYmhFem5qZEEifSwic3VmZml4RGF0YSI6eyJkZWx0YUhhc2gi" +
+		"OiJFaUNmRFdSbllsY0Q5RUdBM2RfNVoxQUh1LWlZcU1iSjluZmlxZHo1UzhWRGJnIiwicmVjb3ZlcnlDb21taXRtZW50IjoiRWlC" +
+		"Zk9aZE10VTZPQnc4UGs4NzlRdFotMkotOUZiYmpTWnlvYUFfYnFENHpoQSJ9fQ"
+	doc, metadata, err := resolve(t, nil, long)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID := long + "#publicKeyModel1Id"
+	expected := &resolver.Document{
+		Context: []string{"https://www.w3.org/ns/did/v1"},
+		ID:      long,
+		VerificationMethod: []resolver.VerificationMethod{
+			{
+				ID:         keyID,
+				Type:       "EcdsaSecp256k1VerificationKey2019",
+				Controller: long,
+				PublicKeyJwk: &resolver.JWK{
+					Kty: "EC",
+					Crv: "secp256k1",
+					X:   "tXSKB_rubXS7sCjXqupVJEzTcW3MsjmEvq1YpXn96Zg",
+					Y:   "dOicXqbjFxoGJ-K0-GJ1kHYJqic_D_OMuUwkQ7Ol6nk",
+				},
+			},
+		},
+		Authentication: []resolver.VerificationMethod{resolver.Reference(keyID)},
+		KeyAgreement:   []resolver.VerificationMethod{resolver.Reference(keyID)},
+		Service: []resolver.ServiceEndpoint{
+			{
+				ID:              long + "#service1Id",
+				Type:            "service1Type",
+				ServiceEndpoint: "http://www.service1.com",
+			},
+		},
+	}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Errorf("unexpected document: %+v", doc)
+	}
+	if !reflect.DeepEqual(metadata.EquivalentID, []string{short}) {
+		t.Errorf("unexpected metadata: %+v", metadata)
+	}
+
+	// Changing the delta no longer matches the delta hash of the suffix data.
+	data, err := encoding.DecodeString(long[len(short)+1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = []byte(strings.Replace(string(data), "service1.com", "service2.com", 1))
+	if _, _, err := resolve(t, nil, short+":"+encoding.EncodeToString(data)); err == nil {
+		t.Error("expected Resolve to return error")
+	}
+}
+
+func TestResolveNetwork(t *testing.T) {
+	_, long := longForm(t, Patch{Action: "add-public-keys", PublicKeys: []PublicKey{signingKey}})
+	long = strings.Replace(long, "did:ion:", "did:ion:test:", 1)
+	doc, metadata, err := resolve(t, nil, long)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.ID != long || !strings.HasPrefix(metadata.EquivalentID[0], "did:ion:test:") {
+		t.Errorf("unexpected document: %+v", doc)
+	}
+}
+
+func TestPatches(t *testing.T) {
+	other := signingKey
+	other.ID = "other-key"
+	other.Purposes = []string{"keyAgreement"}
+	_, long := longForm(t, Patch{
+		Action:     "add-public-keys",
+		PublicKeys: []PublicKey{signingKey},
+	}, Patch{
+		Action: "replace",
+		Document: &DocumentState{
+			PublicKeys: []PublicKey{signingKey, other},
+			Services:   []Service{linkedDomains},
+		},
+	}, Patch{
+		Action: "remove-public-keys",
+		IDs:    []string{"signing-key"},
+	}, Patch{
+		Action: "remove-services",
+		IDs:    []string{"linked-domain"},
+	})
+	doc, _, err := resolve(t, nil, long)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.VerificationMethod) != 1 || doc.VerificationMethod[0].ID != long+"#other-key" {
+		t.Errorf("unexpected verification methods: %+v", doc.VerificationMethod)
+	}
+	if len(doc.KeyAgreement) != 1 || len(doc.Authentication) != 0 || len(doc.Service) != 0 {
+		t.Errorf("unexpected document: %+v", doc)
+	}
+}
+
+func TestInvalidLongForm(t *testing.T) {
+	short, long := longForm(t, Patch{Action: "add-public-keys", PublicKeys: []PublicKey{signingKey}})
+	other, _ := longForm(t)
+	_, _, err := resolve(t, nil, strings.Replace(long, short, other, 1))
+	if err == nil || err.Error() != "did suffix does not match suffix data" {
+		t.Error("expected Resolve to return error")
+	}
+
+	private := signingKey
+	private.PublicKeyJwk = json.RawMessage(`{"kty":"EC","crv":"secp256k1","x":"a","y":"b","d":"c"}`)
+	_, long = longForm(t, Patch{Action: "add-public-keys", PublicKeys: []PublicKey{private}})
+	_, _, err = resolve(t, nil, long)
+	if err == nil || err.Error() != "public key jwk must not contain private key: 'signing-key'" {
+		t.Error("expected Resolve to return error")
+	}
+
+	invalid := signingKey
+	invalid.Purposes = []string{"authentication", "authentication"}
+	_, long = longForm(t, Patch{Action: "add-public-keys", PublicKeys: []PublicKey{invalid}})
+	if _, _, err = resolve(t, nil, long); err == nil {
+		t.Error("expected Resolve to return error")
+	}
+
+	_, long = longForm(t, Patch{Action: "ietf-json-patch"})
+	_, _, err = resolve(t, nil, long)
+	if err == nil || err.Error() != "unsupported patch action: 'ietf-json-patch'" {
+		t.Error("expected Resolve to return error")
+	}
+}
+
+// TestTamperedDelta changes the delta without updating the delta hash.
+func TestTamperedDelta(t *testing.T) {
+	_, long := longForm(t, Patch{Action: "add-public-keys", PublicKeys: []PublicKey{signingKey}})
+	i := strings.LastIndex(long, ":")
+	data, err := encoding.DecodeString(long[i+1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = []byte(strings.Replace(string(data), "signing-key", "another-key", 1))
+	_, _, err = resolve(t, nil, long[:i+1]+encoding.EncodeToString(data))
+	if err == nil || err.Error() != "delta hash does not match delta" {
+		t.Error("expected Resolve to return error")
+	}
+}
+
+func TestShortFormWithoutClient(t *testing.T) {
+	short, _ := longForm(t)
+	_, _, err := resolve(t, nil, short)
+	var rerr *resolver.Error
+	if !errors.As(err, &rerr) || rerr.Code != resolver.NotFound {
+		t.Errorf("expected notFound error: %v", err)
+	}
+}
+
+func TestClient(t *testing.T) {
+	short, long := longForm(t, Patch{Action: "add-public-keys", PublicKeys: []PublicKey{signingKey}})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/identifiers/"+short {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{
+			"didDocument": {
+				"@context": ["https://www.w3.org/ns/did/v1", {"@base": "` + short + `"}],
+				"id": "` + short + `",
+				"verificationMethod": [{"id": "#signing-key", "controller": "` + short + `", "type": "EcdsaSecp256k1VerificationKey2019"}],
+				"authentication": ["#signing-key"]
+			},
+			"didDocumentMetadata": {"method": {"published": true}, "canonicalId": "` + short + `"}
+		}`))
+	}))
+	defer server.Close()
+
+	doc, metadata, err := resolve(t, &HTTPClient{URL: server.URL}, long)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(doc.Context, []string{"https://www.w3.org/ns/did/v1"}) {
+		t.Errorf("unexpected context: %v", doc.Context)
+	}
+	if doc.VerificationMethod[0].ID != short+"#signing-key" || doc.Authentication[0].ID != short+"#signing-key" {
+		t.Errorf("expected absolute ids: %+v", doc)
+	}
+	if metadata.CanonicalID != short || !reflect.DeepEqual(metadata.EquivalentID, []string{short}) {
+		t.Errorf("unexpected metadata: %+v", metadata)
+	}
+
+	// Unpublished long-form dids fall back to their create operation.
+	unpublished, long := longForm(t)
+	doc, metadata, err = resolve(t, &HTTPClient{URL: server.URL}, long)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.ID != long || metadata.CanonicalID != "" {
+		t.Errorf("unexpected document: %+v", doc)
+	}
+	_, _, err = resolve(t, &HTTPClient{URL: server.URL}, unpublished)
+	var rerr *resolver.Error
+	if !errors.As(err, &rerr) || rerr.Code != resolver.NotFound {
+		t.Errorf("expected notFound error: %v", err)
+	}
+
+	// Requests go through the http client of the HTTPClient, when set.
+	transport := &httpclient.CountingTransport{}
+	_, _, err = resolve(t, &HTTPClient{URL: server.URL, HTTP: &http.Client{Transport: transport}}, short)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transport.Requests() != 1 {
+		t.Errorf("expected the transport to be used, got %d requests", transport.Requests())
+	}
+}
@@ -0,0 +1,322 @@
+// Package ion provides tools for resolving the did:ion method format, and
+// Sidetree long-form dids in general:
+// https://identity.foundation/sidetree/spec/
+// Copyright 2021 Textile
+package ion
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+
+	mh "github.com/multiformats/go-multihash"
+	"github.com/textileio/go-did-resolver/jcs"
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+var encoding = base64.RawURLEncoding
+
+// idPattern matches the ids of public keys and services.
+var idPattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,50}$`)
+
+// purposes are the verification relationships a public key may be used for.
+var purposes = map[string]bool{
+	"authentication":       true,
+	"assertionMethod":      true,
+	"capabilityInvocation": true,
+	"capabilityDelegation": true,
+	"keyAgreement":         true,
+}
+
+// CreateOperation is the create operation embedded in a long-form did.
+// Both members are kept as they were encoded, so that they hash the same.
+type CreateOperation struct {
+	SuffixData json.RawMessage `json:"suffixData"`
+	Delta      json.RawMessage `json:"delta"`
+}
+
+// SuffixData is the part of a create operation that the did suffix is
+// derived from.
+type SuffixData struct {
+	DeltaHash          string `json:"deltaHash"`
+	RecoveryCommitment string `json:"recoveryCommitment"`
+	Type               string `json:"type,omitempty"`
+	AnchorOrigin       string `json:"anchorOrigin,omitempty"`
+}
+
+// Delta is the set of patches that produce the document state.
+type Delta struct {
+	Patches          []Patch `json:"patches"`
+	UpdateCommitment string  `json:"updateCommitment"`
+}
+
+// Patch is a single change to the document state.
+type Patch struct {
+	Action     string         `json:"action"`
+	PublicKeys []PublicKey    `json:"publicKeys,omitempty"`
+	Services   []Service      `json:"services,omitempty"`
+	IDs        []string       `json:"ids,omitempty"`
+	Document   *DocumentState `json:"document,omitempty"`
+}
+
+// DocumentState is the set of public keys and services of a did.
+type DocumentState struct {
+	PublicKeys []PublicKey `json:"publicKeys,omitempty"`
+	Services   []Service   `json:"services,omitempty"`
+}
+
+// PublicKey is a public key entry of the document state.
+type PublicKey struct {
+	ID           string          `json:"id"`
+	Type         string          `json:"type"`
+	PublicKeyJwk json.RawMessage `json:"publicKeyJwk"`
+	Purposes     []string        `json:"purposes,omitempty"`
+}
+
+// Service is a service entry of the document state.
+type Service struct {
+	ID              string          `json:"id"`
+	Type            string          `json:"type"`
+	ServiceEndpoint json.RawMessage `json:"serviceEndpoint"`
+}
+
+// decodeLongForm decodes the create operation of a long-form did, and checks
+// that it hashes to the did suffix.
+func decodeLongForm(suffix, encoded string) (*Delta, error) {
+	data, err := encoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("invalid long-form did: %v", err)
+	}
+	var op CreateOperation
+	if err := json.Unmarshal(data, &op); err != nil {
+		return nil, fmt.Errorf("invalid long-form did: %v", err)
+	}
+	if op.SuffixData == nil || op.Delta == nil {
+		return nil, fmt.Errorf("invalid long-form did: missing suffixData or delta")
+	}
+	if err := verifyHash(suffix, op.SuffixData); err != nil {
+		return nil, fmt.Errorf("did suffix does not match suffix data")
+	}
+	var suffixData SuffixData
+	if err := strictUnmarshal(op.SuffixData, &suffixData); err != nil {
+		return nil, fmt.Errorf("invalid suffix data: %v", err)
+	}
+	if err := verifyHash(suffixData.DeltaHash, op.Delta); err != nil {
+		return nil, fmt.Errorf("delta hash does not match delta")
+	}
+	var delta Delta
+	if err := strictUnmarshal(op.Delta, &delta); err != nil {
+		return nil, fmt.Errorf("invalid delta: %v", err)
+	}
+	return &delta, nil
+}
+
+// strictUnmarshal decodes data into v, rejecting unknown members.
+func strictUnmarshal(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// verifyHash checks that the base64url encoded sha2-256 multihash matches the
+// canonicalized data.
+func verifyHash(encoded string, data []byte) error {
+	hash, err := encoding.DecodeString(encoded)
+	if err != nil {
+		return err
+	}
+	decoded, err := mh.Decode(hash)
+	if err != nil {
+		return err
+	}
+	if decoded.Code != mh.SHA2_256 {
+		return fmt.Errorf("unsupported hash algorithm: '%s'", decoded.Name)
+	}
+	canonical, err := jcs.Canonicalize(data)
+	if err != nil {
+		return err
+	}
+	digest := sha256.Sum256(canonical)
+	if !bytes.Equal(decoded.Digest, digest[:]) {
+		return fmt.Errorf("hash mismatch")
+	}
+	return nil
+}
+
+// isSuffix reports whether str is a base64url encoded multihash, rather than
+// an encoded create operation.
+func isSuffix(str string) bool {
+	hash, err := encoding.DecodeString(str)
+	if err != nil {
+		return false
+	}
+	_, err = mh.Decode(hash)
+	return err == nil
+}
+
+// apply applies the patches of delta to the document state.
+func (s *DocumentState) apply(delta *Delta) error {
+	for _, patch := range delta.Patches {
+		switch patch.Action {
+		case "add-public-keys":
+			for _, key := range patch.PublicKeys {
+				if err := key.validate(); err != nil {
+					return err
+				}
+				s.removePublicKeys([]string{key.ID})
+				s.PublicKeys = append(s.PublicKeys, key)
+			}
+		case "remove-public-keys":
+			s.removePublicKeys(patch.IDs)
+		case "add-services":
+			for _, service := range patch.Services {
+				if err := service.validate(); err != nil {
+					return err
+				}
+				s.removeServices([]string{service.ID})
+				s.Services = append(s.Services, service)
+			}
+		case "remove-services":
+			s.removeServices(patch.IDs)
+		case "replace":
+			if patch.Document == nil {
+				return fmt.Errorf("replace patch is missing document")
+			}
+			*s = DocumentState{}
+			if err := s.apply(&Delta{Patches: []Patch{
+				{Action: "add-public-keys", PublicKeys: patch.Document.PublicKeys},
+				{Action: "add-services", Services: patch.Document.Services},
+			}}); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unsupported patch action: '%s'", patch.Action)
+		}
+	}
+	return nil
+}
+
+func (s *DocumentState) removePublicKeys(ids []string) {
+	keys := s.PublicKeys[:0]
+	for _, key := range s.PublicKeys {
+		if !contains(ids, key.ID) {
+			keys = append(keys, key)
+		}
+	}
+	s.PublicKeys = keys
+}
+
+func (s *DocumentState) removeServices(ids []string) {
+	services := s.Services[:0]
+	for _, service := range s.Services {
+		if !contains(ids, service.ID) {
+			services = append(services, service)
+		}
+	}
+	s.Services = services
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks a public key entry, including that it has no private key
+// material.
+func (k PublicKey) validate() error {
+	if !idPattern.MatchString(k.ID) {
+		return fmt.Errorf("invalid public key id: '%s'", k.ID)
+	}
+	if k.Type == "" {
+		return fmt.Errorf("missing public key type: '%s'", k.ID)
+	}
+	var members map[string]interface{}
+	if err := json.Unmarshal(k.PublicKeyJwk, &members); err != nil {
+		return fmt.Errorf("invalid public key jwk: '%s'", k.ID)
+	}
+	if _, ok := members["d"]; ok {
+		return fmt.Errorf("public key jwk must not contain private key: '%s'", k.ID)
+	}
+	seen := make(map[string]bool)
+	for _, purpose := range k.Purposes {
+		if !purposes[purpose] || seen[purpose] {
+			return fmt.Errorf("invalid public key purpose: '%s'", purpose)
+		}
+		seen[purpose] = true
+	}
+	return nil
+}
+
+// validate checks a service entry. Only uri service endpoints are supported.
+func (s Service) validate() error {
+	if !idPattern.MatchString(s.ID) {
+		return fmt.Errorf("invalid service id: '%s'", s.ID)
+	}
+	if s.Type == "" || len(s.Type) > 30 {
+		return fmt.Errorf("invalid service type: '%s'", s.Type)
+	}
+	var endpoint string
+	if err := json.Unmarshal(s.ServiceEndpoint, &endpoint); err != nil {
+		return fmt.Errorf("unsupported service endpoint: '%s'", s.ID)
+	}
+	if u, err := url.Parse(endpoint); err != nil || !u.IsAbs() {
+		return fmt.Errorf("invalid service endpoint: '%s'", endpoint)
+	}
+	return nil
+}
+
+// document creates the did document for the document state.
+func (s *DocumentState) document(did string) (*resolver.Document, error) {
+	doc := &resolver.Document{
+		Context: []string{"https://www.w3.org/ns/did/v1"},
+		ID:      did,
+	}
+	for _, key := range s.PublicKeys {
+		var jwk resolver.JWK
+		if err := json.Unmarshal(key.PublicKeyJwk, &jwk); err != nil {
+			return nil, err
+		}
+		id := did + "#" + key.ID
+		doc.VerificationMethod = append(doc.VerificationMethod, resolver.VerificationMethod{
+			ID:           id,
+			Type:         key.Type,
+			Controller:   did,
+			PublicKeyJwk: &jwk,
+		})
+		ref := resolver.Reference(id)
+		for _, purpose := range key.Purposes {
+			switch purpose {
+			case "authentication":
+				doc.Authentication = append(doc.Authentication, ref)
+			case "assertionMethod":
+				doc.AssertionMethod = append(doc.AssertionMethod, ref)
+			case "capabilityInvocation":
+				doc.CapabilityInvocation = append(doc.CapabilityInvocation, ref)
+			case "capabilityDelegation":
+				doc.CapabilityDelegation = append(doc.CapabilityDelegation, ref)
+			case "keyAgreement":
+				doc.KeyAgreement = append(doc.KeyAgreement, ref)
+			}
+		}
+	}
+	for _, service := range s.Services {
+		var endpoint string
+		if err := json.Unmarshal(service.ServiceEndpoint, &endpoint); err != nil {
+			return nil, err
+		}
+		doc.Service = append(doc.Service, resolver.ServiceEndpoint{
+			ID:              did + "#" + service.ID,
+			Type:            service.Type,
+			ServiceEndpoint: endpoint,
+		})
+	}
+	return doc, nil
+}
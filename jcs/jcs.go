@@ -0,0 +1,142 @@
+// Package jcs implements the JSON Canonicalization Scheme, as used to hash
+// and sign json data by Sidetree, did:webvh and Data Integrity proofs:
+// https://tools.ietf.org/html/rfc8785
+// Copyright 2021 Textile
+package jcs
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// Canonicalize returns the canonical encoding of a json value.
+func Canonicalize(data []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	if decoder.More() {
+		return nil, fmt.Errorf("unexpected data after json value")
+	}
+	var b bytes.Buffer
+	if err := writeCanonical(&b, value); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func writeCanonical(b *bytes.Buffer, value interface{}) error {
+	switch value := value.(type) {
+	case nil:
+		b.WriteString("null")
+	case bool:
+		b.WriteString(strconv.FormatBool(value))
+	case json.Number:
+		f, err := value.Float64()
+		if err != nil {
+			return err
+		}
+		b.WriteString(formatNumber(f))
+	case string:
+		writeString(b, value)
+	case []interface{}:
+		b.WriteByte('[')
+		for i, v := range value {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			if err := writeCanonical(b, v); err != nil {
+				return err
+			}
+		}
+		b.WriteByte(']')
+	case map[string]interface{}:
+		// Members are sorted by the utf-16 code units of their names.
+		names := make([]string, 0, len(value))
+		for name := range value {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool {
+			return lessUTF16(names[i], names[j])
+		})
+		b.WriteByte('{')
+		for i, name := range names {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			writeString(b, name)
+			b.WriteByte(':')
+			if err := writeCanonical(b, value[name]); err != nil {
+				return err
+			}
+		}
+		b.WriteByte('}')
+	default:
+		return fmt.Errorf("unsupported json value: %T", value)
+	}
+	return nil
+}
+
+// writeString writes a json string with only the escaping required by JCS.
+func writeString(b *bytes.Buffer, str string) {
+	b.WriteByte('"')
+	for _, r := range str {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(b, `\u%04x`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+}
+
+// formatNumber formats a number the way ECMAScript's Number.toString does.
+func formatNumber(f float64) string {
+	if f == 0 {
+		// Includes negative zero.
+		return "0"
+	}
+	if abs := math.Abs(f); abs >= 1e-6 && abs < 1e21 {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	str := strconv.FormatFloat(f, 'e', -1, 64)
+	i := strings.IndexByte(str, 'e')
+	mantissa, sign, exponent := str[:i], str[i+1], strings.TrimLeft(str[i+2:], "0")
+	return mantissa + "e" + string(sign) + exponent
+}
+
+// lessUTF16 compares strings by their utf-16 code units.
+func lessUTF16(a, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
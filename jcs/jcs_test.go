@@ -0,0 +1,26 @@
+// Package jcs implements the JSON Canonicalization Scheme, as used to hash
+// and sign json data by Sidetree, did:webvh and Data Integrity proofs:
+// https://tools.ietf.org/html/rfc8785
+// Copyright 2021 Textile
+package jcs
+
+import (
+	"testing"
+)
+
+func TestCanonicalize(t *testing.T) {
+	tests := map[string]string{
+		`{"b": 2, "a": [1E21, 0.000001, 1e-7, -0, 4.50, "€\n<\u000f"]}`: `{"a":[1e+21,0.000001,1e-7,0,4.5,"€\n<\u000f"],"b":2}`,
+		`{"\ufb33": 1, "\ud83d\ude00": 2, "\u20ac": 3}`:                 "{\"\u20ac\":3,\"\U0001f600\":2,\"\ufb33\":1}",
+		`[null, true, false, {}]`:                                       `[null,true,false,{}]`,
+	}
+	for input, expected := range tests {
+		observed, err := Canonicalize([]byte(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(observed) != expected {
+			t.Errorf("unexpected canonical json: %s", observed)
+		}
+	}
+}
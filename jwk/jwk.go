@@ -0,0 +1,246 @@
+// Package jwk provides tools for converting did verification method key
+// material to and from JSON Web Keys: https://tools.ietf.org/html/rfc7517,
+// and for resolving the did:jwk method format that embeds them.
+// Copyright 2021 Textile
+package jwk
+
+import (
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+
+	mbase "github.com/multiformats/go-multibase"
+	codec "github.com/multiformats/go-multicodec"
+	"github.com/textileio/go-did-resolver/multikey"
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+// curves maps the multicodec codes of supported keys to their JWK key type
+// and curve names.
+// See https://www.iana.org/assignments/jose/jose.xhtml#web-key-elliptic-curve
+var curves = map[codec.Code]struct {
+	kty string
+	crv string
+	// size is the size in bytes of a coordinate, for EC keys.
+	size int
+}{
+	codec.Ed25519Pub:   {"OKP", "Ed25519", 0},
+	codec.X25519Pub:    {"OKP", "X25519", 0},
+	codec.Secp256k1Pub: {"EC", "secp256k1", 32},
+	codec.P256Pub:      {"EC", "P-256", 32},
+	codec.P384Pub:      {"EC", "P-384", 48},
+	codec.P521Pub:      {"EC", "P-521", 66},
+}
+
+// rawKeyTypes maps verification method types that carry raw, un-prefixed key
+// bytes to the multicodec code of the key.
+var rawKeyTypes = map[string]codec.Code{
+	"Ed25519VerificationKey2018":        codec.Ed25519Pub,
+	"X25519KeyAgreementKey2019":         codec.X25519Pub,
+	"Curve25519EncryptionPublicKey":     codec.X25519Pub,
+	"Secp256k1VerificationKey2018":      codec.Secp256k1Pub,
+	"EcdsaSecp256k1VerificationKey2019": codec.Secp256k1Pub,
+}
+
+var encoding = base64.RawURLEncoding
+
+// FromKey converts a multicodec public key into a JWK.
+func FromKey(key *multikey.Key) (*resolver.JWK, error) {
+	if key.Code == multikey.RSAPub {
+		return fromRSAKey(key.Bytes)
+	}
+	curve, ok := curves[key.Code]
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type: '%s'", key.Name())
+	}
+	if err := key.Validate(); err != nil {
+		return nil, err
+	}
+	jwk := &resolver.JWK{
+		Kty: curve.kty,
+		Crv: curve.crv,
+	}
+	if curve.kty == "OKP" {
+		jwk.X = encoding.EncodeToString(key.Bytes)
+		return jwk, nil
+	}
+	x, y, err := key.Point()
+	if err != nil {
+		return nil, err
+	}
+	jwk.X = encoding.EncodeToString(x.FillBytes(make([]byte, curve.size)))
+	jwk.Y = encoding.EncodeToString(y.FillBytes(make([]byte, curve.size)))
+	return jwk, nil
+}
+
+// ToKey converts a JWK into a multicodec public key.
+func ToKey(jwk *resolver.JWK) (*multikey.Key, error) {
+	if jwk == nil {
+		return nil, fmt.Errorf("missing jwk")
+	}
+	if jwk.Kty == "RSA" {
+		return toRSAKey(jwk)
+	}
+	for code, curve := range curves {
+		if curve.kty != jwk.Kty || curve.crv != jwk.Crv {
+			continue
+		}
+		x, err := encoding.DecodeString(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+		key := &multikey.Key{Code: code, Bytes: x}
+		if curve.kty == "EC" {
+			y, err := encoding.DecodeString(jwk.Y)
+			if err != nil {
+				return nil, err
+			}
+			if len(x) != curve.size || len(y) != curve.size {
+				return nil, fmt.Errorf("invalid %s coordinate length", jwk.Crv)
+			}
+			key.Bytes = multikey.Compress(new(big.Int).SetBytes(x), new(big.Int).SetBytes(y), curve.size)
+			// Compression drops y, so make sure it was the right one.
+			_, py, err := key.Point()
+			if err != nil {
+				return nil, err
+			}
+			if py.Cmp(new(big.Int).SetBytes(y)) != 0 {
+				return nil, fmt.Errorf("invalid %s point: not on curve", jwk.Crv)
+			}
+		}
+		if err := key.Validate(); err != nil {
+			return nil, err
+		}
+		return key, nil
+	}
+	return nil, fmt.Errorf("unsupported jwk: kty '%s', crv '%s'", jwk.Kty, jwk.Crv)
+}
+
+// fromRSAKey converts a DER-encoded PKCS#1 RSA public key into a JWK.
+// See https://tools.ietf.org/html/rfc7518#section-6.3.1
+func fromRSAKey(bytes []byte) (*resolver.JWK, error) {
+	key, err := x509.ParsePKCS1PublicKey(bytes)
+	if err != nil {
+		return nil, resolver.NewError(resolver.InvalidPublicKey, "invalid rsa public key: %v", err)
+	}
+	if key.N.BitLen() < multikey.MinRSAModulusSize {
+		return nil, resolver.NewError(resolver.InvalidPublicKey, "rsa modulus too small: %d bits", key.N.BitLen())
+	}
+	return &resolver.JWK{
+		Kty: "RSA",
+		N:   encoding.EncodeToString(key.N.Bytes()),
+		E:   encoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	}, nil
+}
+
+// toRSAKey converts an RSA JWK into a DER-encoded PKCS#1 RSA public key. The
+// modulus must be at least multikey.MinRSAModulusSize bits.
+func toRSAKey(jwk *resolver.JWK) (*multikey.Key, error) {
+	n, err := encoding.DecodeString(jwk.N)
+	if err != nil {
+		return nil, err
+	}
+	e, err := encoding.DecodeString(jwk.E)
+	if err != nil {
+		return nil, err
+	}
+	exponent := new(big.Int).SetBytes(e)
+	if len(n) == 0 || exponent.Cmp(big.NewInt(1)) <= 0 || exponent.BitLen() > 31 {
+		return nil, resolver.NewError(resolver.InvalidPublicKey, "invalid rsa public key")
+	}
+	key := &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}
+	if size := key.N.BitLen(); size < multikey.MinRSAModulusSize {
+		return nil, resolver.NewError(resolver.InvalidPublicKey, "rsa modulus too small: %d bits", size)
+	}
+	return &multikey.Key{Code: multikey.RSAPub, Bytes: x509.MarshalPKCS1PublicKey(key)}, nil
+}
+
+// FromVerificationMethod extracts the key material of a verification method
+// as a JWK. Keys may be given as publicKeyJwk, as a multicodec prefixed
+// publicKeyMultibase, or as the raw publicKeyBase58 or publicKeyMultibase of
+// a known verification method type.
+func FromVerificationMethod(vm resolver.VerificationMethod) (*resolver.JWK, error) {
+	if vm.PublicKeyJwk != nil {
+		return vm.PublicKeyJwk, nil
+	}
+	encoded := vm.PublicKeyMultibase
+	if vm.PublicKeyBase58 != "" {
+		encoded = "z" + vm.PublicKeyBase58
+	}
+	if encoded == "" {
+		return nil, fmt.Errorf("verification method has no key material: '%s'", vm.ID)
+	}
+	_, bytes, err := mbase.Decode(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if code, ok := rawKeyTypes[vm.Type]; ok {
+		return FromKey(&multikey.Key{Code: code, Bytes: bytes})
+	}
+	key, err := multikey.FromBytes(bytes)
+	if err != nil {
+		return nil, err
+	}
+	return FromKey(key)
+}
+
+// ToVerificationMethod creates a Multikey verification method from a JWK.
+func ToVerificationMethod(jwk *resolver.JWK, id, controller string) (resolver.VerificationMethod, error) {
+	key, err := ToKey(jwk)
+	if err != nil {
+		return resolver.VerificationMethod{}, err
+	}
+	encoded, err := key.Encode()
+	if err != nil {
+		return resolver.VerificationMethod{}, err
+	}
+	return resolver.VerificationMethod{
+		ID:                 id,
+		Type:               "Multikey",
+		Controller:         controller,
+		PublicKeyMultibase: encoded,
+	}, nil
+}
+
+// Thumbprint computes the base64url encoded SHA-256 JWK thumbprint of jwk.
+// See https://tools.ietf.org/html/rfc7638
+func Thumbprint(jwk *resolver.JWK) (string, error) {
+	if jwk == nil {
+		return "", fmt.Errorf("missing jwk")
+	}
+	var members interface{}
+	// The required members, in lexicographic order
+	switch jwk.Kty {
+	case "EC":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+			Y   string `json:"y"`
+		}{jwk.Crv, jwk.Kty, jwk.X, jwk.Y}
+	case "OKP":
+		members = struct {
+			Crv string `json:"crv"`
+			Kty string `json:"kty"`
+			X   string `json:"x"`
+		}{jwk.Crv, jwk.Kty, jwk.X}
+	case "RSA":
+		members = struct {
+			E   string `json:"e"`
+			Kty string `json:"kty"`
+			N   string `json:"n"`
+		}{jwk.E, jwk.Kty, jwk.N}
+	default:
+		return "", fmt.Errorf("unsupported jwk key type: '%s'", jwk.Kty)
+	}
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return encoding.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,206 @@
+// Package jwk provides tools for converting did verification method key
+// material to and from JSON Web Keys: https://tools.ietf.org/html/rfc7517,
+// and for resolving the did:jwk method format that embeds them.
+// Copyright 2021 Textile
+package jwk
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"reflect"
+	"testing"
+
+	codec "github.com/multiformats/go-multicodec"
+	"github.com/textileio/go-did-resolver/multikey"
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+func TestRoundTrip(t *testing.T) {
+	fingerprints := []string{
+		"z6MktvqCyLxTsXUH1tUZncNdVeEZ7hNh7npPRbUU27GTrYb8",
+		"z6LSnkZe3JZPCo88XsdQVJi8j1TomzX2yRW7ZnvvWhmrSdmG",
+		"zQ3shbgnTGcgBpXPdBjDur3ATMDWhS7aPs6FRFkWR19Lb9Zwz",
+	}
+	nist := map[codec.Code]elliptic.Curve{
+		codec.P256Pub: elliptic.P256(),
+		codec.P384Pub: elliptic.P384(),
+		codec.P521Pub: elliptic.P521(),
+	}
+	for code, curve := range nist {
+		private, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		key := &multikey.Key{
+			Code:  code,
+			Bytes: elliptic.MarshalCompressed(curve, private.X, private.Y),
+		}
+		encoded, err := key.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		fingerprints = append(fingerprints, encoded)
+	}
+	for _, fingerprint := range fingerprints {
+		key, err := multikey.Decode(fingerprint)
+		if err != nil {
+			t.Fatal(err)
+		}
+		jwk, err := FromKey(key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		observed, err := ToKey(jwk)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(observed, key) {
+			t.Errorf("expected round trip to match: %s", fingerprint)
+		}
+	}
+}
+
+func TestRSARoundTrip(t *testing.T) {
+	private, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := &multikey.Key{Code: multikey.RSAPub, Bytes: x509.MarshalPKCS1PublicKey(&private.PublicKey)}
+	jwk, err := FromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jwk.Kty != "RSA" || jwk.N != encoding.EncodeToString(private.N.Bytes()) || jwk.E != "AQAB" {
+		t.Errorf("unexpected jwk: %+v", jwk)
+	}
+	observed, err := ToKey(jwk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(observed, key) {
+		t.Error("expected round trip to match")
+	}
+
+	small, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key = &multikey.Key{Code: multikey.RSAPub, Bytes: x509.MarshalPKCS1PublicKey(&small.PublicKey)}
+	if _, err := FromKey(key); err == nil || err.Error() != "rsa modulus too small: 1024 bits" {
+		t.Errorf("expected FromKey to return error, got: %v", err)
+	}
+	if _, err := ToKey(&resolver.JWK{Kty: "RSA", N: jwk.N, E: "AQ"}); err == nil || err.Error() != "invalid rsa public key" {
+		t.Errorf("expected ToKey to return error, got: %v", err)
+	}
+}
+
+func TestP256Coordinates(t *testing.T) {
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := &multikey.Key{
+		Code:  codec.P256Pub,
+		Bytes: elliptic.MarshalCompressed(elliptic.P256(), p256.X, p256.Y),
+	}
+	jwk, err := FromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if jwk.X != base64.RawURLEncoding.EncodeToString(p256.X.FillBytes(make([]byte, 32))) ||
+		jwk.Y != base64.RawURLEncoding.EncodeToString(p256.Y.FillBytes(make([]byte, 32))) {
+		t.Error("expected coordinates to match")
+	}
+}
+
+func TestFromVerificationMethod(t *testing.T) {
+	did := "did:key:z6MktvqCyLxTsXUH1tUZncNdVeEZ7hNh7npPRbUU27GTrYb8"
+	expected := &resolver.JWK{
+		Kty: "OKP",
+		Crv: "Ed25519",
+		X:   "1xPLf4Yk2GSEluAQEPK9cvDcu97NtwNvOMIEdfX0Kb8",
+	}
+	methods := []resolver.VerificationMethod{
+		{
+			ID:              did + "#key",
+			Type:            "Ed25519VerificationKey2018",
+			PublicKeyBase58: "FUaAP6i2XyyouPds73QneYgZJ86qhua2jaZYBqJSwKok",
+		},
+		{
+			ID:                 did + "#key",
+			Type:               "Ed25519VerificationKey2018",
+			PublicKeyMultibase: "zFUaAP6i2XyyouPds73QneYgZJ86qhua2jaZYBqJSwKok",
+		},
+		{
+			ID:                 did + "#key",
+			Type:               "Multikey",
+			PublicKeyMultibase: "z6MktvqCyLxTsXUH1tUZncNdVeEZ7hNh7npPRbUU27GTrYb8",
+		},
+		{
+			ID:           did + "#key",
+			Type:         "JsonWebKey2020",
+			PublicKeyJwk: expected,
+		},
+	}
+	for _, vm := range methods {
+		observed, err := FromVerificationMethod(vm)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(observed, expected) {
+			t.Errorf("expected jwk to match for: %s", vm.Type)
+		}
+	}
+	vm, err := ToVerificationMethod(expected, did+"#key", did)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vm.PublicKeyMultibase != "z6MktvqCyLxTsXUH1tUZncNdVeEZ7hNh7npPRbUU27GTrYb8" {
+		t.Errorf("unexpected publicKeyMultibase: %s", vm.PublicKeyMultibase)
+	}
+}
+
+// TestThumbprint checks against the example in RFC 7638, section 3.1.
+func TestThumbprint(t *testing.T) {
+	jwk := &resolver.JWK{
+		Kty: "RSA",
+		N: "0vx7agoebGcQSuuPiLJXZptN9nndrQmbXEps2aiAFbWhM78LhWx4cbbfAAtVT86zwu1RK7aPFFxuhDR1L6tSoc_BJECPebWKRXjBZCiFV4n3oknjhMs" +
+			"tn64tZ_2W-5JsGY4Hc5n9yBXArwl93lqt7_RN5w6Cf0h4QyQ5v-65YGjQR0_FDW2QvzqY368QQMicAtaSqzs8KJZgnYb9c7d0zgdAZHzu6qMQvRL5hajrn" +
+			"1n91CbOpbISD08qNLyrdkt-bFTWhAI4vMQFh6WeZu0fM4lFd2NcRwr3XPksINHaQ-G_xBniIqbw0Ls1jF44-csFCur-kEgU8awapJzKnqDKgw",
+		E: "AQAB",
+	}
+	observed, err := Thumbprint(jwk)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if observed != "NzbLsXh8uDCcd-6MNwXF4W_7noWXFZAfHkxZsRGC9Xs" {
+		t.Errorf("unexpected thumbprint: %s", observed)
+	}
+	if _, err := Thumbprint(nil); err == nil || err.Error() != "missing jwk" {
+		t.Error("expected Thumbprint to return error")
+	}
+}
+
+func TestInvalidJWK(t *testing.T) {
+	_, err := ToKey(&resolver.JWK{Kty: "OKP", Crv: "Ed448"})
+	if err == nil || err.Error() != "unsupported jwk: kty 'OKP', crv 'Ed448'" {
+		t.Error("expected ToKey to return error")
+	}
+	key, err := multikey.Decode("zQ3shbgnTGcgBpXPdBjDur3ATMDWhS7aPs6FRFkWR19Lb9Zwz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwk, err := FromKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Flip the parity of y, which is then no longer on the curve
+	jwk.Y = jwk.X
+	if _, err := ToKey(jwk); err == nil {
+		t.Error("expected ToKey to return error")
+	}
+}
@@ -0,0 +1,93 @@
+// Package jwk provides tools for converting did verification method key
+// material to and from JSON Web Keys: https://tools.ietf.org/html/rfc7517,
+// and for resolving the did:jwk method format that embeds them.
+// Copyright 2021 Textile
+package jwk
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ockam-network/did"
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+// Resolver resolves the did:jwk method, for dids that embed a public JWK:
+// https://github.com/quartzjer/did-jwk/blob/main/spec.md
+type Resolver struct{}
+
+// New creates and returns a new jwk Resolver.
+func New() *Resolver {
+	return &Resolver{}
+}
+
+// Method returns the method that this resolver is capable of resolving.
+func (r *Resolver) Method() string {
+	return "jwk"
+}
+
+// Resolve is the primary resolution method for this resolver.
+func (r *Resolver) Resolve(did string, parsed *did.DID, res resolver.Resolver) (*resolver.Document, error) {
+	if parsed.Method != r.Method() {
+		return nil, fmt.Errorf("unknown did method: '%s'", parsed.Method)
+	}
+	data, err := encoding.DecodeString(parsed.ID)
+	if err != nil {
+		return nil, err
+	}
+	var members map[string]interface{}
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+	if _, ok := members["d"]; ok {
+		return nil, resolver.NewError(resolver.InvalidPublicKey, "jwk must not contain a private key")
+	}
+	var jwk resolver.JWK
+	if err := json.Unmarshal(data, &jwk); err != nil {
+		return nil, err
+	}
+	if err := validate(&jwk); err != nil {
+		return nil, err
+	}
+	id := fmt.Sprintf("did:jwk:%s", parsed.ID)
+	keyID := id + "#0"
+	doc := &resolver.Document{
+		Context: []string{
+			"https://www.w3.org/ns/did/v1",
+			"https://w3id.org/security/suites/jws-2020/v1",
+		},
+		ID: id,
+		VerificationMethod: []resolver.VerificationMethod{
+			{
+				ID:           keyID,
+				Type:         "JsonWebKey2020",
+				Controller:   id,
+				PublicKeyJwk: &jwk,
+			},
+		},
+	}
+	ref := []resolver.VerificationMethod{resolver.Reference(keyID)}
+	// X25519 keys can only be used for encryption.
+	if jwk.Use != "enc" && jwk.Crv != "X25519" {
+		doc.Authentication = ref
+		doc.AssertionMethod = ref
+		doc.CapabilityInvocation = ref
+		doc.CapabilityDelegation = ref
+	}
+	if jwk.Use != "sig" && jwk.Crv != "Ed25519" {
+		doc.KeyAgreement = ref
+	}
+	return doc, nil
+}
+
+// validate checks that jwk is a well formed public key. RSA keys must have a
+// modulus of at least multikey.MinRSAModulusSize bits.
+func validate(jwk *resolver.JWK) error {
+	if jwk.Use != "" && jwk.Use != "sig" && jwk.Use != "enc" {
+		return resolver.NewError(resolver.InvalidPublicKey, "unknown jwk use: '%s'", jwk.Use)
+	}
+	_, err := ToKey(jwk)
+	return err
+}
+
+var _ resolver.Resolver = (*Resolver)(nil)
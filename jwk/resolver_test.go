@@ -0,0 +1,188 @@
+// Package jwk provides tools for converting did verification method key
+// material to and from JSON Web Keys: https://tools.ietf.org/html/rfc7517,
+// and for resolving the did:jwk method format that embeds them.
+// Copyright 2021 Textile
+package jwk
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"math/big"
+	"reflect"
+	"testing"
+
+	"github.com/ockam-network/did"
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+func TestUnknownMethod(t *testing.T) {
+	id := "did:borg:eyJrdHkiOiJPS1AifQ"
+
+	parsed, err := did.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	_, err = r.Resolve(id, parsed, r)
+	if err == nil || err.Error() != "unknown did method: 'borg'" {
+		t.Error("expected Resolve to return error")
+	}
+}
+
+// TestResolveP256 resolves the P-256 example from the did:jwk spec.
+func TestResolveP256(t *testing.T) {
+	id := "did:jwk:eyJjcnYiOiJQLTI1NiIsImt0eSI6IkVDIiwieCI6ImFjYklRaXVNczNpOF91c3pFakoydHBUdFJNNEVVM3l6OTFQSDZDZEgyVjAiLCJ5IjoiX0tjeUxqOXZXTXB0bm1LdG00NkdxRHo4d2Y3NEk1TEtncmwyR3pIM25TRSJ9"
+
+	parsed, err := did.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	observed, err := r.Resolve(id, parsed, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := []resolver.VerificationMethod{resolver.Reference(id + "#0")}
+	expected := &resolver.Document{
+		Context: []string{
+			"https://www.w3.org/ns/did/v1",
+			"https://w3id.org/security/suites/jws-2020/v1",
+		},
+		ID: id,
+		VerificationMethod: []resolver.VerificationMethod{
+			{
+				ID:         id + "#0",
+				Type:       "JsonWebKey2020",
+				Controller: id,
+				PublicKeyJwk: &resolver.JWK{
+					Kty: "EC",
+					Crv: "P-256",
+					X:   "acbIQiuMs3i8_uszEjJ2tpTtRM4EU3yz91PH6CdH2V0",
+					Y:   "_KcyLj9vWMptnmKtm46GqDz8wf74I5LKgrl2GzH3nSE",
+				},
+			},
+		},
+		Authentication:       ref,
+		AssertionMethod:      ref,
+		CapabilityInvocation: ref,
+		CapabilityDelegation: ref,
+		KeyAgreement:         ref,
+	}
+	if !reflect.DeepEqual(observed, expected) {
+		t.Errorf("expected document to match: %s", expected.ID)
+	}
+}
+
+// TestResolveX25519 resolves the encryption only example from the did:jwk
+// spec.
+func TestResolveX25519(t *testing.T) {
+	id := "did:jwk:eyJrdHkiOiJPS1AiLCJjcnYiOiJYMjU1MTkiLCJ1c2UiOiJlbmMiLCJ4IjoiM3A3YmZYdDl3YlRUVzJIQzdPUTFOei1EUThoYmVHZE5yZngtRkctSUswOCJ9"
+
+	parsed, err := did.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	observed, err := r.Resolve(id, parsed, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(observed.KeyAgreement) != 1 || observed.KeyAgreement[0].ID != id+"#0" {
+		t.Error("expected key agreement relationship")
+	}
+	if len(observed.Authentication) != 0 || len(observed.AssertionMethod) != 0 {
+		t.Error("expected no signing relationships")
+	}
+}
+
+func TestPrivateKey(t *testing.T) {
+	// {"kty":"OKP","crv":"Ed25519","x":"...","d":"..."}
+	id := "did:jwk:eyJrdHkiOiJPS1AiLCJjcnYiOiJFZDI1NTE5IiwieCI6IjF4UExmNFlrMkdTRWx1QVFFUEs5Y3ZEY3U5N050d052T01JRWRmWDBLYjgiLCJkIjoiQUFBQSJ9"
+
+	parsed, err := did.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	_, err = r.Resolve(id, parsed, r)
+	if err == nil || err.Error() != "jwk must not contain a private key" {
+		t.Error("expected Resolve to return error")
+	}
+}
+
+func TestInvalidKey(t *testing.T) {
+	// {"kty":"OKP","crv":"Ed25519","x":"AQ"}
+	id := "did:jwk:eyJrdHkiOiJPS1AiLCJjcnYiOiJFZDI1NTE5IiwieCI6IkFRIn0"
+
+	parsed, err := did.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	_, err = r.Resolve(id, parsed, r)
+	if err == nil || err.Error() != "invalid ed25519-pub key length: 1" {
+		t.Error("expected Resolve to return error")
+	}
+}
+
+func TestResolveNISTCurves(t *testing.T) {
+	for _, curve := range []elliptic.Curve{elliptic.P384(), elliptic.P521()} {
+		private, err := ecdsa.GenerateKey(curve, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		size := (curve.Params().BitSize + 7) / 8
+		data, err := json.Marshal(&resolver.JWK{
+			Kty: "EC",
+			Crv: curve.Params().Name,
+			X:   encoding.EncodeToString(private.X.FillBytes(make([]byte, size))),
+			Y:   encoding.EncodeToString(private.Y.FillBytes(make([]byte, size))),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		id := "did:jwk:" + encoding.EncodeToString(data)
+
+		parsed, err := did.Parse(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := New()
+		observed, err := r.Resolve(id, parsed, r)
+		if err != nil {
+			t.Fatalf("%s: %v", curve.Params().Name, err)
+		}
+		if len(observed.AssertionMethod) != 1 || observed.VerificationMethod[0].PublicKeyJwk.Crv != curve.Params().Name {
+			t.Errorf("%s: unexpected document", curve.Params().Name)
+		}
+	}
+}
+
+func TestRSAModulusTooSmall(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(&resolver.JWK{
+		Kty: "RSA",
+		N:   encoding.EncodeToString(key.N.Bytes()),
+		E:   encoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := "did:jwk:" + encoding.EncodeToString(data)
+
+	parsed, err := did.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	_, err = r.Resolve(id, parsed, r)
+	if err == nil || err.Error() != "rsa modulus too small: 1024 bits" {
+		t.Errorf("expected Resolve to return error, got: %v", err)
+	}
+}
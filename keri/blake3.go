@@ -0,0 +1,129 @@
+// Package keri provides tools for resolving the did:keri and did:webs method
+// formats, by verifying the KERI key event log of an autonomic identifier:
+// https://trustoverip.github.io/tswg-keri-specification/
+// Copyright 2021 Textile
+package keri
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// BLAKE3 is the default KERI digest, and is not available in the standard
+// library. Only the 256 bit hash mode is needed.
+// See https://github.com/BLAKE3-team/BLAKE3-specs
+const (
+	blake3ChunkLen   = 1024
+	blake3BlockLen   = 64
+	blake3ChunkStart = 1 << 0
+	blake3ChunkEnd   = 1 << 1
+	blake3Parent     = 1 << 2
+	blake3Root       = 1 << 3
+)
+
+var blake3IV = [8]uint32{
+	0x6a09e667, 0xbb67ae85, 0x3c6ef372, 0xa54ff53a,
+	0x510e527f, 0x9b05688c, 0x1f83d9ab, 0x5be0cd19,
+}
+
+var blake3Permutation = [16]int{2, 6, 3, 10, 7, 0, 4, 13, 1, 11, 12, 5, 9, 14, 15, 8}
+
+// blake3Sum256 returns the 256 bit BLAKE3 hash of data.
+func blake3Sum256(data []byte) [32]byte {
+	cv := blake3Node(data, 0, true)
+	var sum [32]byte
+	for i, word := range cv {
+		binary.LittleEndian.PutUint32(sum[i*4:], word)
+	}
+	return sum
+}
+
+// blake3Node returns the chaining value of a subtree of chunks. The left
+// subtree holds the largest power of two number of chunks that leaves at
+// least one byte for the right subtree.
+func blake3Node(data []byte, counter uint64, root bool) [8]uint32 {
+	if len(data) <= blake3ChunkLen {
+		return blake3Chunk(data, counter, root)
+	}
+	chunks := uint64(len(data)-1) / blake3ChunkLen
+	leftLen := (uint64(1) << (63 - bits.LeadingZeros64(chunks))) * blake3ChunkLen
+	left := blake3Node(data[:leftLen], counter, false)
+	right := blake3Node(data[leftLen:], counter+leftLen/blake3ChunkLen, false)
+	var block [16]uint32
+	copy(block[:8], left[:])
+	copy(block[8:], right[:])
+	flags := uint32(blake3Parent)
+	if root {
+		flags |= blake3Root
+	}
+	return blake3Compress(blake3IV, block, 0, blake3BlockLen, flags)
+}
+
+// blake3Chunk returns the chaining value of a chunk of up to 1024 bytes.
+func blake3Chunk(data []byte, counter uint64, root bool) [8]uint32 {
+	cv := blake3IV
+	blocks := (len(data) + blake3BlockLen - 1) / blake3BlockLen
+	if blocks == 0 {
+		blocks = 1
+	}
+	for i := 0; i < blocks; i++ {
+		var buf [blake3BlockLen]byte
+		n := copy(buf[:], data[i*blake3BlockLen:])
+		var block [16]uint32
+		for j := range block {
+			block[j] = binary.LittleEndian.Uint32(buf[j*4:])
+		}
+		var flags uint32
+		if i == 0 {
+			flags |= blake3ChunkStart
+		}
+		if i == blocks-1 {
+			flags |= blake3ChunkEnd
+			if root {
+				flags |= blake3Root
+			}
+		}
+		cv = blake3Compress(cv, block, counter, uint32(n), flags)
+	}
+	return cv
+}
+
+func blake3Compress(cv [8]uint32, block [16]uint32, counter uint64, blockLen, flags uint32) [8]uint32 {
+	s := [16]uint32{
+		cv[0], cv[1], cv[2], cv[3], cv[4], cv[5], cv[6], cv[7],
+		blake3IV[0], blake3IV[1], blake3IV[2], blake3IV[3],
+		uint32(counter), uint32(counter >> 32), blockLen, flags,
+	}
+	m := block
+	for round := 0; round < 7; round++ {
+		blake3G(&s, 0, 4, 8, 12, m[0], m[1])
+		blake3G(&s, 1, 5, 9, 13, m[2], m[3])
+		blake3G(&s, 2, 6, 10, 14, m[4], m[5])
+		blake3G(&s, 3, 7, 11, 15, m[6], m[7])
+		blake3G(&s, 0, 5, 10, 15, m[8], m[9])
+		blake3G(&s, 1, 6, 11, 12, m[10], m[11])
+		blake3G(&s, 2, 7, 8, 13, m[12], m[13])
+		blake3G(&s, 3, 4, 9, 14, m[14], m[15])
+		var permuted [16]uint32
+		for i, j := range blake3Permutation {
+			permuted[i] = m[j]
+		}
+		m = permuted
+	}
+	var out [8]uint32
+	for i := range out {
+		out[i] = s[i] ^ s[i+8]
+	}
+	return out
+}
+
+func blake3G(s *[16]uint32, a, b, c, d int, x, y uint32) {
+	s[a] += s[b] + x
+	s[d] = bits.RotateLeft32(s[d]^s[a], -16)
+	s[c] += s[d]
+	s[b] = bits.RotateLeft32(s[b]^s[c], -12)
+	s[a] += s[b] + y
+	s[d] = bits.RotateLeft32(s[d]^s[a], -8)
+	s[c] += s[d]
+	s[b] = bits.RotateLeft32(s[b]^s[c], -7)
+}
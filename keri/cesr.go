@@ -0,0 +1,170 @@
+// Package keri provides tools for resolving the did:keri and did:webs method
+// formats, by verifying the KERI key event log of an autonomic identifier:
+// https://trustoverip.github.io/tswg-keri-specification/
+// Copyright 2021 Textile
+package keri
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	codec "github.com/multiformats/go-multicodec"
+	"github.com/textileio/go-did-resolver/multikey"
+	"golang.org/x/crypto/blake2b"
+	"golang.org/x/crypto/blake2s"
+	"golang.org/x/crypto/sha3"
+)
+
+// CESR primitive codes of the keys, digests and signatures that are supported.
+// See https://trustoverip.github.io/tswg-cesr-specification/
+const (
+	codeEd25519N     = "B"
+	codeEd25519      = "D"
+	codeBlake3       = "E"
+	codeBlake2b      = "F"
+	codeBlake2s      = "G"
+	codeSHA3         = "H"
+	codeSHA2         = "I"
+	codeEd25519Sig   = "0B"
+	codeSecp256k1Sig = "0C"
+	codeSecp256k1N   = "1AAA"
+	codeSecp256k1    = "1AAB"
+)
+
+// b64 is the alphabet of CESR codes, indexes and counts.
+const b64 = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789-_"
+
+// digests are the digest functions by code.
+var digests = map[string]func([]byte) []byte{
+	codeBlake3:  func(data []byte) []byte { sum := blake3Sum256(data); return sum[:] },
+	codeBlake2b: func(data []byte) []byte { sum := blake2b.Sum256(data); return sum[:] },
+	codeBlake2s: func(data []byte) []byte { sum := blake2s.Sum256(data); return sum[:] },
+	codeSHA3:    func(data []byte) []byte { sum := sha3.Sum256(data); return sum[:] },
+	codeSHA2:    func(data []byte) []byte { sum := sha256.Sum256(data); return sum[:] },
+}
+
+// matter is a decoded CESR primitive.
+type matter struct {
+	code string
+	raw  []byte
+}
+
+// decodeMatter decodes a qb64 primitive with a code of the given size. The
+// code replaces the leading pad characters of the base64 encoded raw value.
+func decodeMatter(qb64 string, size int) (*matter, error) {
+	if len(qb64) <= size || len(qb64)%4 != 0 {
+		return nil, fmt.Errorf("invalid primitive: '%s'", qb64)
+	}
+	pad := size % 4
+	raw, err := base64.RawURLEncoding.DecodeString(strings.Repeat("A", pad) + qb64[size:])
+	if err != nil {
+		return nil, fmt.Errorf("invalid primitive: '%s'", qb64)
+	}
+	for _, b := range raw[:pad] {
+		if b != 0 {
+			return nil, fmt.Errorf("invalid primitive: '%s'", qb64)
+		}
+	}
+	return &matter{code: qb64[:size], raw: raw[pad:]}, nil
+}
+
+// encodeMatter returns the qb64 encoding of a raw value.
+func encodeMatter(code string, raw []byte) string {
+	pad := len(code) % 4
+	encoded := base64.RawURLEncoding.EncodeToString(append(make([]byte, pad), raw...))
+	return code + encoded[pad:]
+}
+
+// b64Int decodes a base64 encoded index or count.
+func b64Int(str string) (int, error) {
+	n := 0
+	for _, c := range str {
+		i := strings.IndexRune(b64, c)
+		if i < 0 {
+			return 0, fmt.Errorf("invalid base64 integer: '%s'", str)
+		}
+		n = n*64 + i
+	}
+	return n, nil
+}
+
+// decodeKey decodes a qb64 public key, and reports whether it is
+// transferable, i.e. whether it may be rotated.
+func decodeKey(qb64 string) (*multikey.Key, bool, error) {
+	var key multikey.Key
+	var size int
+	var transferable bool
+	switch {
+	case strings.HasPrefix(qb64, codeSecp256k1N), strings.HasPrefix(qb64, codeSecp256k1):
+		key.Code, size, transferable = codec.Secp256k1Pub, 4, strings.HasPrefix(qb64, codeSecp256k1)
+	case strings.HasPrefix(qb64, codeEd25519N), strings.HasPrefix(qb64, codeEd25519):
+		key.Code, size, transferable = codec.Ed25519Pub, 1, strings.HasPrefix(qb64, codeEd25519)
+	default:
+		return nil, false, fmt.Errorf("unsupported key type: '%s'", qb64)
+	}
+	m, err := decodeMatter(qb64, size)
+	if err != nil {
+		return nil, false, err
+	}
+	key.Bytes = m.raw
+	if err := key.Validate(); err != nil {
+		return nil, false, err
+	}
+	return &key, transferable, nil
+}
+
+// digest returns the qb64 digest of data, using the digest function of code.
+func digest(code string, data []byte) (string, error) {
+	sum, ok := digests[code]
+	if !ok {
+		return "", fmt.Errorf("unsupported digest type: '%s'", code)
+	}
+	return encodeMatter(code, sum(data)), nil
+}
+
+// isDigest reports whether a qb64 primitive is a supported digest.
+func isDigest(qb64 string) bool {
+	if len(qb64) != 44 {
+		return false
+	}
+	_, ok := digests[qb64[:1]]
+	return ok
+}
+
+// signature is a signature over an event, with the index of the signing key
+// in the key list of the signer.
+type signature struct {
+	index int
+	raw   []byte
+}
+
+// decodeIndexedSignature decodes a qb64 indexed signature. The code is one
+// character, followed by a one character index.
+func decodeIndexedSignature(qb64 string) (*signature, error) {
+	if len(qb64) != 88 || !strings.Contains("ABCD", qb64[:1]) {
+		return nil, fmt.Errorf("unsupported indexed signature: '%.4s'", qb64)
+	}
+	index, err := b64Int(qb64[1:2])
+	if err != nil {
+		return nil, err
+	}
+	m, err := decodeMatter(qb64, 2)
+	if err != nil {
+		return nil, err
+	}
+	return &signature{index: index, raw: m.raw}, nil
+}
+
+// decodeSignature decodes a qb64 unindexed signature.
+func decodeSignature(qb64 string) ([]byte, error) {
+	if len(qb64) != 88 || (qb64[:2] != codeEd25519Sig && qb64[:2] != codeSecp256k1Sig) {
+		return nil, fmt.Errorf("unsupported signature: '%.4s'", qb64)
+	}
+	m, err := decodeMatter(qb64, 2)
+	if err != nil {
+		return nil, err
+	}
+	return m.raw, nil
+}
@@ -0,0 +1,458 @@
+// Package keri provides tools for resolving the did:keri and did:webs method
+// formats, by verifying the KERI key event log of an autonomic identifier:
+// https://trustoverip.github.io/tswg-keri-specification/
+// Copyright 2021 Textile
+package keri
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+// Key event types.
+const (
+	TypeInception   = "icp"
+	TypeRotation    = "rot"
+	TypeInteraction = "ixn"
+)
+
+// configEstablishmentOnly is the configuration trait that forbids
+// interaction events.
+const configEstablishmentOnly = "EO"
+
+// Event is a KERI key event. Only the members of its type are set.
+type Event struct {
+	Version          string          `json:"v"`
+	Type             string          `json:"t"`
+	SAID             string          `json:"d"`
+	Prefix           string          `json:"i"`
+	Sequence         string          `json:"s"`
+	Prior            string          `json:"p"`
+	KeyThreshold     json.RawMessage `json:"kt"`
+	Keys             []string        `json:"k"`
+	NextThreshold    json.RawMessage `json:"nt"`
+	NextDigests      []string        `json:"n"`
+	WitnessThreshold string          `json:"bt"`
+	Witnesses        []string        `json:"b"`
+	WitnessesRemoved []string        `json:"br"`
+	WitnessesAdded   []string        `json:"ba"`
+	Config           []string        `json:"c"`
+	Anchors          json.RawMessage `json:"a"`
+}
+
+// KeyState is the state of an identifier after its verified key events.
+type KeyState struct {
+	Prefix            string
+	Sequence          int
+	Digest            string
+	Keys              []string
+	NextDigests       []string
+	Witnesses         []string
+	WitnessThreshold  int
+	EstablishmentOnly bool
+	Abandoned         bool
+
+	kt      *threshold
+	nt      *threshold
+	digests []string
+}
+
+// Verify verifies the key event log of the identifier aid, a CESR stream, and
+// returns its current key state. Events of other identifiers and messages that
+// are not key events are ignored. Delegated identifiers are not supported.
+func Verify(aid string, stream []byte) (*KeyState, error) {
+	messages, err := parseStream(stream)
+	if err != nil {
+		return nil, err
+	}
+	var state *KeyState
+	for _, msg := range messages {
+		if msg.event.Prefix != aid {
+			continue
+		}
+		switch msg.event.Type {
+		case "dip", "drt":
+			return nil, fmt.Errorf("delegated identifiers are not supported")
+		case TypeInception, TypeRotation, TypeInteraction:
+		default:
+			continue
+		}
+		if state == nil {
+			state, err = incept(msg)
+		} else {
+			err = state.apply(msg)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	if state == nil {
+		return nil, resolver.NewError(resolver.NotFound, "no inception event for: '%s'", aid)
+	}
+	return state, nil
+}
+
+// incept creates the key state of an inception event.
+func incept(msg message) (*KeyState, error) {
+	e := msg.event
+	if e.Type != TypeInception {
+		return nil, fmt.Errorf("first event is not an inception event: '%s'", e.SAID)
+	}
+	if e.Sequence != "0" {
+		return nil, fmt.Errorf("invalid inception sequence number: '%s'", e.Sequence)
+	}
+	if err := msg.verifySAID(); err != nil {
+		return nil, err
+	}
+	if len(e.Keys) == 0 {
+		return nil, fmt.Errorf("inception event has no keys")
+	}
+	state := &KeyState{
+		Prefix:            e.Prefix,
+		EstablishmentOnly: contains(e.Config, configEstablishmentOnly),
+	}
+	if err := state.establish(e, nil); err != nil {
+		return nil, err
+	}
+	// The prefix is either the event digest, or the single inception key.
+	if isDigest(e.Prefix) {
+		if e.Prefix != e.SAID {
+			return nil, fmt.Errorf("prefix does not match inception event")
+		}
+	} else {
+		if len(e.Keys) != 1 || e.Keys[0] != e.Prefix {
+			return nil, fmt.Errorf("prefix does not match inception key")
+		}
+		_, transferable, err := decodeKey(e.Prefix)
+		if err != nil {
+			return nil, err
+		}
+		if !transferable && len(e.NextDigests) != 0 {
+			return nil, fmt.Errorf("non-transferable prefix has next keys")
+		}
+	}
+	if _, err := verifySignatures(e.Keys, msg.signatures, msg.raw, state.kt); err != nil {
+		return nil, err
+	}
+	if err := msg.verifyReceipts(state.Witnesses, state.WitnessThreshold); err != nil {
+		return nil, err
+	}
+	state.digests = []string{e.SAID}
+	state.Digest = e.SAID
+	return state, nil
+}
+
+// apply verifies a rotation or interaction event, and applies it to the key
+// state.
+func (s *KeyState) apply(msg message) error {
+	e := msg.event
+	sn, err := strconv.ParseUint(e.Sequence, 16, 32)
+	if err != nil {
+		return fmt.Errorf("invalid sequence number: '%s'", e.Sequence)
+	}
+	if int(sn) <= s.Sequence {
+		// A repeated event is ignored, a different one is duplicitous.
+		if s.digests[sn] == e.SAID {
+			return nil
+		}
+		return fmt.Errorf("duplicitous event at sequence number %d", sn)
+	}
+	if int(sn) != s.Sequence+1 {
+		return fmt.Errorf("out of order event at sequence number %d", sn)
+	}
+	if e.Prior != s.Digest {
+		return fmt.Errorf("event prior does not match previous event: '%s'", e.SAID)
+	}
+	if err := msg.verifySAID(); err != nil {
+		return err
+	}
+	switch e.Type {
+	case TypeRotation:
+		if len(s.NextDigests) == 0 {
+			return fmt.Errorf("identifier is not rotatable: '%s'", s.Prefix)
+		}
+		next := *s
+		if err := next.establish(e, s); err != nil {
+			return err
+		}
+		signers, err := verifySignatures(e.Keys, msg.signatures, msg.raw, next.kt)
+		if err != nil {
+			return err
+		}
+		// The signing keys must also satisfy the prior next threshold, by the
+		// position of their digests in the prior next key list.
+		var committed []int
+		for _, i := range signers {
+			for j, d := range s.NextDigests {
+				if h, err := digest(d[:1], []byte(e.Keys[i])); err == nil && h == d {
+					committed = append(committed, j)
+				}
+			}
+		}
+		if !s.nt.satisfied(committed) {
+			return fmt.Errorf("rotation does not satisfy prior next threshold: '%s'", e.SAID)
+		}
+		if err := msg.verifyReceipts(next.Witnesses, next.WitnessThreshold); err != nil {
+			return err
+		}
+		*s = next
+	case TypeInteraction:
+		if s.EstablishmentOnly {
+			return fmt.Errorf("interaction event for establishment only identifier: '%s'", e.SAID)
+		}
+		if _, err := verifySignatures(s.Keys, msg.signatures, msg.raw, s.kt); err != nil {
+			return err
+		}
+		if err := msg.verifyReceipts(s.Witnesses, s.WitnessThreshold); err != nil {
+			return err
+		}
+	}
+	s.Sequence = int(sn)
+	s.Digest = e.SAID
+	s.digests = append(s.digests, e.SAID)
+	return nil
+}
+
+// establish sets the keys, next key digests and witnesses of an inception or
+// rotation event. Rotations update the witnesses of the prior state, and a
+// rotation without next keys abandons the identifier.
+func (s *KeyState) establish(e Event, prior *KeyState) error {
+	for _, key := range e.Keys {
+		if _, transferable, err := decodeKey(key); err != nil {
+			return err
+		} else if !transferable && prior != nil {
+			return fmt.Errorf("rotation to non-transferable key: '%s'", key)
+		}
+	}
+	for _, d := range e.NextDigests {
+		if !isDigest(d) {
+			return fmt.Errorf("invalid next key digest: '%s'", d)
+		}
+	}
+	kt, err := parseThreshold(e.KeyThreshold, len(e.Keys))
+	if err != nil {
+		return err
+	}
+	nt, err := parseThreshold(e.NextThreshold, len(e.NextDigests))
+	if err != nil {
+		return err
+	}
+	witnesses := e.Witnesses
+	if prior != nil {
+		witnesses = nil
+		for _, w := range prior.Witnesses {
+			if !contains(e.WitnessesRemoved, w) {
+				witnesses = append(witnesses, w)
+			}
+		}
+		for _, w := range e.WitnessesRemoved {
+			if !contains(prior.Witnesses, w) {
+				return fmt.Errorf("removed witness is not a witness: '%s'", w)
+			}
+		}
+		witnesses = append(witnesses, e.WitnessesAdded...)
+	}
+	seen := make(map[string]bool)
+	for _, w := range witnesses {
+		if _, transferable, err := decodeKey(w); err != nil || transferable || seen[w] {
+			return fmt.Errorf("invalid witness: '%s'", w)
+		}
+		seen[w] = true
+	}
+	bt, err := strconv.ParseUint(e.WitnessThreshold, 16, 32)
+	if err != nil || int(bt) > len(witnesses) || (bt == 0 && len(witnesses) > 0) {
+		return fmt.Errorf("invalid witness threshold: '%s'", e.WitnessThreshold)
+	}
+	s.Keys = e.Keys
+	s.NextDigests = e.NextDigests
+	s.Witnesses = witnesses
+	s.WitnessThreshold = int(bt)
+	s.Abandoned = prior != nil && len(e.NextDigests) == 0
+	s.kt = kt
+	s.nt = nt
+	return nil
+}
+
+// verifySAID checks that the event digest matches the event, serialized with
+// a dummy digest. The prefix of a self-addressing inception event is also
+// replaced.
+func (m *message) verifySAID() error {
+	e := m.event
+	if !isDigest(e.SAID) {
+		return fmt.Errorf("invalid event digest: '%s'", e.SAID)
+	}
+	dummy := strings.Repeat("#", len(e.SAID))
+	raw := bytes.Replace(m.raw, []byte(`"d":"`+e.SAID+`"`), []byte(`"d":"`+dummy+`"`), 1)
+	if e.Type == TypeInception && e.Prefix == e.SAID {
+		raw = bytes.Replace(raw, []byte(`"i":"`+e.Prefix+`"`), []byte(`"i":"`+dummy+`"`), 1)
+	}
+	d, err := digest(e.SAID[:1], raw)
+	if err != nil {
+		return err
+	}
+	if d != e.SAID {
+		return fmt.Errorf("event digest does not match event: '%s'", e.SAID)
+	}
+	return nil
+}
+
+// verifySignatures checks the indexed signatures over raw, and that the
+// signing keys satisfy the threshold. It returns the indexes of the signing
+// keys.
+func verifySignatures(keys []string, sigs []signature, raw []byte, kt *threshold) ([]int, error) {
+	var signers []int
+	for _, sig := range sigs {
+		if sig.index >= len(keys) {
+			return nil, fmt.Errorf("invalid signature index: %d", sig.index)
+		}
+		key, _, err := decodeKey(keys[sig.index])
+		if err != nil {
+			return nil, err
+		}
+		if err := key.Verify(raw, sig.raw); err != nil {
+			return nil, err
+		}
+		if !containsIndex(signers, sig.index) {
+			signers = append(signers, sig.index)
+		}
+	}
+	if !kt.satisfied(signers) {
+		return nil, fmt.Errorf("event signatures do not satisfy threshold")
+	}
+	return signers, nil
+}
+
+// verifyReceipts checks that enough witnesses have signed the event.
+func (m *message) verifyReceipts(witnesses []string, bt int) error {
+	signed := make(map[string]bool)
+	for _, sig := range m.witnessSignatures {
+		if sig.index >= len(witnesses) {
+			return fmt.Errorf("invalid witness signature index: %d", sig.index)
+		}
+		if err := verifyWitness(witnesses[sig.index], sig.raw, m.raw); err != nil {
+			return err
+		}
+		signed[witnesses[sig.index]] = true
+	}
+	for _, r := range m.receipts {
+		if !contains(witnesses, r.prefix) {
+			continue
+		}
+		if err := verifyWitness(r.prefix, r.sig, m.raw); err != nil {
+			return err
+		}
+		signed[r.prefix] = true
+	}
+	if len(signed) < bt {
+		return fmt.Errorf("insufficient witness receipts: %d of %d", len(signed), bt)
+	}
+	return nil
+}
+
+func verifyWitness(prefix string, sig, raw []byte) error {
+	key, _, err := decodeKey(prefix)
+	if err != nil {
+		return err
+	}
+	if err := key.Verify(raw, sig); err != nil {
+		return fmt.Errorf("invalid witness signature: '%s'", prefix)
+	}
+	return nil
+}
+
+// threshold is a signing threshold, either a number of keys, or clauses of
+// fractionally weighted keys that must each sum to at least one.
+type threshold struct {
+	count   int
+	clauses [][]*big.Rat
+}
+
+// parseThreshold decodes a hex or weighted threshold for a list of keys.
+func parseThreshold(raw json.RawMessage, keys int) (*threshold, error) {
+	var hex string
+	if err := json.Unmarshal(raw, &hex); err == nil {
+		n, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil || int(n) > keys || (n == 0 && keys > 0) {
+			return nil, fmt.Errorf("invalid threshold: '%s'", hex)
+		}
+		return &threshold{count: int(n)}, nil
+	}
+	var clauses [][]string
+	if err := json.Unmarshal(raw, &clauses); err != nil {
+		var clause []string
+		if err := json.Unmarshal(raw, &clause); err != nil {
+			return nil, fmt.Errorf("invalid threshold: '%s'", raw)
+		}
+		clauses = [][]string{clause}
+	}
+	t := &threshold{}
+	total := 0
+	one := big.NewRat(1, 1)
+	for _, clause := range clauses {
+		var weights []*big.Rat
+		sum := new(big.Rat)
+		for _, w := range clause {
+			weight, ok := new(big.Rat).SetString(w)
+			if !ok || weight.Sign() < 0 || weight.Cmp(one) > 0 {
+				return nil, fmt.Errorf("invalid threshold weight: '%s'", w)
+			}
+			weights = append(weights, weight)
+			sum.Add(sum, weight)
+		}
+		if sum.Cmp(one) < 0 {
+			return nil, fmt.Errorf("unsatisfiable threshold: '%s'", raw)
+		}
+		t.clauses = append(t.clauses, weights)
+		total += len(clause)
+	}
+	if total != keys {
+		return nil, fmt.Errorf("threshold does not match key count: '%s'", raw)
+	}
+	return t, nil
+}
+
+// satisfied reports whether the keys at the indexes satisfy the threshold.
+func (t *threshold) satisfied(indexes []int) bool {
+	if t.clauses == nil {
+		return len(indexes) >= t.count
+	}
+	offset := 0
+	one := big.NewRat(1, 1)
+	for _, clause := range t.clauses {
+		sum := new(big.Rat)
+		for i, weight := range clause {
+			if containsIndex(indexes, offset+i) {
+				sum.Add(sum, weight)
+			}
+		}
+		if sum.Cmp(one) < 0 {
+			return false
+		}
+		offset += len(clause)
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func containsIndex(values []int, value int) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,238 @@
+// Package keri provides tools for resolving the did:keri and did:webs method
+// formats, by verifying the KERI key event log of an autonomic identifier:
+// https://trustoverip.github.io/tswg-keri-specification/
+// Copyright 2021 Textile
+package keri
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ockam-network/did"
+	"github.com/textileio/go-did-resolver/resolver"
+	"github.com/textileio/go-did-resolver/web"
+)
+
+// Source provides the key event logs of identifiers.
+type Source interface {
+	// KEL returns the key event log of the identifier aid, as a CESR stream.
+	KEL(aid string) ([]byte, error)
+}
+
+// SourceFunc adapts a function to a Source.
+type SourceFunc func(aid string) ([]byte, error)
+
+// KEL calls f(aid).
+func (f SourceFunc) KEL(aid string) ([]byte, error) {
+	return f(aid)
+}
+
+// Dir returns a Source that reads the log of an identifier from the file
+// named {aid}.cesr in dir.
+func Dir(dir string) Source {
+	return SourceFunc(func(aid string) ([]byte, error) {
+		data, err := ioutil.ReadFile(filepath.Join(dir, filepath.Base(aid)+".cesr"))
+		if os.IsNotExist(err) {
+			return nil, resolver.NewError(resolver.NotFound, "key event log not found: '%s'", aid)
+		}
+		return data, err
+	})
+}
+
+// OOBI returns a Source that fetches the log of an identifier from the
+// out-of-band introduction endpoint {base}/oobi/{aid} of a witness or agent,
+// using a web.Resolver configured by opts.
+func OOBI(base string, opts ...web.Option) Source {
+	base = strings.TrimSuffix(base, "/")
+	client := web.New(opts...)
+	return SourceFunc(func(aid string) ([]byte, error) {
+		return fetch(client, base+"/oobi/"+aid)
+	})
+}
+
+// fetch gets the key event log at location.
+func fetch(client *web.Resolver, location string) ([]byte, error) {
+	resp, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Body == nil {
+		return nil, fmt.Errorf("empty key event log")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, resolver.NewError(resolver.NotFound, "key event log not found: '%s'", location)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to resolve: '%s'", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// Resolver resolves did:keri identifiers from the key event logs of a Source.
+type Resolver struct {
+	source Source
+}
+
+// New creates and returns a new keri Resolver for the logs of source.
+func New(source Source) *Resolver {
+	return &Resolver{source: source}
+}
+
+// Method returns the method that this resolver is capable of resolving.
+func (r *Resolver) Method() string {
+	return "keri"
+}
+
+// Resolve is the primary resolution method for this resolver.
+func (r *Resolver) Resolve(did string, parsed *did.DID, res resolver.Resolver) (*resolver.Document, error) {
+	doc, _, err := r.ResolveWithMetadata(did, parsed, res, nil)
+	return doc, err
+}
+
+// ResolveWithMetadata resolves the did from the current key state of its
+// verified key event log.
+func (r *Resolver) ResolveWithMetadata(did string, parsed *did.DID, res resolver.Resolver, options *resolver.ResolutionOptions) (*resolver.Document, resolver.DocumentMetadata, error) {
+	if parsed.Method != r.Method() {
+		return nil, resolver.DocumentMetadata{}, fmt.Errorf("unknown did method: '%s'", parsed.Method)
+	}
+	if len(parsed.IDStrings) != 1 || !isPrefix(parsed.ID) {
+		return nil, resolver.DocumentMetadata{}, fmt.Errorf("invalid did:keri identifier: '%s'", parsed.ID)
+	}
+	stream, err := r.source.KEL(parsed.ID)
+	if err != nil {
+		return nil, resolver.DocumentMetadata{}, err
+	}
+	state, err := Verify(parsed.ID, stream)
+	if err != nil {
+		return nil, resolver.DocumentMetadata{}, err
+	}
+	doc, err := state.document("did:keri:" + parsed.ID)
+	if err != nil {
+		return nil, resolver.DocumentMetadata{}, err
+	}
+	return doc, state.metadata(), nil
+}
+
+// WebsResolver resolves did:webs identifiers, from the key event log at
+// keri.cesr next to the did.json of the corresponding did:web, fetched with a
+// web.Resolver. The key event log is authoritative, so did.json is not used.
+type WebsResolver struct {
+	web *web.Resolver
+}
+
+// NewWebs creates and returns a new webs Resolver, whose key event logs are
+// fetched with a web.Resolver configured by opts.
+func NewWebs(opts ...web.Option) *WebsResolver {
+	return &WebsResolver{web: web.New(opts...)}
+}
+
+// Method returns the method that this resolver is capable of resolving.
+func (r *WebsResolver) Method() string {
+	return "webs"
+}
+
+// Resolve is the primary resolution method for this resolver.
+func (r *WebsResolver) Resolve(did string, parsed *did.DID, res resolver.Resolver) (*resolver.Document, error) {
+	doc, _, err := r.ResolveWithMetadata(did, parsed, res, nil)
+	return doc, err
+}
+
+// ResolveWithMetadata resolves the did from the current key state of its
+// verified key event log. The did:keri of the identifier is an equivalent id.
+// For a did did:webs:example.com:dids:{aid}, the log is fetched from
+// https://example.com/dids/{aid}/keri.cesr
+func (r *WebsResolver) ResolveWithMetadata(did string, parsed *did.DID, res resolver.Resolver, options *resolver.ResolutionOptions) (*resolver.Document, resolver.DocumentMetadata, error) {
+	if parsed.Method != r.Method() {
+		return nil, resolver.DocumentMetadata{}, fmt.Errorf("unknown did method: '%s'", parsed.Method)
+	}
+	ids := parsed.IDStrings
+	if len(ids) < 2 || !isPrefix(ids[len(ids)-1]) {
+		return nil, resolver.DocumentMetadata{}, fmt.Errorf("invalid did:webs identifier: '%s'", parsed.ID)
+	}
+	aid := ids[len(ids)-1]
+	location, err := web.URL(ids, "keri.cesr")
+	if err != nil {
+		return nil, resolver.DocumentMetadata{}, err
+	}
+	stream, err := fetch(r.web, location)
+	if err != nil {
+		return nil, resolver.DocumentMetadata{}, err
+	}
+	state, err := Verify(aid, stream)
+	if err != nil {
+		return nil, resolver.DocumentMetadata{}, err
+	}
+	id := "did:webs:" + parsed.ID
+	doc, err := state.document(id)
+	if err != nil {
+		return nil, resolver.DocumentMetadata{}, err
+	}
+	doc.AlsoKnownAs = []string{"did:keri:" + aid, "did:web:" + parsed.ID}
+	metadata := state.metadata()
+	metadata.EquivalentID = []string{"did:keri:" + aid}
+	return doc, metadata, nil
+}
+
+// isPrefix reports whether str looks like a self-addressing or basic prefix.
+func isPrefix(str string) bool {
+	if isDigest(str) {
+		return true
+	}
+	_, _, err := decodeKey(str)
+	return err == nil
+}
+
+// document creates the did document for the key state. Each current key is a
+// verification method, and is also used for authentication and assertions
+// when it satisfies the signing threshold alone.
+func (s *KeyState) document(did string) (*resolver.Document, error) {
+	doc := &resolver.Document{
+		Context: []string{
+			"https://www.w3.org/ns/did/v1",
+			"https://w3id.org/security/multikey/v1",
+		},
+		ID: did,
+	}
+	for i, qb64 := range s.Keys {
+		key, _, err := decodeKey(qb64)
+		if err != nil {
+			return nil, err
+		}
+		encoded, err := key.Encode()
+		if err != nil {
+			return nil, err
+		}
+		id := did + "#" + qb64
+		doc.VerificationMethod = append(doc.VerificationMethod, resolver.VerificationMethod{
+			ID:                 id,
+			Type:               "Multikey",
+			Controller:         did,
+			PublicKeyMultibase: encoded,
+		})
+		if s.kt.satisfied([]int{i}) {
+			doc.Authentication = append(doc.Authentication, resolver.Reference(id))
+			doc.AssertionMethod = append(doc.AssertionMethod, resolver.Reference(id))
+		}
+	}
+	return doc, nil
+}
+
+// metadata returns the document metadata of the key state. An identifier
+// that can no longer be rotated is deactivated.
+func (s *KeyState) metadata() resolver.DocumentMetadata {
+	return resolver.DocumentMetadata{
+		VersionID:   strconv.Itoa(s.Sequence),
+		Deactivated: s.Abandoned,
+	}
+}
+
+var (
+	_ resolver.MetadataResolver = (*Resolver)(nil)
+	_ resolver.MetadataResolver = (*WebsResolver)(nil)
+)
@@ -0,0 +1,489 @@
+// Package keri provides tools for resolving the did:keri and did:webs method
+// formats, by verifying the KERI key event log of an autonomic identifier:
+// https://trustoverip.github.io/tswg-keri-specification/
+// Copyright 2021 Textile
+package keri
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/textileio/go-did-resolver/resolver"
+	"github.com/textileio/go-did-resolver/web"
+)
+
+// MockClient serves files by url.
+type MockClient struct {
+	files map[string][]byte
+}
+
+// Get is the mock client's `Get` func
+func (m *MockClient) Get(url string) (*http.Response, error) {
+	body, ok := m.files[url]
+	if !ok {
+		return &http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found"}, nil
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(bytes.NewReader(body)),
+	}, nil
+}
+
+// signer is an ed25519 controller or witness key.
+type signer struct {
+	private ed25519.PrivateKey
+	qb64    string
+}
+
+func newSigner(t *testing.T, code string) *signer {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &signer{private, encodeMatter(code, public)}
+}
+
+// next returns the pre-rotation digest of the key.
+func (s *signer) next() string {
+	d, _ := digest(codeBlake3, []byte(s.qb64))
+	return d
+}
+
+// indexed returns an indexed signature of raw.
+func (s *signer) indexed(index int, raw []byte) string {
+	return encodeMatter("A"+b64[index:index+1], ed25519.Sign(s.private, raw))
+}
+
+// counter returns a count code for n items.
+func counter(code string, n int) string {
+	return code + b64[n/64:n/64+1] + b64[n%64:n%64+1]
+}
+
+type inception struct {
+	V  string        `json:"v"`
+	T  string        `json:"t"`
+	D  string        `json:"d"`
+	I  string        `json:"i"`
+	S  string        `json:"s"`
+	Kt interface{}   `json:"kt"`
+	K  []string      `json:"k"`
+	Nt interface{}   `json:"nt"`
+	N  []string      `json:"n"`
+	Bt string        `json:"bt"`
+	B  []string      `json:"b"`
+	C  []string      `json:"c"`
+	A  []interface{} `json:"a"`
+}
+
+type rotation struct {
+	V  string        `json:"v"`
+	T  string        `json:"t"`
+	D  string        `json:"d"`
+	I  string        `json:"i"`
+	S  string        `json:"s"`
+	P  string        `json:"p"`
+	Kt interface{}   `json:"kt"`
+	K  []string      `json:"k"`
+	Nt interface{}   `json:"nt"`
+	N  []string      `json:"n"`
+	Bt string        `json:"bt"`
+	Br []string      `json:"br"`
+	Ba []string      `json:"ba"`
+	A  []interface{} `json:"a"`
+}
+
+type interaction struct {
+	V string        `json:"v"`
+	T string        `json:"t"`
+	D string        `json:"d"`
+	I string        `json:"i"`
+	S string        `json:"s"`
+	P string        `json:"p"`
+	A []interface{} `json:"a"`
+}
+
+// serialize sets the version string and digest of an event, and the prefix
+// of a self-addressing inception event, and returns its serialization.
+func serialize(t *testing.T, e interface{}, v, d, i *string) []byte {
+	*d = strings.Repeat("#", 44)
+	if i != nil {
+		*i = *d
+	}
+	*v = "KERI10JSON000000_"
+	data, err := json.Marshal(e)
+	if err != nil {
+		t.Fatal(err)
+	}
+	*v = fmt.Sprintf("KERI10JSON%06x_", len(data))
+	data, _ = json.Marshal(e)
+	said, err := digest(codeBlake3, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	*d = said
+	if i != nil {
+		*i = said
+	}
+	data, _ = json.Marshal(e)
+	return data
+}
+
+// kel builds the key event log of an identifier.
+type kel struct {
+	aid    string
+	sn     int
+	said   string
+	stream bytes.Buffer
+}
+
+// add appends an event, signed by the signers at their index, and receipted
+// by the witnesses.
+func (l *kel) add(raw []byte, signers []*signer, witnesses []*signer) {
+	l.stream.Write(raw)
+	var sigs []string
+	for i, s := range signers {
+		if s != nil {
+			sigs = append(sigs, s.indexed(i, raw))
+		}
+	}
+	l.stream.WriteString(counter("-A", len(sigs)) + strings.Join(sigs, ""))
+	if len(witnesses) > 0 {
+		l.stream.WriteString(counter("-C", len(witnesses)))
+		for _, w := range witnesses {
+			l.stream.WriteString(w.qb64 + encodeMatter(codeEd25519Sig, ed25519.Sign(w.private, raw)))
+		}
+	}
+}
+
+func qb64s(signers []*signer, f func(*signer) string) []string {
+	list := []string{}
+	for _, s := range signers {
+		list = append(list, f(s))
+	}
+	return list
+}
+
+func key(s *signer) string  { return s.qb64 }
+func next(s *signer) string { return s.next() }
+
+// newKEL starts a log with single key and next key thresholds.
+func newKEL(t *testing.T, keys, nextKeys, witnesses []*signer, bt string) *kel {
+	e := &inception{
+		T:  TypeInception,
+		S:  "0",
+		Kt: "1",
+		K:  qb64s(keys, key),
+		Nt: "1",
+		N:  qb64s(nextKeys, next),
+		Bt: bt,
+		B:  qb64s(witnesses, key),
+		C:  []string{},
+		A:  []interface{}{},
+	}
+	raw := serialize(t, e, &e.V, &e.D, &e.I)
+	l := &kel{aid: e.I, said: e.D}
+	l.add(raw, keys, witnesses)
+	return l
+}
+
+func (l *kel) rotate(t *testing.T, keys, nextKeys, signers, witnesses []*signer) {
+	l.sn++
+	e := &rotation{
+		T:  TypeRotation,
+		I:  l.aid,
+		S:  fmt.Sprintf("%x", l.sn),
+		P:  l.said,
+		Kt: "1",
+		K:  qb64s(keys, key),
+		Nt: "1",
+		N:  qb64s(nextKeys, next),
+		Bt: "0",
+		Br: []string{},
+		Ba: []string{},
+		A:  []interface{}{},
+	}
+	if len(nextKeys) == 0 {
+		e.Nt = "0"
+	}
+	if len(witnesses) > 0 {
+		e.Bt = "1"
+	}
+	raw := serialize(t, e, &e.V, &e.D, nil)
+	l.said = e.D
+	l.add(raw, signers, witnesses)
+}
+
+func (l *kel) interact(t *testing.T, signers, witnesses []*signer) {
+	l.sn++
+	e := &interaction{
+		T: TypeInteraction,
+		I: l.aid,
+		S: fmt.Sprintf("%x", l.sn),
+		P: l.said,
+		A: []interface{}{},
+	}
+	raw := serialize(t, e, &e.V, &e.D, nil)
+	l.said = e.D
+	l.add(raw, signers, witnesses)
+}
+
+func resolve(t *testing.T, l *kel) (*resolver.Document, resolver.DocumentMetadata, error) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, l.aid+".cesr"), l.stream.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	id := "did:keri:" + l.aid
+	parsed, err := resolver.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New(Dir(dir))
+	return r.ResolveWithMetadata(id, parsed, r, nil)
+}
+
+func TestBlake3(t *testing.T) {
+	tests := map[int]string{
+		0:    "af1349b9f5f9a1a6a0404dea36dcc9499bcb25c9adc112b7cc9a93cae41f3262",
+		1:    "2d3adedff11b61f14c886e35afa036736dcd87a74d27b5c1510225d0f592e213",
+		1024: "42214739f095a406f3fc83deb889744ac00df831c10daa55189b5d121c855af7",
+		1025: "d00278ae47eb27b34faecf67b4fe263f82d5412916c1ffd97c8cb7fb814b8444",
+		2048: "e776b6028c7cd22a4d0ba182a8bf62205d2ef576467e838ed6f2529b85fba24a",
+	}
+	for n, expected := range tests {
+		data := make([]byte, n)
+		for i := range data {
+			data[i] = byte(i % 251)
+		}
+		sum := blake3Sum256(data)
+		if hex.EncodeToString(sum[:]) != expected {
+			t.Errorf("unexpected hash of %d bytes: %x", n, sum)
+		}
+	}
+}
+
+func TestUnknownMethod(t *testing.T) {
+	id := "did:borg:EKYLUMmNPZeEs77Zvclf0bSN5IN-mLfLpx2ySb-HDlk4"
+	parsed, err := resolver.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New(Dir(t.TempDir()))
+	_, err = r.Resolve(id, parsed, r)
+	if err == nil || err.Error() != "unknown did method: 'borg'" {
+		t.Error("expected Resolve to return error")
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	_, _, err := resolve(t, &kel{aid: "EKYLUMmNPZeEs77Zvclf0bSN5IN-mLfLpx2ySb-HDlk4"})
+	var rerr *resolver.Error
+	if !errors.As(err, &rerr) || rerr.Code != resolver.NotFound {
+		t.Errorf("expected notFound error: %v", err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	k0, k1, k2 := newSigner(t, codeEd25519), newSigner(t, codeEd25519), newSigner(t, codeEd25519)
+	w := []*signer{newSigner(t, codeEd25519N)}
+	l := newKEL(t, []*signer{k0}, []*signer{k1}, w, "1")
+	l.interact(t, []*signer{k0}, w)
+	l.rotate(t, []*signer{k1}, []*signer{k2}, []*signer{k1}, w)
+
+	doc, metadata, err := resolve(t, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := "did:keri:" + l.aid
+	key, _, _ := decodeKey(k1.qb64)
+	encoded, _ := key.Encode()
+	expected := &resolver.Document{
+		Context: []string{
+			"https://www.w3.org/ns/did/v1",
+			"https://w3id.org/security/multikey/v1",
+		},
+		ID: id,
+		VerificationMethod: []resolver.VerificationMethod{
+			{
+				ID:                 id + "#" + k1.qb64,
+				Type:               "Multikey",
+				Controller:         id,
+				PublicKeyMultibase: encoded,
+			},
+		},
+		Authentication:  []resolver.VerificationMethod{resolver.Reference(id + "#" + k1.qb64)},
+		AssertionMethod: []resolver.VerificationMethod{resolver.Reference(id + "#" + k1.qb64)},
+	}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Errorf("unexpected document: %+v", doc)
+	}
+	if metadata.VersionID != "2" || metadata.Deactivated {
+		t.Errorf("unexpected metadata: %+v", metadata)
+	}
+
+	// A rotation without next keys abandons the identifier.
+	l.rotate(t, []*signer{k2}, nil, []*signer{k2}, w)
+	if _, metadata, err = resolve(t, l); err != nil || !metadata.Deactivated {
+		t.Errorf("expected deactivated metadata: %+v %v", metadata, err)
+	}
+}
+
+func TestInvalidLog(t *testing.T) {
+	k0, k1, k2 := newSigner(t, codeEd25519), newSigner(t, codeEd25519), newSigner(t, codeEd25519)
+	w := []*signer{newSigner(t, codeEd25519N)}
+	tests := []struct {
+		name  string
+		build func() *kel
+		err   string
+	}{
+		{"digest", func() *kel {
+			l := newKEL(t, []*signer{k0}, []*signer{k1}, nil, "0")
+			tampered := bytes.Replace(l.stream.Bytes(), []byte(`"bt":"0"`), []byte(`"bt":"1"`), 1)
+			l.stream = *bytes.NewBuffer(tampered)
+			return l
+		}, "event digest does not match event"},
+		{"signature", func() *kel {
+			l := newKEL(t, []*signer{k0}, []*signer{k1}, nil, "0")
+			l.interact(t, []*signer{k1}, nil)
+			return l
+		}, ""},
+		{"threshold", func() *kel {
+			l := newKEL(t, []*signer{k0}, []*signer{k1}, nil, "0")
+			l.interact(t, []*signer{nil}, nil)
+			return l
+		}, "event signatures do not satisfy threshold"},
+		{"pre-rotation", func() *kel {
+			l := newKEL(t, []*signer{k0}, []*signer{k1}, nil, "0")
+			l.rotate(t, []*signer{k2}, []*signer{k0}, []*signer{k2}, nil)
+			return l
+		}, "rotation does not satisfy prior next threshold"},
+		{"witness", func() *kel {
+			return newKEL(t, []*signer{k0}, []*signer{k1}, nil, "1")
+		}, "invalid witness threshold"},
+		{"receipts", func() *kel {
+			l := newKEL(t, []*signer{k0}, []*signer{k1}, w, "1")
+			l.interact(t, []*signer{k0}, nil)
+			return l
+		}, "insufficient witness receipts"},
+		{"duplicity", func() *kel {
+			l := newKEL(t, []*signer{k0}, []*signer{k1}, nil, "0")
+			said := l.said
+			l.interact(t, []*signer{k0}, nil)
+			l.sn, l.said = 0, said
+			l.rotate(t, []*signer{k1}, []*signer{k2}, []*signer{k1}, nil)
+			return l
+		}, "duplicitous event at sequence number 1"},
+	}
+	for _, tt := range tests {
+		_, _, err := resolve(t, tt.build())
+		if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+			t.Errorf("%s: expected Resolve to return error, got: %v", tt.name, err)
+		}
+	}
+}
+
+func TestThreshold(t *testing.T) {
+	kt, err := parseThreshold(json.RawMessage(`[["1/2","1/2","1/2"],["1","1"]]`), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		indexes   []int
+		satisfied bool
+	}{
+		{[]int{0, 1, 3}, true},
+		{[]int{0, 4}, false},
+		{[]int{0, 1, 2}, false},
+		{[]int{1, 2, 4}, true},
+	}
+	for _, tt := range tests {
+		if kt.satisfied(tt.indexes) != tt.satisfied {
+			t.Errorf("unexpected result for %v", tt.indexes)
+		}
+	}
+	for _, raw := range []string{`"3"`, `"0"`, `["1/3","1/3"]`, `["1/2","1/2","1/2"]`, `"x"`} {
+		if _, err := parseThreshold(json.RawMessage(raw), 2); err == nil {
+			t.Errorf("expected parseThreshold to return error for %s", raw)
+		}
+	}
+}
+
+func TestWeightedInception(t *testing.T) {
+	k0, k1, n0 := newSigner(t, codeEd25519), newSigner(t, codeEd25519), newSigner(t, codeEd25519)
+	e := &inception{
+		T:  TypeInception,
+		S:  "0",
+		Kt: []string{"1/2", "1/2"},
+		K:  []string{k0.qb64, k1.qb64},
+		Nt: "1",
+		N:  []string{n0.next()},
+		Bt: "0",
+		B:  []string{},
+		C:  []string{},
+		A:  []interface{}{},
+	}
+	raw := serialize(t, e, &e.V, &e.D, &e.I)
+	l := &kel{aid: e.I, said: e.D}
+	l.add(raw, []*signer{k0, nil}, nil)
+	if _, _, err := resolve(t, l); err == nil {
+		t.Error("expected Resolve to return error")
+	}
+
+	l = &kel{aid: e.I, said: e.D}
+	l.add(raw, []*signer{k0, k1}, nil)
+	doc, _, err := resolve(t, l)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Neither key satisfies the threshold alone.
+	if len(doc.VerificationMethod) != 2 || len(doc.Authentication) != 0 {
+		t.Errorf("unexpected document: %+v", doc)
+	}
+}
+
+func TestWebs(t *testing.T) {
+	k0, k1 := newSigner(t, codeEd25519), newSigner(t, codeEd25519)
+	l := newKEL(t, []*signer{k0}, []*signer{k1}, nil, "0")
+	client := web.WithClient(&MockClient{files: map[string][]byte{
+		"https://example.com/dids/" + l.aid + "/keri.cesr": l.stream.Bytes(),
+		"https://witness.example.com/oobi/" + l.aid:        l.stream.Bytes(),
+	}})
+
+	id := "did:webs:example.com:dids:" + l.aid
+	parsed, err := resolver.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := NewWebs(client)
+	doc, metadata, err := r.ResolveWithMetadata(id, parsed, r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.ID != id || doc.VerificationMethod[0].ID != id+"#"+k0.qb64 {
+		t.Errorf("unexpected document: %+v", doc)
+	}
+	if !reflect.DeepEqual(metadata.EquivalentID, []string{"did:keri:" + l.aid}) {
+		t.Errorf("unexpected metadata: %+v", metadata)
+	}
+
+	// The same log, from an out-of-band introduction.
+	keriID := "did:keri:" + l.aid
+	parsed, err = resolver.Parse(keriID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kr := New(OOBI("https://witness.example.com/", client))
+	if _, err := kr.Resolve(keriID, parsed, kr); err != nil {
+		t.Fatal(err)
+	}
+}
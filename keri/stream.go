@@ -0,0 +1,130 @@
+// Package keri provides tools for resolving the did:keri and did:webs method
+// formats, by verifying the KERI key event log of an autonomic identifier:
+// https://trustoverip.github.io/tswg-keri-specification/
+// Copyright 2021 Textile
+package keri
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// versionPattern matches the start of a KERI json event, whose version string
+// holds the size of the serialized event.
+var versionPattern = regexp.MustCompile(`^\{"v":"KERI10JSON([0-9a-f]{6})_"`)
+
+// message is an event of a CESR stream, with its attached signatures.
+type message struct {
+	event             Event
+	raw               []byte
+	signatures        []signature
+	witnessSignatures []signature
+	receipts          []receipt
+}
+
+// receipt is a signature by a non-transferable witness prefix.
+type receipt struct {
+	prefix string
+	sig    []byte
+}
+
+// parseStream decodes the events of a CESR stream, and their attachments.
+func parseStream(data []byte) ([]message, error) {
+	var messages []message
+	for {
+		data = bytes.TrimLeft(data, " \t\r\n")
+		if len(data) == 0 {
+			return messages, nil
+		}
+		match := versionPattern.FindSubmatch(data)
+		if match == nil {
+			return nil, fmt.Errorf("unsupported event at: '%.24s'", data)
+		}
+		size, _ := strconv.ParseInt(string(match[1]), 16, 64)
+		if int(size) > len(data) {
+			return nil, fmt.Errorf("truncated event")
+		}
+		msg := message{raw: data[:size]}
+		if err := json.Unmarshal(msg.raw, &msg.event); err != nil {
+			return nil, fmt.Errorf("invalid event: %v", err)
+		}
+		rest, err := msg.parseAttachments(data[size:])
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+		data = rest
+	}
+}
+
+// parseAttachments decodes the attachment groups that follow an event, and
+// returns the rest of the stream.
+func (m *message) parseAttachments(data []byte) ([]byte, error) {
+	for len(data) > 0 && data[0] == '-' {
+		if len(data) < 4 {
+			return nil, fmt.Errorf("truncated attachment")
+		}
+		code := string(data[:2])
+		count, err := b64Int(string(data[2:4]))
+		if err != nil {
+			return nil, err
+		}
+		data = data[4:]
+		// size is the length of each item of the group.
+		var size int
+		switch code {
+		case "-A", "-B":
+			size = 88
+		case "-C":
+			size = 44 + 88
+		case "-E":
+			// First seen replay couples, which are not verified.
+			size = 24 + 36
+		case "-V":
+			// Attached material quadlets, which wrap other groups.
+			size = 4
+		default:
+			return nil, fmt.Errorf("unsupported attachment: '%s'", code)
+		}
+		if len(data) < count*size {
+			return nil, fmt.Errorf("truncated attachment: '%s'", code)
+		}
+		group := data[:count*size]
+		data = data[count*size:]
+		if code == "-V" {
+			rest, err := m.parseAttachments(group)
+			if err != nil {
+				return nil, err
+			}
+			if len(rest) != 0 {
+				return nil, fmt.Errorf("invalid attachment group")
+			}
+			continue
+		}
+		for i := 0; i < count; i++ {
+			item := string(group[i*size : (i+1)*size])
+			switch code {
+			case "-A", "-B":
+				sig, err := decodeIndexedSignature(item)
+				if err != nil {
+					return nil, err
+				}
+				if code == "-A" {
+					m.signatures = append(m.signatures, *sig)
+				} else {
+					m.witnessSignatures = append(m.witnessSignatures, *sig)
+				}
+			case "-C":
+				sig, err := decodeSignature(item[44:])
+				if err != nil {
+					return nil, err
+				}
+				m.receipts = append(m.receipts, receipt{prefix: item[:44], sig: sig})
+			}
+		}
+	}
+	return data, nil
+}
@@ -7,65 +7,63 @@ package keys
 import (
 	"fmt"
 
-	mbase "github.com/multiformats/go-multibase"
 	codec "github.com/multiformats/go-multicodec"
-	varint "github.com/multiformats/go-varint"
+	"github.com/textileio/go-did-resolver/multikey"
 	"github.com/textileio/go-did-resolver/resolver"
 
 	// https://github.com/golang/go/issues/20504
 	"github.com/jorrizza/ed2curve25519"
 )
 
-func encodeEd25519PublicKey(key []byte) (string, error) {
-	// https://github.com/multiformats/multicodec/blob/master/table.csv#L84
-	// {name:"x25519-pub", tag:"key", code:0xec, description:"Curve25519 public key"}
-	prefix := varint.ToUvarint(uint64(codec.X25519Pub))
-	bytes := append(prefix, key...)
-	// The spec specifies base58 btc...
-	str, err := mbase.Encode(mbase.Base58BTC, bytes)
-	if err != nil {
-		return "", err
-	}
-	return str, nil
+// ExpandEd25519Key creates a did Document from an input ed15519 public key,
+// using the DefaultFormat.
+func ExpandEd25519Key(bytes []byte, fingerprint string) (*resolver.Document, error) {
+	return ExpandEd25519KeyWithFormat(bytes, fingerprint, DefaultFormat)
 }
 
-// ExpandEd25519Key creates a did Document from an input ed15519 public key.
-func ExpandEd25519Key(bytes []byte, fingerprint string) (*resolver.Document, error) {
+// ExpandEd25519KeyWithFormat creates a did Document from an input ed15519
+// public key, expressing the key and its derived x25519 key agreement key in
+// the given format. An empty format selects DefaultFormat.
+func ExpandEd25519KeyWithFormat(bytes []byte, fingerprint string, format Format) (*resolver.Document, error) {
+	ed25519Key := &multikey.Key{Code: codec.Ed25519Pub, Bytes: bytes}
+	if err := ed25519Key.Validate(); err != nil {
+		return nil, err
+	}
+	format, err := formatFor(ed25519Key, format)
+	if err != nil {
+		return nil, err
+	}
 	did := fmt.Sprintf("did:key:%s", fingerprint)
 	keyID := fmt.Sprintf("%s#%s", did, fingerprint)
-	keyMultiBase, err := mbase.Encode(mbase.Base58BTC, bytes)
+	key, err := format.verificationMethod(ed25519Key, keyID, did)
 	if err != nil {
 		return nil, err
 	}
-	x25519Bytes := ed2curve25519.Ed25519PublicKeyToCurve25519(bytes)
-	x25519Encoded, err := encodeEd25519PublicKey(x25519Bytes)
+	// https://github.com/multiformats/multicodec/blob/master/table.csv#L84
+	// {name:"x25519-pub", tag:"key", code:0xec, description:"Curve25519 public key"}
+	x25519Key := &multikey.Key{
+		Code:  codec.X25519Pub,
+		Bytes: ed2curve25519.Ed25519PublicKeyToCurve25519(bytes),
+	}
+	// The spec specifies base58 btc...
+	x25519Encoded, err := x25519Key.Encode()
 	if err != nil {
 		return nil, err
 	}
 	x25519ID := fmt.Sprintf("%s#%s", did, x25519Encoded)
-	x25519MultiBase, err := mbase.Encode(mbase.Base58BTC, x25519Bytes)
+	x25519, err := format.verificationMethod(x25519Key, x25519ID, did)
 	if err != nil {
 		return nil, err
 	}
 	doc := &resolver.Document{
-		Context: []string{"https://w3id.org/did/v1"},
-		ID:      did,
-		Authentication: []resolver.VerificationMethod{
-			{
-				ID:                 keyID,
-				Type:               "Ed25519VerificationKey2018",
-				Controller:         did,
-				PublicKeyMultibase: keyMultiBase,
-			},
-		},
-		VerificationMethod: []resolver.VerificationMethod{
-			{
-				ID:                 x25519ID,
-				Type:               "X25519KeyAgreementKey2019",
-				Controller:         did,
-				PublicKeyMultibase: x25519MultiBase,
-			},
-		},
+		Context:              format.contexts(),
+		ID:                   did,
+		VerificationMethod:   []resolver.VerificationMethod{key, x25519},
+		Authentication:       []resolver.VerificationMethod{resolver.Reference(keyID)},
+		AssertionMethod:      []resolver.VerificationMethod{resolver.Reference(keyID)},
+		CapabilityInvocation: []resolver.VerificationMethod{resolver.Reference(keyID)},
+		CapabilityDelegation: []resolver.VerificationMethod{resolver.Reference(keyID)},
+		KeyAgreement:         []resolver.VerificationMethod{resolver.Reference(x25519ID)},
 	}
 	return doc, nil
 }
@@ -0,0 +1,148 @@
+// Package keys provides tools for resolving the w3c did:key format, for
+// static cryptographic keys: https://w3c-ccg.github.io/did-method-key/#format
+// Copyright 2021 Textile
+// Copyright 2021 Ceramic Network
+package keys
+
+import (
+	"fmt"
+
+	mbase "github.com/multiformats/go-multibase"
+	codec "github.com/multiformats/go-multicodec"
+	"github.com/textileio/go-did-resolver/jwk"
+	"github.com/textileio/go-did-resolver/multikey"
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+// Format is the verification method type used to express public keys in a
+// did:key document.
+// See https://w3c-ccg.github.io/did-method-key/#resolve-options
+type Format string
+
+const (
+	// Ed25519VerificationKey2018 expresses keys as base58 encoded 2018 suite
+	// verification methods. This is the default format.
+	Ed25519VerificationKey2018 Format = "Ed25519VerificationKey2018"
+	// Ed25519VerificationKey2020 expresses keys as multibase encoded 2020
+	// suite verification methods.
+	Ed25519VerificationKey2020 Format = "Ed25519VerificationKey2020"
+	// Multikey expresses keys as multibase encoded Multikey verification
+	// methods.
+	Multikey Format = "Multikey"
+	// JSONWebKey2020 expresses keys as JsonWebKey2020 verification methods.
+	JSONWebKey2020 Format = "JsonWebKey2020"
+)
+
+// DefaultFormat is the Format used for ed25519 and x25519 keys when none is
+// requested.
+const DefaultFormat = Ed25519VerificationKey2018
+
+// keyFormats lists the formats defined for each key type, the first being the
+// default when no format is requested. The 2018 and 2020 suites are only
+// defined for ed25519 and x25519 keys.
+var keyFormats = map[codec.Code][]Format{
+	codec.Ed25519Pub:   {Ed25519VerificationKey2018, Ed25519VerificationKey2020, Multikey, JSONWebKey2020},
+	codec.X25519Pub:    {Ed25519VerificationKey2018, Ed25519VerificationKey2020, Multikey, JSONWebKey2020},
+	codec.Secp256k1Pub: {Multikey, JSONWebKey2020},
+	multikey.RSAPub:    {JSONWebKey2020, Multikey},
+}
+
+// contexts returns the json-ld contexts required by documents of format f.
+func (f Format) contexts() []string {
+	switch f {
+	case Ed25519VerificationKey2018:
+		return []string{
+			"https://www.w3.org/ns/did/v1",
+			"https://w3id.org/security/suites/ed25519-2018/v1",
+			"https://w3id.org/security/suites/x25519-2019/v1",
+		}
+	case Ed25519VerificationKey2020:
+		return []string{
+			"https://www.w3.org/ns/did/v1",
+			"https://w3id.org/security/suites/ed25519-2020/v1",
+			"https://w3id.org/security/suites/x25519-2020/v1",
+		}
+	case Multikey:
+		return []string{
+			"https://www.w3.org/ns/did/v1",
+			"https://w3id.org/security/multikey/v1",
+		}
+	case JSONWebKey2020:
+		return []string{
+			"https://www.w3.org/ns/did/v1",
+			"https://w3id.org/security/suites/jws-2020/v1",
+		}
+	}
+	return nil
+}
+
+// parseFormat validates a requested public key format. An empty format is
+// returned as is, leaving the choice to the default of the key type.
+func parseFormat(format string) (Format, error) {
+	f := Format(format)
+	if f != "" && f.contexts() == nil {
+		return "", fmt.Errorf("unsupported public key format: '%s'", format)
+	}
+	return f, nil
+}
+
+// formatFor checks that format is defined for keys of the given type,
+// returning the default format of the type when format is empty.
+func formatFor(key *multikey.Key, format Format) (Format, error) {
+	formats := keyFormats[key.Code]
+	if len(formats) == 0 {
+		return "", fmt.Errorf("unknown key type: '%s'", key.Name())
+	}
+	if format == "" {
+		return formats[0], nil
+	}
+	if format.contexts() == nil {
+		return "", fmt.Errorf("unsupported public key format: '%s'", format)
+	}
+	for _, f := range formats {
+		if f == format {
+			return format, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported public key format for %s key: '%s'", key.Name(), format)
+}
+
+// verificationMethod expresses a key as a verification method of format f.
+// The 2018 and 2020 suites are only defined for ed25519 and x25519 keys.
+func (f Format) verificationMethod(key *multikey.Key, id, controller string) (resolver.VerificationMethod, error) {
+	vm := resolver.VerificationMethod{
+		ID:         id,
+		Controller: controller,
+	}
+	switch f {
+	case Ed25519VerificationKey2018:
+		vm.Type = "Ed25519VerificationKey2018"
+		if key.Code == codec.X25519Pub {
+			vm.Type = "X25519KeyAgreementKey2019"
+		}
+		// Base58 without the multibase prefix
+		encoded, err := mbase.Encode(mbase.Base58BTC, key.Bytes)
+		if err != nil {
+			return vm, err
+		}
+		vm.PublicKeyBase58 = encoded[1:]
+	case Ed25519VerificationKey2020, Multikey:
+		vm.Type = string(f)
+		if f == Ed25519VerificationKey2020 && key.Code == codec.X25519Pub {
+			vm.Type = "X25519KeyAgreementKey2020"
+		}
+		encoded, err := key.Encode()
+		if err != nil {
+			return vm, err
+		}
+		vm.PublicKeyMultibase = encoded
+	case JSONWebKey2020:
+		vm.Type = string(f)
+		jwk, err := jwk.FromKey(key)
+		if err != nil {
+			return vm, err
+		}
+		vm.PublicKeyJwk = jwk
+	}
+	return vm, nil
+}
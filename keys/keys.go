@@ -7,10 +7,9 @@ package keys
 import (
 	"fmt"
 
-	mbase "github.com/multiformats/go-multibase"
 	codec "github.com/multiformats/go-multicodec"
-	varint "github.com/multiformats/go-varint"
 	"github.com/ockam-network/did"
+	"github.com/textileio/go-did-resolver/multikey"
 	"github.com/textileio/go-did-resolver/resolver"
 )
 
@@ -29,28 +28,39 @@ func (r *Resolver) Method() string {
 
 // Resolve is the primary resolution method for this resolver.
 func (r *Resolver) Resolve(did string, parsed *did.DID, res resolver.Resolver) (*resolver.Document, error) {
+	return r.ResolveWithOptions(did, parsed, res, nil)
+}
+
+// ResolveWithOptions resolves a did:key, expressing keys in the requested
+// options.PublicKeyFormat, or the default format of the key type.
+func (r *Resolver) ResolveWithOptions(did string, parsed *did.DID, res resolver.Resolver, options *resolver.ResolutionOptions) (*resolver.Document, error) {
 	if parsed.Method != r.Method() {
 		return nil, fmt.Errorf("unknown did method: '%s'", parsed.Method)
 	}
-	_, bytes, err := mbase.Decode(parsed.ID)
-	if err != nil {
-		return nil, err
+	var format Format
+	if options != nil {
+		var err error
+		format, err = parseFormat(options.PublicKeyFormat)
+		if err != nil {
+			return nil, err
+		}
 	}
-	keyType, n, err := varint.FromUvarint(bytes)
+	key, err := multikey.Decode(parsed.ID)
 	if err != nil {
 		return nil, err
 	}
-	if n != 2 {
-		return nil, fmt.Errorf("error parsing varint")
-	}
-	switch keyType {
-	case uint64(codec.Ed25519Pub):
-		return ExpandEd25519Key(bytes[n:], parsed.ID)
-	case uint64(codec.Secp256k1Pub):
-		return ExpandSecp256k1Key(bytes[n:], parsed.ID)
+	switch key.Code {
+	case codec.Ed25519Pub:
+		return ExpandEd25519KeyWithFormat(key.Bytes, parsed.ID, format)
+	case codec.Secp256k1Pub:
+		return ExpandSecp256k1KeyWithFormat(key.Bytes, parsed.ID, format)
+	case codec.X25519Pub:
+		return ExpandX25519KeyWithFormat(key.Bytes, parsed.ID, format)
+	case multikey.RSAPub:
+		return ExpandRSAKeyWithFormat(key.Bytes, parsed.ID, format)
 	default:
-		return nil, fmt.Errorf("unknown key type: '%s'", codec.Code(keyType).String())
+		return nil, fmt.Errorf("unknown key type: '%s'", key.Name())
 	}
 }
 
-var _ resolver.Resolver = (*Resolver)(nil)
+var _ resolver.OptionsResolver = (*Resolver)(nil)
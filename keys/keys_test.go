@@ -5,6 +5,10 @@
 package keys
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
 	"encoding/json"
 	"io/ioutil"
 	"reflect"
@@ -14,6 +18,7 @@ import (
 	codec "github.com/multiformats/go-multicodec"
 	varint "github.com/multiformats/go-varint"
 	did "github.com/ockam-network/did"
+	"github.com/textileio/go-did-resolver/multikey"
 	"github.com/textileio/go-did-resolver/resolver"
 )
 
@@ -161,7 +166,8 @@ func TestUnknownMethod(t *testing.T) {
 }
 
 func TestVarintParsingError(t *testing.T) {
-	id := "did:key:zhash"
+	// A single continuation byte, the varint never terminates
+	id := "did:key:z3D"
 
 	parsed, err := did.Parse(id)
 	if err != nil {
@@ -169,22 +175,49 @@ func TestVarintParsingError(t *testing.T) {
 	}
 	r := New()
 	_, err = r.Resolve(id, parsed, r)
-	if err == nil || err.Error() != "error parsing varint" {
+	if err == nil || err.Error() != varint.ErrUnderflow.Error() {
 		t.Error("expected Resolve to return error")
 	}
 }
 
-func TestUnknownKeyType(t *testing.T) {
+func TestX25519KeyResolver(t *testing.T) {
 	id := "did:key:z6LSnkZe3JZPCo88XsdQVJi8j1TomzX2yRW7ZnvvWhmrSdmG"
 
+	parsed, err := did.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	observed, err := r.Resolve(id, parsed, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	byteValue, err := ioutil.ReadFile("testdata/x25519.json")
+	if err != nil {
+		t.Error(err)
+	}
+	var expected resolver.Document
+	err = json.Unmarshal(byteValue, &expected)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(observed, &expected) {
+		t.Errorf("expected document to match: %s", expected.ID)
+	}
+}
+
+func TestUnknownKeyType(t *testing.T) {
+	id := "did:key:z6GPB9Uyygokv92zg7F2khRAdjwNN8wMVNXRwLa6RWSLXd3q1pbQFJS33WXAxu4qK4KMGgJohnXPcCfgvE"
+
 	parsed, err := did.Parse(id)
 	if err != nil {
 		t.Error(err)
 	}
 	r := New()
 	_, err = r.Resolve(id, parsed, r)
-	// We don't support "x25519-pub" keys directly
-	if err == nil || err.Error() != "unknown key type: 'x25519-pub'" {
+	// We don't support "ed448-pub" keys
+	if err == nil || err.Error() != "unknown key type: 'ed448-pub'" {
 		t.Error("expected Resolve to return error")
 	}
 }
@@ -216,3 +249,220 @@ func TestInvalidVarint(t *testing.T) {
 		t.Error("expected Resolve to return error")
 	}
 }
+
+func TestRSAKeyResolver(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, MinRSAModulusSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fingerprint, err := EncodeRSAPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := "did:key:" + fingerprint
+
+	parsed, err := did.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	observed, err := r.Resolve(id, parsed, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID := id + "#" + fingerprint
+	expected := &resolver.Document{
+		Context: JSONWebKey2020.contexts(),
+		ID:      id,
+		VerificationMethod: []resolver.VerificationMethod{
+			{
+				ID:         keyID,
+				Type:       "JsonWebKey2020",
+				Controller: id,
+				PublicKeyJwk: &resolver.JWK{
+					Kty: "RSA",
+					N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+					E:   "AQAB",
+				},
+			},
+		},
+		Authentication:       []resolver.VerificationMethod{resolver.Reference(keyID)},
+		AssertionMethod:      []resolver.VerificationMethod{resolver.Reference(keyID)},
+		CapabilityInvocation: []resolver.VerificationMethod{resolver.Reference(keyID)},
+		CapabilityDelegation: []resolver.VerificationMethod{resolver.Reference(keyID)},
+	}
+	if !reflect.DeepEqual(observed, expected) {
+		t.Errorf("expected document to match: %s", expected.ID)
+	}
+}
+
+func TestRSAKeyFormats(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, MinRSAModulusSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fingerprint, err := EncodeRSAPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := "did:key:" + fingerprint
+	registry := resolver.New([]resolver.Resolver{New()}, false)
+	options := &resolver.ResolutionOptions{PublicKeyFormat: string(Multikey)}
+	_, observed, _, err := registry.Resolve(id, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm := observed.VerificationMethod[0]
+	if vm.Type != "Multikey" || vm.PublicKeyMultibase != fingerprint || vm.PublicKeyJwk != nil {
+		t.Errorf("unexpected verification method: %+v", vm)
+	}
+	if !reflect.DeepEqual(observed.Context, Multikey.contexts()) {
+		t.Errorf("unexpected context: %v", observed.Context)
+	}
+	for _, format := range []Format{Ed25519VerificationKey2018, Ed25519VerificationKey2020} {
+		options := &resolver.ResolutionOptions{PublicKeyFormat: string(format)}
+		_, _, _, err := registry.Resolve(id, options)
+		if err == nil || err.Error() != "unsupported public key format for rsa-pub key: '"+string(format)+"'" {
+			t.Errorf("expected Resolve to return error for %s: %v", format, err)
+		}
+	}
+}
+
+func TestRSAKeyTooSmall(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := EncodeRSAPublicKey(&key.PublicKey); err == nil {
+		t.Error("expected EncodeRSAPublicKey to return error")
+	}
+	bytes := append(varint.ToUvarint(uint64(multikey.RSAPub)), x509.MarshalPKCS1PublicKey(&key.PublicKey)...)
+	fingerprint, err := mbase.Encode(mbase.Base58BTC, bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := "did:key:" + fingerprint
+
+	parsed, err := did.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	_, err = r.Resolve(id, parsed, r)
+	if err == nil || err.Error() != "rsa modulus too small: 1024 bits" {
+		t.Error("expected Resolve to return error")
+	}
+}
+
+func TestEd25519KeyFormats(t *testing.T) {
+	id := "did:key:z6MktvqCyLxTsXUH1tUZncNdVeEZ7hNh7npPRbUU27GTrYb8"
+	tests := []struct {
+		format       Format
+		keyType      string
+		x25519Type   string
+		hasMultibase bool
+		hasJwk       bool
+	}{
+		{Ed25519VerificationKey2020, "Ed25519VerificationKey2020", "X25519KeyAgreementKey2020", true, false},
+		{Multikey, "Multikey", "Multikey", true, false},
+		{JSONWebKey2020, "JsonWebKey2020", "JsonWebKey2020", false, true},
+	}
+	registry := resolver.New([]resolver.Resolver{New()}, true)
+	for _, tt := range tests {
+		options := &resolver.ResolutionOptions{PublicKeyFormat: string(tt.format)}
+		_, observed, _, err := registry.Resolve(id, options)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(observed.VerificationMethod) != 2 {
+			t.Fatalf("expected 2 verification methods, got: %d", len(observed.VerificationMethod))
+		}
+		key, x25519 := observed.VerificationMethod[0], observed.VerificationMethod[1]
+		if key.Type != tt.keyType || x25519.Type != tt.x25519Type {
+			t.Errorf("unexpected verification method types: %s, %s", key.Type, x25519.Type)
+		}
+		if tt.hasMultibase && key.PublicKeyMultibase != "z6MktvqCyLxTsXUH1tUZncNdVeEZ7hNh7npPRbUU27GTrYb8" {
+			t.Errorf("unexpected publicKeyMultibase: %s", key.PublicKeyMultibase)
+		}
+		if tt.hasJwk && (key.PublicKeyJwk == nil || key.PublicKeyJwk.Crv != "Ed25519" || x25519.PublicKeyJwk.Crv != "X25519") {
+			t.Error("expected publicKeyJwk to be set")
+		}
+		if !reflect.DeepEqual(observed.Context, tt.format.contexts()) {
+			t.Errorf("unexpected context: %v", observed.Context)
+		}
+		if len(observed.KeyAgreement) != 1 || observed.KeyAgreement[0].ID != x25519.ID {
+			t.Error("expected key agreement to reference x25519 key")
+		}
+	}
+}
+
+func TestUnsupportedFormat(t *testing.T) {
+	id := "did:key:z6MktvqCyLxTsXUH1tUZncNdVeEZ7hNh7npPRbUU27GTrYb8"
+
+	parsed, err := did.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	options := &resolver.ResolutionOptions{PublicKeyFormat: "Borg2021"}
+	_, err = r.ResolveWithOptions(id, parsed, r, options)
+	if err == nil || err.Error() != "unsupported public key format: 'Borg2021'" {
+		t.Error("expected ResolveWithOptions to return error")
+	}
+}
+
+func TestInvalidPublicKey(t *testing.T) {
+	// An ed25519-pub multicodec prefix followed by the identity point
+	identity := make([]byte, 32)
+	identity[0] = 1
+	bytes := append(varint.ToUvarint(uint64(codec.Ed25519Pub)), identity...)
+	fingerprint, err := mbase.Encode(mbase.Base58BTC, bytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := resolver.New([]resolver.Resolver{New()}, false)
+	metadata, _, _, err := registry.Resolve("did:key:"+fingerprint, nil)
+	if err == nil || err.Error() != "invalid ed25519 point: small order" {
+		t.Error("expected Resolve to return error")
+	}
+	if metadata.Error != resolver.InvalidPublicKey {
+		t.Errorf("expected invalidPublicKey, got: %s", metadata.Error)
+	}
+}
+
+func TestSecp256k1KeyJWKFormat(t *testing.T) {
+	id := "did:key:zQ3shbgnTGcgBpXPdBjDur3ATMDWhS7aPs6FRFkWR19Lb9Zwz"
+
+	parsed, err := did.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	options := &resolver.ResolutionOptions{PublicKeyFormat: string(JSONWebKey2020)}
+	observed, err := r.ResolveWithOptions(id, parsed, r, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(observed.VerificationMethod) != 1 {
+		t.Fatalf("expected 1 verification method, got: %d", len(observed.VerificationMethod))
+	}
+	key := observed.VerificationMethod[0].PublicKeyJwk
+	if key == nil || key.Kty != "EC" || key.Crv != "secp256k1" || key.Y == "" {
+		t.Error("expected secp256k1 publicKeyJwk")
+	}
+	if len(observed.Authentication) != 1 || !observed.Authentication[0].IsReference() {
+		t.Error("expected authentication reference")
+	}
+}
+
+func TestSecp256k1KeyUnsupportedFormat(t *testing.T) {
+	id := "did:key:zQ3shbgnTGcgBpXPdBjDur3ATMDWhS7aPs6FRFkWR19Lb9Zwz"
+	registry := resolver.New([]resolver.Resolver{New()}, false)
+	for _, format := range []Format{Ed25519VerificationKey2018, Ed25519VerificationKey2020} {
+		options := &resolver.ResolutionOptions{PublicKeyFormat: string(format)}
+		_, _, _, err := registry.Resolve(id, options)
+		if err == nil || err.Error() != "unsupported public key format for secp256k1-pub key: '"+string(format)+"'" {
+			t.Errorf("expected Resolve to return error for %s: %v", format, err)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+// Package keys provides tools for resolving the w3c did:key format, for
+// static cryptographic keys: https://w3c-ccg.github.io/did-method-key/#format
+// Copyright 2021 Textile
+// Copyright 2021 Ceramic Network
+package keys
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+
+	"github.com/textileio/go-did-resolver/multikey"
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+// MinRSAModulusSize is the smallest RSA modulus, in bits, that will be
+// accepted when expanding an RSA did:key.
+const MinRSAModulusSize = multikey.MinRSAModulusSize
+
+// EncodeRSAPublicKey encodes an RSA public key as a did:key fingerprint. The
+// full did is formed by prefixing the result with "did:key:".
+func EncodeRSAPublicKey(key *rsa.PublicKey) (string, error) {
+	if key == nil || key.N == nil {
+		return "", fmt.Errorf("invalid rsa public key")
+	}
+	if key.N.BitLen() < MinRSAModulusSize {
+		return "", fmt.Errorf("rsa modulus too small: %d bits", key.N.BitLen())
+	}
+	mk := &multikey.Key{
+		Code:  multikey.RSAPub,
+		Bytes: x509.MarshalPKCS1PublicKey(key),
+	}
+	return mk.Encode()
+}
+
+// ExpandRSAKey creates a did Document from an input DER-encoded PKCS#1 RSA
+// public key, expressed as a JsonWebKey2020 verification method.
+func ExpandRSAKey(bytes []byte, fingerprint string) (*resolver.Document, error) {
+	return ExpandRSAKeyWithFormat(bytes, fingerprint, JSONWebKey2020)
+}
+
+// ExpandRSAKeyWithFormat creates a did Document from an input DER-encoded
+// PKCS#1 RSA public key, expressed in the given format. Only the Multikey and
+// JsonWebKey2020 formats are defined for RSA keys, and an empty format
+// selects JsonWebKey2020.
+func ExpandRSAKeyWithFormat(bytes []byte, fingerprint string, format Format) (*resolver.Document, error) {
+	key, err := x509.ParsePKCS1PublicKey(bytes)
+	if err != nil {
+		return nil, resolver.NewError(resolver.InvalidPublicKey, "invalid rsa public key: %v", err)
+	}
+	if key.N.BitLen() < MinRSAModulusSize {
+		return nil, resolver.NewError(resolver.InvalidPublicKey, "rsa modulus too small: %d bits", key.N.BitLen())
+	}
+	rsaKey := &multikey.Key{Code: multikey.RSAPub, Bytes: bytes}
+	format, err = formatFor(rsaKey, format)
+	if err != nil {
+		return nil, err
+	}
+	did := fmt.Sprintf("did:key:%s", fingerprint)
+	keyID := fmt.Sprintf("%s#%s", did, fingerprint)
+	vm, err := format.verificationMethod(rsaKey, keyID, did)
+	if err != nil {
+		return nil, err
+	}
+	doc := &resolver.Document{
+		Context:              format.contexts(),
+		ID:                   did,
+		VerificationMethod:   []resolver.VerificationMethod{vm},
+		Authentication:       []resolver.VerificationMethod{resolver.Reference(keyID)},
+		AssertionMethod:      []resolver.VerificationMethod{resolver.Reference(keyID)},
+		CapabilityInvocation: []resolver.VerificationMethod{resolver.Reference(keyID)},
+		CapabilityDelegation: []resolver.VerificationMethod{resolver.Reference(keyID)},
+	}
+	return doc, nil
+}
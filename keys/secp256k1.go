@@ -7,29 +7,44 @@ package keys
 import (
 	"fmt"
 
-	mbase "github.com/multiformats/go-multibase"
+	codec "github.com/multiformats/go-multicodec"
+	"github.com/textileio/go-did-resolver/multikey"
 	"github.com/textileio/go-did-resolver/resolver"
 )
 
-// ExpandSecp256k1Key creates a did Document from an input secp256k1 public key.
+// ExpandSecp256k1Key creates a did Document from an input secp256k1 public
+// key, expressed as a Multikey verification method.
 func ExpandSecp256k1Key(bytes []byte, fingerprint string) (*resolver.Document, error) {
+	return ExpandSecp256k1KeyWithFormat(bytes, fingerprint, Multikey)
+}
+
+// ExpandSecp256k1KeyWithFormat creates a did Document from an input secp256k1
+// public key, expressed in the given format. Only the Multikey and
+// JsonWebKey2020 formats are defined for secp256k1 keys, and an empty format
+// selects Multikey.
+func ExpandSecp256k1KeyWithFormat(bytes []byte, fingerprint string, format Format) (*resolver.Document, error) {
+	key := &multikey.Key{Code: codec.Secp256k1Pub, Bytes: bytes}
+	if err := key.Validate(); err != nil {
+		return nil, err
+	}
+	format, err := formatFor(key, format)
+	if err != nil {
+		return nil, err
+	}
 	did := fmt.Sprintf("did:key:%s", fingerprint)
 	keyID := fmt.Sprintf("%s#%s", did, fingerprint)
-	keyMultiBase, err := mbase.Encode(mbase.Base16, bytes)
+	vm, err := format.verificationMethod(key, keyID, did)
 	if err != nil {
 		return nil, err
 	}
 	doc := &resolver.Document{
-		Context: []string{"https://w3id.org/did/v1"},
-		ID:      did,
-		VerificationMethod: []resolver.VerificationMethod{
-			{
-				ID:                 keyID,
-				Type:               "Secp256k1VerificationKey2018",
-				Controller:         did,
-				PublicKeyMultibase: keyMultiBase,
-			},
-		},
+		Context:              format.contexts(),
+		ID:                   did,
+		VerificationMethod:   []resolver.VerificationMethod{vm},
+		Authentication:       []resolver.VerificationMethod{resolver.Reference(keyID)},
+		AssertionMethod:      []resolver.VerificationMethod{resolver.Reference(keyID)},
+		CapabilityInvocation: []resolver.VerificationMethod{resolver.Reference(keyID)},
+		CapabilityDelegation: []resolver.VerificationMethod{resolver.Reference(keyID)},
 	}
 	return doc, nil
 }
@@ -0,0 +1,46 @@
+// Package keys provides tools for resolving the w3c did:key format, for
+// static cryptographic keys: https://w3c-ccg.github.io/did-method-key/#format
+// Copyright 2021 Textile
+// Copyright 2021 Ceramic Network
+package keys
+
+import (
+	"fmt"
+
+	codec "github.com/multiformats/go-multicodec"
+	"github.com/textileio/go-did-resolver/multikey"
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+// ExpandX25519Key creates a did Document from an input x25519 public key,
+// using the DefaultFormat.
+func ExpandX25519Key(bytes []byte, fingerprint string) (*resolver.Document, error) {
+	return ExpandX25519KeyWithFormat(bytes, fingerprint, DefaultFormat)
+}
+
+// ExpandX25519KeyWithFormat creates a did Document from an input x25519 public
+// key, expressed in the given format, or DefaultFormat when empty. X25519 keys may only be used for key
+// agreement, so the resulting document has no authentication relationships.
+func ExpandX25519KeyWithFormat(bytes []byte, fingerprint string, format Format) (*resolver.Document, error) {
+	x25519Key := &multikey.Key{Code: codec.X25519Pub, Bytes: bytes}
+	if err := x25519Key.Validate(); err != nil {
+		return nil, err
+	}
+	format, err := formatFor(x25519Key, format)
+	if err != nil {
+		return nil, err
+	}
+	did := fmt.Sprintf("did:key:%s", fingerprint)
+	keyID := fmt.Sprintf("%s#%s", did, fingerprint)
+	key, err := format.verificationMethod(x25519Key, keyID, did)
+	if err != nil {
+		return nil, err
+	}
+	doc := &resolver.Document{
+		Context:            format.contexts(),
+		ID:                 did,
+		VerificationMethod: []resolver.VerificationMethod{key},
+		KeyAgreement:       []resolver.VerificationMethod{resolver.Reference(keyID)},
+	}
+	return doc, nil
+}
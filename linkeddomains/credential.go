@@ -0,0 +1,359 @@
+// Package linkeddomains provides tools for verifying that dids and web origins
+// control each other, with the DID Configuration resource of an origin and the
+// LinkedDomains services of a did document:
+// https://identity.foundation/.well-known/resources/did-configuration/
+// Copyright 2021 Textile
+package linkeddomains
+
+import (
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+
+	mbase "github.com/multiformats/go-multibase"
+	codec "github.com/multiformats/go-multicodec"
+	"github.com/textileio/go-did-resolver/jcs"
+	"github.com/textileio/go-did-resolver/jwk"
+	"github.com/textileio/go-did-resolver/multikey"
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+// algorithms maps the supported JWS algorithms to the multicodec code of
+// their keys.
+var algorithms = map[string]codec.Code{
+	"EdDSA":  codec.Ed25519Pub,
+	"ES256":  codec.P256Pub,
+	"ES256K": codec.Secp256k1Pub,
+	"ES384":  codec.P384Pub,
+}
+
+// credential is a Domain Linkage Credential, or the vc claim of its JWT form.
+type credential struct {
+	Context           json.RawMessage `json:"@context"`
+	Type              []string        `json:"type"`
+	Issuer            json.RawMessage `json:"issuer,omitempty"`
+	IssuanceDate      string          `json:"issuanceDate,omitempty"`
+	ExpirationDate    string          `json:"expirationDate,omitempty"`
+	CredentialSubject struct {
+		ID     string `json:"id"`
+		Origin string `json:"origin"`
+	} `json:"credentialSubject"`
+	Proof json.RawMessage `json:"proof,omitempty"`
+}
+
+// Proof is a Data Integrity proof of a json-ld Domain Linkage Credential.
+// See https://www.w3.org/TR/vc-data-integrity/
+type Proof struct {
+	Type               string `json:"type"`
+	Cryptosuite        string `json:"cryptosuite"`
+	VerificationMethod string `json:"verificationMethod"`
+	Created            string `json:"created,omitempty"`
+	ProofPurpose       string `json:"proofPurpose"`
+	ProofValue         string `json:"proofValue"`
+}
+
+// check validates the context, type and subject of the credential for origin,
+// and returns the did of its subject.
+func (c *credential) check(origin string) (string, error) {
+	if !hasContext(c.Context, ConfigurationContext) {
+		return "", fmt.Errorf("invalid credential context")
+	}
+	if !contains(c.Type, "VerifiableCredential") || !contains(c.Type, "DomainLinkageCredential") {
+		return "", fmt.Errorf("not a domain linkage credential: '%s'", strings.Join(c.Type, ", "))
+	}
+	subject, err := Origin(c.CredentialSubject.Origin)
+	if err != nil {
+		return "", err
+	}
+	if subject != origin {
+		return "", fmt.Errorf("credential origin does not match: '%s'", c.CredentialSubject.Origin)
+	}
+	if _, err := resolver.Parse(c.CredentialSubject.ID); err != nil {
+		return "", fmt.Errorf("invalid credential subject: '%s'", c.CredentialSubject.ID)
+	}
+	return c.CredentialSubject.ID, nil
+}
+
+// issuer returns the id of the credential issuer, which is either a string or
+// an object with an id.
+func (c *credential) issuer() string {
+	var id string
+	if err := json.Unmarshal(c.Issuer, &id); err == nil {
+		return id
+	}
+	var object struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(c.Issuer, &object); err == nil {
+		return object.ID
+	}
+	return ""
+}
+
+// VerifyCredential verifies a Domain Linkage Credential for origin, and returns
+// the did that it links. The credential is either a JWT string signed with a
+// JWS algorithm in algorithms, or a json-ld credential with an eddsa-jcs-2022
+// or ecdsa-jcs-2019 Data Integrity proof. Suites that require RDF dataset
+// canonicalization are not supported. The signing key must be an
+// assertionMethod of the did.
+func (v *Verifier) VerifyCredential(origin string, data json.RawMessage) (string, error) {
+	origin, err := Origin(origin)
+	if err != nil {
+		return "", err
+	}
+	var token string
+	if err := json.Unmarshal(data, &token); err == nil {
+		return v.verifyJWT(origin, token)
+	}
+	return v.verifyLinkedData(origin, data)
+}
+
+// verifyJWT verifies the JWT form of a Domain Linkage Credential.
+// See https://identity.foundation/.well-known/resources/did-configuration/#json-web-token-proof-format
+func (v *Verifier) verifyJWT(origin, token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("invalid jwt")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return "", err
+	}
+	var claims struct {
+		Iss string      `json:"iss"`
+		Sub string      `json:"sub"`
+		Nbf *float64    `json:"nbf"`
+		Exp *float64    `json:"exp"`
+		VC  *credential `json:"vc"`
+	}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return "", err
+	}
+	if claims.VC == nil {
+		return "", fmt.Errorf("jwt has no vc claim")
+	}
+	did, err := claims.VC.check(origin)
+	if err != nil {
+		return "", err
+	}
+	if claims.Iss != did || claims.Sub != did {
+		return "", fmt.Errorf("jwt issuer and subject must be the credential subject: '%s'", did)
+	}
+	now := v.now()
+	if claims.Exp == nil || now.After(time.Unix(int64(*claims.Exp), 0)) {
+		return "", fmt.Errorf("jwt expired or has no expiration")
+	}
+	if claims.Nbf != nil && now.Before(time.Unix(int64(*claims.Nbf), 0)) {
+		return "", fmt.Errorf("jwt not valid yet")
+	}
+	code, ok := algorithms[header.Alg]
+	if !ok {
+		return "", fmt.Errorf("unsupported jwt algorithm: '%s'", header.Alg)
+	}
+	if !strings.HasPrefix(header.Kid, did+"#") {
+		return "", fmt.Errorf("jwt key is not a key of the issuer: '%s'", header.Kid)
+	}
+	key, err := v.assertionKey(header.Kid)
+	if err != nil {
+		return "", err
+	}
+	if key.Code != code {
+		return "", fmt.Errorf("jwt key type does not match algorithm '%s': '%s'", header.Alg, key.Name())
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", fmt.Errorf("invalid jwt signature: %v", err)
+	}
+	if err := key.Verify([]byte(parts[0]+"."+parts[1]), lowS(key, sig)); err != nil {
+		return "", err
+	}
+	return did, nil
+}
+
+func decodeSegment(segment string, v interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("invalid jwt: %v", err)
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("invalid jwt: %v", err)
+	}
+	return nil
+}
+
+// verifyLinkedData verifies the json-ld form of a Domain Linkage Credential.
+// See https://identity.foundation/.well-known/resources/did-configuration/#linked-data-proof-format
+func (v *Verifier) verifyLinkedData(origin string, data json.RawMessage) (string, error) {
+	var c credential
+	if err := json.Unmarshal(data, &c); err != nil {
+		return "", fmt.Errorf("invalid credential: %v", err)
+	}
+	did, err := c.check(origin)
+	if err != nil {
+		return "", err
+	}
+	if c.issuer() != did {
+		return "", fmt.Errorf("credential issuer must be the credential subject: '%s'", did)
+	}
+	now := v.now()
+	issued, err := time.Parse(time.RFC3339, c.IssuanceDate)
+	if err != nil || now.Before(issued) {
+		return "", fmt.Errorf("invalid credential issuance date: '%s'", c.IssuanceDate)
+	}
+	expires, err := time.Parse(time.RFC3339, c.ExpirationDate)
+	if err != nil || now.After(expires) {
+		return "", fmt.Errorf("credential expired or has no expiration: '%s'", c.ExpirationDate)
+	}
+
+	var proof Proof
+	if err := json.Unmarshal(c.Proof, &proof); err != nil {
+		return "", fmt.Errorf("invalid credential proof: %v", err)
+	}
+	if proof.Type != "DataIntegrityProof" {
+		return "", fmt.Errorf("unsupported proof type: '%s'", proof.Type)
+	}
+	if proof.ProofPurpose != "assertionMethod" {
+		return "", fmt.Errorf("unsupported proof purpose: '%s'", proof.ProofPurpose)
+	}
+	if !strings.HasPrefix(proof.VerificationMethod, did+"#") {
+		return "", fmt.Errorf("proof key is not a key of the issuer: '%s'", proof.VerificationMethod)
+	}
+	key, err := v.assertionKey(proof.VerificationMethod)
+	if err != nil {
+		return "", err
+	}
+	_, sig, err := mbase.Decode(proof.ProofValue)
+	if err != nil {
+		return "", fmt.Errorf("invalid proof value: %v", err)
+	}
+
+	// The unsecured credential is the credential without its proof, and the
+	// proof configuration is the proof without its value.
+	var unsecured, config map[string]interface{}
+	if err := json.Unmarshal(data, &unsecured); err != nil {
+		return "", err
+	}
+	delete(unsecured, "proof")
+	if err := json.Unmarshal(c.Proof, &config); err != nil {
+		return "", err
+	}
+	delete(config, "proofValue")
+	hashData, err := proofHashData(proof.Cryptosuite, key, config, unsecured)
+	if err != nil {
+		return "", err
+	}
+	if err := key.Verify(hashData, lowS(key, sig)); err != nil {
+		return "", err
+	}
+	return did, nil
+}
+
+// proofHashData returns the data signed by a jcs Data Integrity proof: the
+// hash of the canonical proof configuration followed by the hash of the
+// canonical credential.
+// See https://www.w3.org/TR/vc-di-eddsa/#eddsa-jcs-2022 and
+// https://www.w3.org/TR/vc-di-ecdsa/#ecdsa-jcs-2019
+func proofHashData(cryptosuite string, key *multikey.Key, config, unsecured interface{}) ([]byte, error) {
+	hash := func(data []byte) []byte {
+		sum := sha256.Sum256(data)
+		return sum[:]
+	}
+	switch {
+	case cryptosuite == "eddsa-jcs-2022" && key.Code == codec.Ed25519Pub:
+	case cryptosuite == "ecdsa-jcs-2019" && key.Code == codec.P256Pub:
+	case cryptosuite == "ecdsa-jcs-2019" && key.Code == codec.P384Pub:
+		hash = func(data []byte) []byte {
+			sum := sha512.Sum384(data)
+			return sum[:]
+		}
+	default:
+		return nil, fmt.Errorf("unsupported cryptosuite '%s' for key type '%s'", cryptosuite, key.Name())
+	}
+	var hashes []byte
+	for _, value := range []interface{}{config, unsecured} {
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		canonical, err := jcs.Canonicalize(data)
+		if err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash(canonical)...)
+	}
+	return hashes, nil
+}
+
+// assertionKey resolves the key of the verification method id, which must be
+// an assertionMethod of its did.
+func (v *Verifier) assertionKey(id string) (*multikey.Key, error) {
+	did := strings.SplitN(id, "#", 2)[0]
+	_, doc, _, err := v.registry.Resolve(did, nil)
+	if err != nil {
+		return nil, err
+	}
+	matches := func(vm resolver.VerificationMethod) bool {
+		return vm.ID == id || (strings.HasPrefix(vm.ID, "#") && doc.ID+vm.ID == id)
+	}
+	var method *resolver.VerificationMethod
+	authorized := false
+	for i, vm := range doc.AssertionMethod {
+		if matches(vm) {
+			authorized = true
+			if !vm.IsReference() {
+				method = &doc.AssertionMethod[i]
+			}
+		}
+	}
+	if !authorized {
+		return nil, fmt.Errorf("not an assertion method: '%s'", id)
+	}
+	for i, vm := range doc.VerificationMethod {
+		if method == nil && matches(vm) {
+			method = &doc.VerificationMethod[i]
+		}
+	}
+	if method == nil {
+		return nil, fmt.Errorf("verification method not found: '%s'", id)
+	}
+	key, err := jwk.FromVerificationMethod(*method)
+	if err != nil {
+		return nil, err
+	}
+	return jwk.ToKey(key)
+}
+
+// lowS returns an ECDSA signature of a NIST curve key with s in the low half
+// of the group order, which multikey requires. Both forms are valid ECDSA
+// signatures, and JWS and ecdsa-jcs-2019 do not require low-S.
+func lowS(key *multikey.Key, sig []byte) []byte {
+	var curve elliptic.Curve
+	switch key.Code {
+	case codec.P256Pub:
+		curve = elliptic.P256()
+	case codec.P384Pub:
+		curve = elliptic.P384()
+	default:
+		return sig
+	}
+	n := curve.Params().N
+	size := (n.BitLen() + 7) / 8
+	if len(sig) != 2*size {
+		return sig
+	}
+	s := new(big.Int).SetBytes(sig[size:])
+	if s.Cmp(new(big.Int).Rsh(n, 1)) <= 0 {
+		return sig
+	}
+	normalized := append([]byte{}, sig[:size]...)
+	return append(normalized, s.Sub(n, s).FillBytes(make([]byte, size))...)
+}
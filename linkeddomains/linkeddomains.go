@@ -0,0 +1,220 @@
+// Package linkeddomains provides tools for verifying that dids and web origins
+// control each other, with the DID Configuration resource of an origin and the
+// LinkedDomains services of a did document:
+// https://identity.foundation/.well-known/resources/did-configuration/
+// Copyright 2021 Textile
+package linkeddomains
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/textileio/go-did-resolver/resolver"
+	"github.com/textileio/go-did-resolver/web"
+)
+
+// ConfigurationPath is the well-known path of the DID Configuration resource.
+const ConfigurationPath = "/.well-known/did-configuration.json"
+
+// ConfigurationContext is the json-ld context of DID Configuration resources
+// and Domain Linkage Credentials.
+const ConfigurationContext = "https://identity.foundation/.well-known/did-configuration/v1"
+
+// ServiceType is the type of the service entries that list the origins linked
+// to a did.
+const ServiceType = "LinkedDomains"
+
+// Configuration is a DID Configuration resource. Each linked did is a Domain
+// Linkage Credential, either a JWT string or a json-ld credential object.
+type Configuration struct {
+	Context    json.RawMessage   `json:"@context"`
+	LinkedDIDs []json.RawMessage `json:"linked_dids"`
+}
+
+// Verifier verifies the linkage of dids and origins, resolving dids with a
+// Registry and fetching DID Configuration resources with a web.Resolver.
+type Verifier struct {
+	registry resolver.Registry
+	web      *web.Resolver
+	now      func() time.Time
+}
+
+// New creates and returns a new Verifier, which resolves dids with registry
+// and fetches resources with a web.Resolver configured by opts.
+func New(registry resolver.Registry, opts ...web.Option) *Verifier {
+	return &Verifier{registry: registry, web: web.New(opts...), now: time.Now}
+}
+
+// Fetch fetches the DID Configuration resource of origin.
+func (v *Verifier) Fetch(origin string) (*Configuration, error) {
+	origin, err := Origin(origin)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := v.web.Get(origin + ConfigurationPath)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Body == nil {
+		return nil, fmt.Errorf("empty did configuration")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, resolver.NewError(resolver.NotFound, "did configuration not found: '%s'", origin)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch did configuration: '%s'", resp.Status)
+	}
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	var config Configuration
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	if !hasContext(config.Context, ConfigurationContext) {
+		return nil, fmt.Errorf("invalid did configuration context")
+	}
+	return &config, nil
+}
+
+// VerifyOrigin returns the dids with a valid Domain Linkage Credential in the
+// DID Configuration resource of origin. Invalid credentials are ignored.
+func (v *Verifier) VerifyOrigin(origin string) ([]string, error) {
+	config, err := v.Fetch(origin)
+	if err != nil {
+		return nil, err
+	}
+	var dids []string
+	for _, credential := range config.LinkedDIDs {
+		did, err := v.VerifyCredential(origin, credential)
+		if err == nil && !contains(dids, did) {
+			dids = append(dids, did)
+		}
+	}
+	return dids, nil
+}
+
+// VerifyDID returns the origins of the LinkedDomains services of did, whose
+// DID Configuration resources link back to it.
+func (v *Verifier) VerifyDID(did string) ([]string, error) {
+	origins, err := v.LinkedDomains(did)
+	if err != nil {
+		return nil, err
+	}
+	var verified []string
+	for _, origin := range origins {
+		if err := v.verifyConfiguration(did, origin); err == nil {
+			verified = append(verified, origin)
+		}
+	}
+	return verified, nil
+}
+
+// Verify checks the linkage of did and origin in both directions: the did
+// document has a LinkedDomains service with origin, and the DID Configuration
+// resource of origin has a valid Domain Linkage Credential for did.
+func (v *Verifier) Verify(did, origin string) error {
+	origin, err := Origin(origin)
+	if err != nil {
+		return err
+	}
+	origins, err := v.LinkedDomains(did)
+	if err != nil {
+		return err
+	}
+	if !contains(origins, origin) {
+		return fmt.Errorf("origin not linked from did document: '%s'", origin)
+	}
+	return v.verifyConfiguration(did, origin)
+}
+
+// verifyConfiguration checks that the DID Configuration resource of origin
+// has a valid Domain Linkage Credential for did.
+func (v *Verifier) verifyConfiguration(did, origin string) error {
+	config, err := v.Fetch(origin)
+	if err != nil {
+		return err
+	}
+	var errs []string
+	for _, credential := range config.LinkedDIDs {
+		subject, err := v.VerifyCredential(origin, credential)
+		if err != nil {
+			errs = append(errs, err.Error())
+		} else if subject == did {
+			return nil
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("did not linked from origin '%s': %s", origin, strings.Join(errs, "; "))
+	}
+	return fmt.Errorf("did not linked from origin: '%s'", origin)
+}
+
+// LinkedDomains returns the origins of the LinkedDomains services of the
+// document of did.
+func (v *Verifier) LinkedDomains(did string) ([]string, error) {
+	_, doc, _, err := v.registry.Resolve(did, nil)
+	if err != nil {
+		return nil, err
+	}
+	var origins []string
+	for _, service := range doc.Service {
+		if service.Type != ServiceType {
+			continue
+		}
+		origin, err := Origin(service.ServiceEndpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid linked domain '%s': %v", service.ID, err)
+		}
+		origins = append(origins, origin)
+	}
+	return origins, nil
+}
+
+// Origin returns the normalized form of a web origin: an https url with a
+// host, an optional port, and no path.
+func Origin(origin string) (string, error) {
+	u, err := url.Parse(origin)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "https" || u.Host == "" || u.User != nil || (u.Path != "" && u.Path != "/") || u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("invalid origin: '%s'", origin)
+	}
+	return "https://" + strings.ToLower(u.Host), nil
+}
+
+// hasContext reports whether a json-ld @context, a string or a list, includes
+// context.
+func hasContext(data json.RawMessage, context string) bool {
+	var contexts []interface{}
+	if err := json.Unmarshal(data, &contexts); err != nil {
+		var single string
+		if err := json.Unmarshal(data, &single); err != nil {
+			return false
+		}
+		return single == context
+	}
+	for _, c := range contexts {
+		if c == context {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,385 @@
+package linkeddomains
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	mbase "github.com/multiformats/go-multibase"
+	codec "github.com/multiformats/go-multicodec"
+	"github.com/textileio/go-did-resolver/jcs"
+	"github.com/textileio/go-did-resolver/keys"
+	"github.com/textileio/go-did-resolver/multikey"
+	"github.com/textileio/go-did-resolver/resolver"
+	"github.com/textileio/go-did-resolver/web"
+)
+
+const (
+	testOrigin = "https://example.com"
+	webDID     = "did:web:example.com"
+)
+
+// signer signs credentials with the key of a verification method.
+type signer struct {
+	id   string
+	alg  string
+	sign func(data []byte) []byte
+}
+
+func ed25519Signer(t *testing.T) (signer, string) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := (&multikey.Key{Code: codec.Ed25519Pub, Bytes: pub}).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	did := "did:key:" + encoded
+	return signer{did + "#" + encoded, "EdDSA", func(data []byte) []byte {
+		return ed25519.Sign(priv, data)
+	}}, did
+}
+
+func p256Signer(t *testing.T, id string) (signer, string) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := (&multikey.Key{Code: codec.P256Pub, Bytes: multikey.Compress(priv.X, priv.Y, 32)}).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer{id, "ES256", func(data []byte) []byte {
+		digest := sha256.Sum256(data)
+		r, s, err := ecdsa.Sign(rand.Reader, priv, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	}}, encoded
+}
+
+func p384Signer(t *testing.T, id string) (signer, string) {
+	priv, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded, err := (&multikey.Key{Code: codec.P384Pub, Bytes: multikey.Compress(priv.X, priv.Y, 48)}).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer{id, "ES384", func(data []byte) []byte {
+		digest := sha512.Sum384(data)
+		r, s, err := ecdsa.Sign(rand.Reader, priv, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return append(r.FillBytes(make([]byte, 48)), s.FillBytes(make([]byte, 48))...)
+	}}, encoded
+}
+
+func domainCredential(did, origin string, issued time.Time) map[string]interface{} {
+	return map[string]interface{}{
+		"@context":       []string{"https://www.w3.org/2018/credentials/v1", ConfigurationContext},
+		"type":           []string{"VerifiableCredential", "DomainLinkageCredential"},
+		"issuer":         did,
+		"issuanceDate":   issued.UTC().Format(time.RFC3339),
+		"expirationDate": issued.Add(time.Hour).UTC().Format(time.RFC3339),
+		"credentialSubject": map[string]interface{}{
+			"id":     did,
+			"origin": origin,
+		},
+	}
+}
+
+func encodeSegment(t *testing.T, v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// jwtCredential signs a Domain Linkage Credential in the JWT form.
+func jwtCredential(t *testing.T, s signer, did, origin string, issued time.Time) json.RawMessage {
+	header := encodeSegment(t, map[string]string{"alg": s.alg, "kid": s.id, "typ": "JWT"})
+	claims := encodeSegment(t, map[string]interface{}{
+		"iss": did,
+		"sub": did,
+		"nbf": issued.Unix(),
+		"exp": issued.Add(time.Hour).Unix(),
+		"vc":  domainCredential(did, origin, issued),
+	})
+	token := header + "." + claims + "." + base64.RawURLEncoding.EncodeToString(s.sign([]byte(header+"."+claims)))
+	data, err := json.Marshal(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// ldCredential signs a Domain Linkage Credential with a jcs Data Integrity
+// proof.
+func ldCredential(t *testing.T, s signer, suite string, credential map[string]interface{}) json.RawMessage {
+	proof := map[string]interface{}{
+		"type":               "DataIntegrityProof",
+		"cryptosuite":        suite,
+		"verificationMethod": s.id,
+		"proofPurpose":       "assertionMethod",
+		"created":            time.Now().UTC().Format(time.RFC3339),
+	}
+	var hashData []byte
+	for _, value := range []interface{}{proof, credential} {
+		data, err := json.Marshal(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		canonical, err := jcs.Canonicalize(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.alg == "ES384" {
+			sum := sha512.Sum384(canonical)
+			hashData = append(hashData, sum[:]...)
+		} else {
+			sum := sha256.Sum256(canonical)
+			hashData = append(hashData, sum[:]...)
+		}
+	}
+	value, err := mbase.Encode(mbase.Base58BTC, s.sign(hashData))
+	if err != nil {
+		t.Fatal(err)
+	}
+	proof["proofValue"] = value
+	credential["proof"] = proof
+	data, err := json.Marshal(credential)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// fixture is an httptest stand-in for https://example.com, which serves the
+// did document of did:web:example.com and a DID Configuration resource.
+type fixture struct {
+	server   *httptest.Server
+	verifier *Verifier
+	key      signer
+	web      signer
+	p384     signer
+	keyDID   string
+	doc      *resolver.Document
+	linked   []json.RawMessage
+}
+
+func newFixture(t *testing.T) *fixture {
+	f := &fixture{}
+	f.key, f.keyDID = ed25519Signer(t)
+	var encoded, p384 string
+	f.web, encoded = p256Signer(t, webDID+"#key-1")
+	f.p384, p384 = p384Signer(t, webDID+"#key-2")
+	f.doc = &resolver.Document{
+		Context: []string{"https://www.w3.org/ns/did/v1"},
+		ID:      webDID,
+		VerificationMethod: []resolver.VerificationMethod{{
+			ID:                 "#key-1",
+			Type:               "Multikey",
+			Controller:         webDID,
+			PublicKeyMultibase: encoded,
+		}, {
+			ID:                 "#key-2",
+			Type:               "Multikey",
+			Controller:         webDID,
+			PublicKeyMultibase: p384,
+		}},
+		AssertionMethod: []resolver.VerificationMethod{resolver.Reference("#key-1"), resolver.Reference("#key-2")},
+		Service: []resolver.ServiceEndpoint{{
+			ID:              webDID + "#linked-domain",
+			Type:            ServiceType,
+			ServiceEndpoint: testOrigin,
+		}},
+	}
+	f.server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/.well-known/did.json":
+			json.NewEncoder(w).Encode(f.doc)
+		case ConfigurationPath:
+			json.NewEncoder(w).Encode(map[string]interface{}{
+				"@context":    ConfigurationContext,
+				"linked_dids": f.linked,
+			})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	registry := resolver.New([]resolver.Resolver{keys.New(), web.New(web.WithClient(f.server.Client()), web.WithCacheSize(0))}, false)
+	f.verifier = New(registry, web.WithClient(f.server.Client()))
+	return f
+}
+
+func TestVerifyOrigin(t *testing.T) {
+	f := newFixture(t)
+	defer f.server.Close()
+	issued := time.Now().Add(-time.Minute)
+	f.linked = []json.RawMessage{
+		jwtCredential(t, f.key, f.keyDID, testOrigin, issued),
+		ldCredential(t, f.web, "ecdsa-jcs-2019", domainCredential(webDID, testOrigin, issued)),
+		jwtCredential(t, f.key, f.keyDID, "https://example.org", issued),
+	}
+	dids, err := f.verifier.VerifyOrigin(testOrigin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dids) != 2 || dids[0] != f.keyDID || dids[1] != webDID {
+		t.Errorf("unexpected linked dids: %v", dids)
+	}
+}
+
+func TestVerifyCredential(t *testing.T) {
+	f := newFixture(t)
+	defer f.server.Close()
+	issued := time.Now().Add(-time.Minute)
+	tests := []struct {
+		name       string
+		credential json.RawMessage
+		did        string
+	}{
+		{"jwt eddsa", jwtCredential(t, f.key, f.keyDID, testOrigin, issued), f.keyDID},
+		{"jwt es256", jwtCredential(t, f.web, webDID, testOrigin, issued), webDID},
+		{"eddsa-jcs-2022", ldCredential(t, f.key, "eddsa-jcs-2022", domainCredential(f.keyDID, testOrigin, issued)), f.keyDID},
+		{"ecdsa-jcs-2019", ldCredential(t, f.web, "ecdsa-jcs-2019", domainCredential(webDID, testOrigin, issued)), webDID},
+		{"jwt es384", jwtCredential(t, f.p384, webDID, testOrigin, issued), webDID},
+		{"ecdsa-jcs-2019 p-384", ldCredential(t, f.p384, "ecdsa-jcs-2019", domainCredential(webDID, testOrigin, issued)), webDID},
+	}
+	for _, test := range tests {
+		did, err := f.verifier.VerifyCredential(testOrigin+"/", test.credential)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+		} else if did != test.did {
+			t.Errorf("%s: unexpected did: %s", test.name, did)
+		}
+	}
+}
+
+func TestVerifyInvalidCredential(t *testing.T) {
+	f := newFixture(t)
+	defer f.server.Close()
+	issued := time.Now().Add(-time.Minute)
+	other, otherDID := ed25519Signer(t)
+
+	tampered := domainCredential(f.keyDID, testOrigin, issued)
+	signed := ldCredential(t, f.key, "eddsa-jcs-2022", tampered)
+	tampered["expirationDate"] = issued.Add(2 * time.Hour).UTC().Format(time.RFC3339)
+	tamperedData, err := json.Marshal(tampered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token := string(jwtCredential(t, f.key, f.keyDID, testOrigin, issued))
+	parts := strings.Split(strings.Trim(token, `"`), ".")
+	forged, err := json.Marshal(parts[0] + "." + strings.Split(strings.Trim(string(jwtCredential(t, other, otherDID, testOrigin, issued)), `"`), ".")[1] + "." + parts[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	notAssertion := *f.doc
+	notAssertion.AssertionMethod = nil
+
+	tests := []struct {
+		name       string
+		credential json.RawMessage
+	}{
+		{"wrong origin", jwtCredential(t, f.key, f.keyDID, "https://example.org", issued)},
+		{"expired jwt", jwtCredential(t, f.key, f.keyDID, testOrigin, issued.Add(-2*time.Hour))},
+		{"future jwt", jwtCredential(t, f.key, f.keyDID, testOrigin, issued.Add(time.Hour))},
+		{"expired ld", ldCredential(t, f.key, "eddsa-jcs-2022", domainCredential(f.keyDID, testOrigin, issued.Add(-2*time.Hour)))},
+		{"key of another did", jwtCredential(t, other, f.keyDID, testOrigin, issued)},
+		{"forged claims", forged},
+		{"tampered ld", tamperedData},
+		{"wrong suite", ldCredential(t, f.key, "ecdsa-jcs-2019", domainCredential(f.keyDID, testOrigin, issued))},
+		{"not a credential", json.RawMessage(`{"type": ["VerifiableCredential"]}`)},
+		{"not a jwt", json.RawMessage(`"abc"`)},
+	}
+	for _, test := range tests {
+		if _, err := f.verifier.VerifyCredential(testOrigin, test.credential); err == nil {
+			t.Errorf("%s: expected VerifyCredential to return error", test.name)
+		}
+	}
+	if _, err := f.verifier.VerifyCredential(testOrigin, signed); err != nil {
+		t.Errorf("expected untampered credential to verify: %v", err)
+	}
+
+	f.doc = &notAssertion
+	if _, err := f.verifier.VerifyCredential(testOrigin, jwtCredential(t, f.web, webDID, testOrigin, issued)); err == nil {
+		t.Error("expected VerifyCredential to return error for a key that is not an assertion method")
+	}
+}
+
+func TestVerifyLinkage(t *testing.T) {
+	f := newFixture(t)
+	defer f.server.Close()
+	issued := time.Now().Add(-time.Minute)
+	f.linked = []json.RawMessage{
+		jwtCredential(t, f.key, f.keyDID, testOrigin, issued),
+		jwtCredential(t, f.web, webDID, testOrigin, issued),
+	}
+
+	if err := f.verifier.Verify(webDID, "https://Example.com"); err != nil {
+		t.Error(err)
+	}
+	origins, err := f.verifier.VerifyDID(webDID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(origins) != 1 || origins[0] != testOrigin {
+		t.Errorf("unexpected origins: %v", origins)
+	}
+	// did:key documents have no LinkedDomains services.
+	if err := f.verifier.Verify(f.keyDID, testOrigin); err == nil {
+		t.Error("expected Verify to return error")
+	}
+
+	// The did document links the origin, but the origin does not link back.
+	f.linked = f.linked[:1]
+	if err := f.verifier.Verify(webDID, testOrigin); err == nil {
+		t.Error("expected Verify to return error")
+	}
+	origins, err = f.verifier.VerifyDID(webDID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(origins) != 0 {
+		t.Errorf("unexpected origins: %v", origins)
+	}
+}
+
+func TestOrigin(t *testing.T) {
+	tests := map[string]string{
+		"https://example.com":      "https://example.com",
+		"https://Example.com/":     "https://example.com",
+		"https://example.com:8443": "https://example.com:8443",
+		"http://example.com":       "",
+		"https://example.com/path": "",
+		"https://user@example.com": "",
+		"https://example.com/?a=b": "",
+		"example.com":              "",
+	}
+	for origin, expected := range tests {
+		observed, err := Origin(origin)
+		if expected == "" && err == nil {
+			t.Errorf("expected Origin to return error for: %s", origin)
+		}
+		if expected != "" && observed != expected {
+			t.Errorf("unexpected origin for %s: %s %v", origin, observed, err)
+		}
+	}
+}
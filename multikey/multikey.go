@@ -0,0 +1,88 @@
+// Package multikey provides tools for decoding multibase encoded, multicodec
+// prefixed public keys, as used by did:key and other did methods.
+// See https://w3c-ccg.github.io/did-method-key/#format
+// Copyright 2021 Textile
+package multikey
+
+import (
+	mbase "github.com/multiformats/go-multibase"
+	codec "github.com/multiformats/go-multicodec"
+	varint "github.com/multiformats/go-varint"
+)
+
+// RSAPub is the multicodec code for a DER-encoded PKCS#1 RSA public key.
+// https://github.com/multiformats/multicodec/blob/master/table.csv
+// {name:"rsa-pub", tag:"key", code:0x1205, description:"RSA public key. DER-encoded ASN.1 type RSAPublicKey according to IETF RFC 8017 (PKCS #1)"}
+const RSAPub codec.Code = 0x1205
+
+// MinRSAModulusSize is the smallest RSA modulus, in bits, accepted for RSA
+// public keys.
+const MinRSAModulusSize = 2048
+
+// keyLengths maps the multicodec codes of fixed size public keys to their
+// expected length in bytes. Elliptic curve points are expected to be in
+// compressed form.
+var keyLengths = map[codec.Code]int{
+	codec.Ed25519Pub:     32,
+	codec.X25519Pub:      32,
+	codec.Secp256k1Pub:   33,
+	codec.P256Pub:        33,
+	codec.P384Pub:        49,
+	codec.P521Pub:        67,
+	codec.Ed448Pub:       57,
+	codec.Bls12_381G1Pub: 48,
+	codec.Bls12_381G2Pub: 96,
+}
+
+// Key is a multicodec public key.
+type Key struct {
+	// Code is the multicodec code identifying the key type.
+	Code codec.Code
+	// Bytes is the raw public key, without the multicodec prefix.
+	Bytes []byte
+}
+
+// Decode decodes a multibase encoded, multicodec prefixed public key.
+func Decode(str string) (*Key, error) {
+	_, bytes, err := mbase.Decode(str)
+	if err != nil {
+		return nil, err
+	}
+	return FromBytes(bytes)
+}
+
+// FromBytes decodes a multicodec prefixed public key, and checks that it is
+// valid for its key type.
+func FromBytes(bytes []byte) (*Key, error) {
+	code, n, err := varint.FromUvarint(bytes)
+	if err != nil {
+		return nil, err
+	}
+	key := &Key{
+		Code:  codec.Code(code),
+		Bytes: bytes[n:],
+	}
+	if err := key.Validate(); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Name returns the multicodec name of the key type.
+func (k *Key) Name() string {
+	if k.Code == RSAPub {
+		return "rsa-pub"
+	}
+	return k.Code.String()
+}
+
+// Prefixed returns the multicodec prefixed bytes representation of the key.
+func (k *Key) Prefixed() []byte {
+	return append(varint.ToUvarint(uint64(k.Code)), k.Bytes...)
+}
+
+// Encode returns the base58 btc multibase encoding of the multicodec prefixed
+// key, as used for did:key fingerprints.
+func (k *Key) Encode() (string, error) {
+	return mbase.Encode(mbase.Base58BTC, k.Prefixed())
+}
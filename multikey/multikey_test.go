@@ -0,0 +1,279 @@
+// Package multikey provides tools for decoding multibase encoded, multicodec
+// prefixed public keys, as used by did:key and other did methods.
+// Copyright 2021 Textile
+package multikey
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"testing"
+
+	secp256k1 "github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secp256k1ecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	codec "github.com/multiformats/go-multicodec"
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+func TestDecodeEd25519(t *testing.T) {
+	fingerprint := "z6MktvqCyLxTsXUH1tUZncNdVeEZ7hNh7npPRbUU27GTrYb8"
+	key, err := Decode(fingerprint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Code != codec.Ed25519Pub {
+		t.Errorf("expected ed25519 public key, got: %s", key.Name())
+	}
+	if len(key.Bytes) != 32 {
+		t.Errorf("expected 32 byte key, got: %d", len(key.Bytes))
+	}
+	encoded, err := key.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if encoded != fingerprint {
+		t.Errorf("expected round trip to match, got: %s", encoded)
+	}
+}
+
+func TestDecodeSecp256k1(t *testing.T) {
+	key, err := Decode("zQ3shbgnTGcgBpXPdBjDur3ATMDWhS7aPs6FRFkWR19Lb9Zwz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Code != codec.Secp256k1Pub {
+		t.Errorf("expected secp256k1 public key, got: %s", key.Name())
+	}
+	if len(key.Bytes) != 33 {
+		t.Errorf("expected 33 byte key, got: %d", len(key.Bytes))
+	}
+}
+
+func TestOneByteCode(t *testing.T) {
+	// 0x55 is the single byte "raw" multicodec
+	key, err := FromBytes([]byte{0x55, 1, 2, 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Code != codec.Raw || len(key.Bytes) != 3 {
+		t.Error("expected one byte code to be parsed")
+	}
+}
+
+func TestInvalidKeyLength(t *testing.T) {
+	key := &Key{Code: codec.Ed25519Pub, Bytes: make([]byte, 31)}
+	_, err := FromBytes(key.Prefixed())
+	if err == nil || err.Error() != "invalid ed25519-pub key length: 31" {
+		t.Error("expected FromBytes to return error")
+	}
+}
+
+func TestRSAName(t *testing.T) {
+	key := &Key{Code: RSAPub}
+	if key.Name() != "rsa-pub" {
+		t.Errorf("expected rsa-pub, got: %s", key.Name())
+	}
+}
+
+func TestValidateEd25519(t *testing.T) {
+	// The public keys of the test vectors of RFC 8032, section 7.1.
+	for _, pub := range []string{
+		"d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a",
+		"3d4017c3e843895a92b70aa74d1b7ebc9c982ccf2ec4968cc0cd55f12af4660c",
+		"fc51cd8e6218a1a38da47ed00230f0580816ed13ba3303ac5deb911548908025",
+		"278117fc144c72340f67d0f2316e8386ceffbf2b2428c9c51fef7c597f1d426e",
+		"ec172b93ad5e563bf4932c70e1245034c35467ef2efd4d64ebf819683467e2bf",
+	} {
+		bytes, _ := hex.DecodeString(pub)
+		key := &Key{Code: codec.Ed25519Pub, Bytes: bytes}
+		if err := key.Validate(); err != nil {
+			t.Errorf("%s: %v", pub, err)
+		}
+	}
+
+	nonCanonical := make([]byte, 32)
+	for i := range nonCanonical {
+		nonCanonical[i] = 0xff
+	}
+	nonCanonical[0] = 0xee
+	nonCanonical[31] = 0x7f
+	// x = 0 with the sign bit set
+	negativeZero := make([]byte, 32)
+	negativeZero[0] = 1
+	negativeZero[31] = 0x80
+	// y = 2 does not have a corresponding x
+	notOnCurve := make([]byte, 32)
+	notOnCurve[0] = 2
+	tests := []struct {
+		name  string
+		bytes string
+		err   string
+	}{
+		{"non-canonical", hex.EncodeToString(nonCanonical), "invalid ed25519 point: non-canonical encoding"},
+		{"negative zero", hex.EncodeToString(negativeZero), "invalid ed25519 point: non-canonical encoding"},
+		{"not-on-curve", hex.EncodeToString(notOnCurve), "invalid ed25519 point: not on curve"},
+	}
+	// The points of small order, from "Taming the many EdDSAs".
+	// See https://eprint.iacr.org/2020/1244
+	for _, point := range []string{
+		"0100000000000000000000000000000000000000000000000000000000000000",
+		"ecffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff7f",
+		"0000000000000000000000000000000000000000000000000000000000000000",
+		"0000000000000000000000000000000000000000000000000000000000000080",
+		"c7176a703d4dd84fba3c0b760d10670f2a2053fa2c39ccc64ec7fd7792ac037a",
+		"c7176a703d4dd84fba3c0b760d10670f2a2053fa2c39ccc64ec7fd7792ac03fa",
+		"26e8958fc2b227b045c3f489f2ef98f0d5dfac05d3c63339b13802886d53fc05",
+		"26e8958fc2b227b045c3f489f2ef98f0d5dfac05d3c63339b13802886d53fc85",
+	} {
+		tests = append(tests, struct {
+			name  string
+			bytes string
+			err   string
+		}{"small order " + point, point, "invalid ed25519 point: small order"})
+	}
+	for _, tt := range tests {
+		bytes, _ := hex.DecodeString(tt.bytes)
+		key := &Key{Code: codec.Ed25519Pub, Bytes: bytes}
+		err := key.Validate()
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%s: expected Validate to return error, got: %v", tt.name, err)
+		}
+	}
+}
+
+func TestValidateSecp256k1(t *testing.T) {
+	valid, _ := hex.DecodeString("02d41c913f542ae671e6df17a456c7dd6a280479d491120629daf92130602cd135")
+	key := &Key{Code: codec.Secp256k1Pub, Bytes: valid}
+	if err := key.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	badPrefix := append([]byte{0x04}, valid[1:]...)
+	// x = 5 gives x^3 + 7 = 132, which is not a square mod p
+	notOnCurve := make([]byte, 33)
+	notOnCurve[0] = 0x02
+	notOnCurve[32] = 5
+	tests := []struct {
+		name  string
+		bytes []byte
+		err   string
+	}{
+		{"prefix", badPrefix, "invalid secp256k1 point encoding: 0x04"},
+		{"not-on-curve", notOnCurve, "invalid secp256k1 point: not on curve"},
+	}
+	for _, tt := range tests {
+		key := &Key{Code: codec.Secp256k1Pub, Bytes: tt.bytes}
+		err := key.Validate()
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("%s: expected Validate to return error, got: %v", tt.name, err)
+		}
+		var e *resolver.Error
+		if !errors.As(err, &e) || e.Code != resolver.InvalidPublicKey {
+			t.Errorf("%s: expected invalidPublicKey error", tt.name)
+		}
+	}
+}
+
+func TestVerifySecp256k1(t *testing.T) {
+	private, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := &Key{Code: codec.Secp256k1Pub, Bytes: private.PubKey().SerializeCompressed()}
+	data := []byte("hello")
+	digest := sha256.Sum256(data)
+	// Compact signatures are the recovery code followed by r and s, in the
+	// canonical low-S form.
+	sig := secp256k1ecdsa.SignCompact(private, digest[:], true)[1:]
+	if err := key.Verify(data, sig); err != nil {
+		t.Fatal(err)
+	}
+	if err := key.Verify([]byte("goodbye"), sig); err == nil {
+		t.Error("expected Verify to return error")
+	}
+	// The high-S form of the same signature is malleable, and rejected.
+	var s secp256k1.ModNScalar
+	s.SetByteSlice(sig[32:])
+	s.Negate().PutBytesUnchecked(sig[32:])
+	if err := key.Verify(data, sig); err == nil || err.Error() != "invalid signature: high s" {
+		t.Errorf("expected Verify to return error, got: %v", err)
+	}
+}
+
+func TestVerifyP256(t *testing.T) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := &Key{Code: codec.P256Pub, Bytes: elliptic.MarshalCompressed(elliptic.P256(), private.X, private.Y)}
+	data := []byte("hello")
+	digest := sha256.Sum256(data)
+	r, s, err := ecdsa.Sign(rand.Reader, private, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := elliptic.P256().Params().N
+	if s.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		s.Sub(n, s)
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	if err := key.Verify(data, sig); err != nil {
+		t.Fatal(err)
+	}
+	if err := key.Verify(data, sig[:63]); err == nil || err.Error() != "invalid signature length: 63" {
+		t.Errorf("expected Verify to return error, got: %v", err)
+	}
+}
+
+func TestVerifyEd25519(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := &Key{Code: codec.Ed25519Pub, Bytes: public}
+	sig := ed25519.Sign(private, []byte("hello"))
+	if err := key.Verify([]byte("hello"), sig); err != nil {
+		t.Fatal(err)
+	}
+	if err := key.Verify([]byte("goodbye"), sig); err == nil {
+		t.Error("expected Verify to return error")
+	}
+}
+
+// FuzzDecode checks that decoding arbitrary input never panics, and that any
+// key that decodes successfully round trips.
+func FuzzDecode(f *testing.F) {
+	f.Add("z6MktvqCyLxTsXUH1tUZncNdVeEZ7hNh7npPRbUU27GTrYb8")
+	f.Add("zQ3shbgnTGcgBpXPdBjDur3ATMDWhS7aPs6FRFkWR19Lb9Zwz")
+	f.Add("z6LSnkZe3JZPCo88XsdQVJi8j1TomzX2yRW7ZnvvWhmrSdmG")
+	f.Add("z3D")
+	f.Fuzz(func(t *testing.T, str string) {
+		key, err := Decode(str)
+		if err != nil {
+			return
+		}
+		decoded, err := FromBytes(key.Prefixed())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if decoded.Code != key.Code || !bytes.Equal(decoded.Bytes, key.Bytes) {
+			t.Error("expected round trip to match")
+		}
+	})
+}
+
+// FuzzFromBytes checks that validating arbitrary key material never panics.
+func FuzzFromBytes(f *testing.F) {
+	f.Add([]byte{0xed, 0x01})
+	f.Add([]byte{0xe7, 0x01, 0x02})
+	f.Fuzz(func(t *testing.T, data []byte) {
+		FromBytes(data)
+	})
+}
@@ -0,0 +1,130 @@
+// Package multikey provides tools for decoding multibase encoded, multicodec
+// prefixed public keys, as used by did:key and other did methods.
+// See https://w3c-ccg.github.io/did-method-key/#format
+// Copyright 2021 Textile
+package multikey
+
+import (
+	"crypto/elliptic"
+	"crypto/subtle"
+	"math/big"
+
+	"filippo.io/edwards25519"
+	secp256k1 "github.com/decred/dcrd/dcrec/secp256k1/v4"
+	codec "github.com/multiformats/go-multicodec"
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+// invalid returns an invalidPublicKey resolution error.
+func invalid(format string, args ...interface{}) error {
+	return resolver.NewError(resolver.InvalidPublicKey, format, args...)
+}
+
+// Validate checks that the key is well formed for its key type: it must have
+// the expected length, and elliptic curve keys must be valid compressed
+// points. Ed25519 keys of small order are rejected. Key types that are not
+// known are not checked.
+func (k *Key) Validate() error {
+	if size, ok := keyLengths[k.Code]; ok && len(k.Bytes) != size {
+		return invalid("invalid %s key length: %d", k.Name(), len(k.Bytes))
+	}
+	switch k.Code {
+	case codec.Ed25519Pub:
+		return validateEd25519(k.Bytes)
+	case codec.Secp256k1Pub:
+		return validateSecp256k1(k.Bytes)
+	case codec.P256Pub:
+		return validateNIST(elliptic.P256(), k)
+	case codec.P384Pub:
+		return validateNIST(elliptic.P384(), k)
+	case codec.P521Pub:
+		return validateNIST(elliptic.P521(), k)
+	}
+	return nil
+}
+
+// validateNIST checks that bytes is a valid compressed point on curve.
+func validateNIST(curve elliptic.Curve, k *Key) error {
+	if x, _ := elliptic.UnmarshalCompressed(curve, k.Bytes); x == nil {
+		return invalid("invalid %s point", k.Name())
+	}
+	return nil
+}
+
+// validateSecp256k1 checks that bytes is a valid compressed secp256k1 point.
+func validateSecp256k1(bytes []byte) error {
+	_, err := decompressSecp256k1(bytes)
+	return err
+}
+
+// decompressSecp256k1 parses a compressed secp256k1 point.
+func decompressSecp256k1(bytes []byte) (*secp256k1.PublicKey, error) {
+	if bytes[0] != 0x02 && bytes[0] != 0x03 {
+		return nil, invalid("invalid secp256k1 point encoding: 0x%02x", bytes[0])
+	}
+	var x, y secp256k1.FieldVal
+	if overflow := x.SetByteSlice(bytes[1:]); overflow {
+		return nil, invalid("invalid secp256k1 point: x out of range")
+	}
+	if !secp256k1.DecompressY(&x, bytes[0] == 0x03, &y) {
+		return nil, invalid("invalid secp256k1 point: not on curve")
+	}
+	y.Normalize()
+	return secp256k1.NewPublicKey(&x, &y), nil
+}
+
+// Point returns the affine coordinates of a secp256k1, P-256, P-384 or P-521
+// public key.
+func (k *Key) Point() (*big.Int, *big.Int, error) {
+	if err := k.Validate(); err != nil {
+		return nil, nil, err
+	}
+	var curve elliptic.Curve
+	switch k.Code {
+	case codec.Secp256k1Pub:
+		key, err := decompressSecp256k1(k.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+		return key.X(), key.Y(), nil
+	case codec.P256Pub:
+		curve = elliptic.P256()
+	case codec.P384Pub:
+		curve = elliptic.P384()
+	case codec.P521Pub:
+		curve = elliptic.P521()
+	default:
+		return nil, nil, invalid("%s is not an elliptic curve point", k.Name())
+	}
+	x, y := elliptic.UnmarshalCompressed(curve, k.Bytes)
+	return x, y, nil
+}
+
+// Compress encodes the affine coordinates of an elliptic curve point in
+// compressed form, padding x to size bytes.
+func Compress(x, y *big.Int, size int) []byte {
+	bytes := make([]byte, size+1)
+	bytes[0] = byte(0x02 | y.Bit(0))
+	x.FillBytes(bytes[1:])
+	return bytes
+}
+
+// validateEd25519 checks that bytes is a canonical encoding of an ed25519
+// point, and that the point is not of small order.
+// See https://tools.ietf.org/html/rfc8032#section-5.1.3
+func validateEd25519(bytes []byte) error {
+	p, err := new(edwards25519.Point).SetBytes(bytes)
+	if err != nil {
+		return invalid("invalid ed25519 point: not on curve")
+	}
+	// SetBytes accepts y >= p, and x = 0 with the sign bit set, which RFC 8032
+	// rejects.
+	if subtle.ConstantTimeCompare(p.Bytes(), bytes) != 1 {
+		return invalid("invalid ed25519 point: non-canonical encoding")
+	}
+	// Multiplying by the cofactor sends small order points to the identity.
+	if new(edwards25519.Point).MultByCofactor(p).Equal(edwards25519.NewIdentityPoint()) == 1 {
+		return invalid("invalid ed25519 point: small order")
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+// Package multikey provides tools for decoding multibase encoded, multicodec
+// prefixed public keys, as used by did:key and other did methods.
+// See https://w3c-ccg.github.io/did-method-key/#format
+// Copyright 2021 Textile
+package multikey
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"math/big"
+
+	secp256k1 "github.com/decred/dcrd/dcrec/secp256k1/v4"
+	secp256k1ecdsa "github.com/decred/dcrd/dcrec/secp256k1/v4/ecdsa"
+	codec "github.com/multiformats/go-multicodec"
+)
+
+// Verify checks a signature of data made with the key. Ed25519 signatures are
+// checked as per RFC 8032. ECDSA signatures are the 64, 96 or 132 byte
+// concatenation of r and s over the SHA-256 (secp256k1 and P-256), SHA-384 or
+// SHA-512 digest of data, and must be in the canonical low-S form.
+func (k *Key) Verify(data, sig []byte) error {
+	if err := k.Validate(); err != nil {
+		return err
+	}
+	switch k.Code {
+	case codec.Ed25519Pub:
+		if !ed25519.Verify(k.Bytes, data, sig) {
+			return fmt.Errorf("invalid signature")
+		}
+		return nil
+	case codec.Secp256k1Pub:
+		digest := sha256.Sum256(data)
+		return verifySecp256k1(k, digest[:], sig)
+	case codec.P256Pub:
+		digest := sha256.Sum256(data)
+		return verifyNIST(elliptic.P256(), k, digest[:], sig)
+	case codec.P384Pub:
+		digest := sha512.Sum384(data)
+		return verifyNIST(elliptic.P384(), k, digest[:], sig)
+	case codec.P521Pub:
+		digest := sha512.Sum512(data)
+		return verifyNIST(elliptic.P521(), k, digest[:], sig)
+	}
+	return fmt.Errorf("unsupported signature key type: '%s'", k.Name())
+}
+
+// splitSignature splits a signature into r and s, checking that both are in
+// range and that s is in the low half of the group order.
+func splitSignature(sig []byte, n *big.Int) (*big.Int, *big.Int, error) {
+	size := (n.BitLen() + 7) / 8
+	if len(sig) != 2*size {
+		return nil, nil, fmt.Errorf("invalid signature length: %d", len(sig))
+	}
+	r := new(big.Int).SetBytes(sig[:size])
+	s := new(big.Int).SetBytes(sig[size:])
+	if r.Sign() == 0 || s.Sign() == 0 || r.Cmp(n) >= 0 || s.Cmp(n) >= 0 {
+		return nil, nil, fmt.Errorf("invalid signature")
+	}
+	if s.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		return nil, nil, fmt.Errorf("invalid signature: high s")
+	}
+	return r, s, nil
+}
+
+// verifyNIST checks an ECDSA signature on a NIST curve.
+func verifyNIST(curve elliptic.Curve, k *Key, digest, sig []byte) error {
+	r, s, err := splitSignature(sig, curve.Params().N)
+	if err != nil {
+		return err
+	}
+	x, y := elliptic.UnmarshalCompressed(curve, k.Bytes)
+	if !ecdsa.Verify(&ecdsa.PublicKey{Curve: curve, X: x, Y: y}, digest, r, s) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
+
+// verifySecp256k1 checks an ECDSA signature on the secp256k1 curve, which the
+// standard library does not provide.
+func verifySecp256k1(k *Key, digest, sig []byte) error {
+	if _, _, err := splitSignature(sig, secp256k1.S256().N); err != nil {
+		return err
+	}
+	key, err := decompressSecp256k1(k.Bytes)
+	if err != nil {
+		return err
+	}
+	var r, s secp256k1.ModNScalar
+	r.SetByteSlice(sig[:32])
+	s.SetByteSlice(sig[32:])
+	if !secp256k1ecdsa.NewSignature(&r, &s).Verify(digest, key) {
+		return fmt.Errorf("invalid signature")
+	}
+	return nil
+}
@@ -0,0 +1,232 @@
+// Package peer provides tools for resolving and creating did:peer identifiers
+// for pairwise and n-wise relationships, using numalgo 0, 2 and 4:
+// https://identity.foundation/peer-did-method-spec/
+// Copyright 2021 Textile
+package peer
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/textileio/go-did-resolver/multikey"
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+// Purpose is the purpose code of an element of a numalgo 2 did:peer.
+type Purpose byte
+
+const (
+	// Assertion keys are used in assertionMethod.
+	Assertion Purpose = 'A'
+	// Encryption keys are used in keyAgreement.
+	Encryption Purpose = 'E'
+	// Verification keys are used in authentication.
+	Verification Purpose = 'V'
+	// CapabilityInvocation keys are used in capabilityInvocation.
+	CapabilityInvocation Purpose = 'I'
+	// CapabilityDelegation keys are used in capabilityDelegation.
+	CapabilityDelegation Purpose = 'D'
+	// Service elements are abbreviated service endpoints.
+	Service Purpose = 'S'
+)
+
+// PurposeKey is a key and the purpose it serves in a numalgo 2 did:peer.
+type PurposeKey struct {
+	Purpose Purpose
+	Key     *multikey.Key
+}
+
+// abbreviations are the service types that are shortened when encoding
+// numalgo 2 services. Member names are shortened by abbreviatedService.
+var abbreviations = map[string]string{
+	"DIDCommMessaging": "dm",
+}
+
+var encoding = base64.RawURLEncoding
+
+// abbreviatedService is the json encoding of a numalgo 2 service.
+type abbreviatedService struct {
+	ID              string          `json:"id,omitempty"`
+	Type            string          `json:"t"`
+	ServiceEndpoint json.RawMessage `json:"s"`
+	RoutingKeys     []string        `json:"r,omitempty"`
+	Accept          []string        `json:"a,omitempty"`
+}
+
+// abbreviatedEndpoint is the object form of a numalgo 2 service endpoint.
+type abbreviatedEndpoint struct {
+	URI         string   `json:"uri"`
+	RoutingKeys []string `json:"r,omitempty"`
+	Accept      []string `json:"a,omitempty"`
+}
+
+// NewNumalgo2 creates a numalgo 2 did:peer from a set of keys and services.
+func NewNumalgo2(keys []PurposeKey, services []resolver.ServiceEndpoint) (string, error) {
+	var b strings.Builder
+	b.WriteString("did:peer:2")
+	for _, key := range keys {
+		if strings.IndexByte("AEVID", byte(key.Purpose)) < 0 {
+			return "", fmt.Errorf("invalid key purpose: '%c'", key.Purpose)
+		}
+		if err := key.Key.Validate(); err != nil {
+			return "", err
+		}
+		encoded, err := key.Key.Encode()
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte('.')
+		b.WriteByte(byte(key.Purpose))
+		b.WriteString(encoded)
+	}
+	for _, service := range services {
+		encoded, err := encodeService(service)
+		if err != nil {
+			return "", err
+		}
+		b.WriteByte('.')
+		b.WriteByte(byte(Service))
+		b.WriteString(encoded)
+	}
+	return b.String(), nil
+}
+
+// resolveNumalgo2 creates a did Document from the inline keys and services of
+// a numalgo 2 did:peer.
+func resolveNumalgo2(did, elements string) (*resolver.Document, error) {
+	doc := &resolver.Document{
+		Context: []string{
+			"https://www.w3.org/ns/did/v1",
+			"https://w3id.org/security/multikey/v1",
+		},
+		ID: did,
+	}
+	for _, element := range strings.Split(strings.TrimPrefix(elements, "."), ".") {
+		if len(element) < 2 {
+			return nil, fmt.Errorf("invalid did:peer element: '%s'", element)
+		}
+		purpose, value := Purpose(element[0]), element[1:]
+		if purpose == Service {
+			service, err := decodeService(value)
+			if err != nil {
+				return nil, err
+			}
+			if service.ID == "" {
+				service.ID = "#service"
+				if n := len(doc.Service); n > 0 {
+					service.ID = fmt.Sprintf("#service-%d", n)
+				}
+			}
+			if strings.HasPrefix(service.ID, "#") {
+				service.ID = did + service.ID
+			}
+			doc.Service = append(doc.Service, *service)
+			continue
+		}
+		key, err := multikey.Decode(value)
+		if err != nil {
+			return nil, err
+		}
+		keyID := fmt.Sprintf("%s#key-%d", did, len(doc.VerificationMethod)+1)
+		vm, err := verificationMethod(key, keyID, did)
+		if err != nil {
+			return nil, err
+		}
+		ref := resolver.Reference(keyID)
+		switch purpose {
+		case Assertion:
+			doc.AssertionMethod = append(doc.AssertionMethod, ref)
+		case Encryption:
+			doc.KeyAgreement = append(doc.KeyAgreement, ref)
+		case Verification:
+			doc.Authentication = append(doc.Authentication, ref)
+		case CapabilityInvocation:
+			doc.CapabilityInvocation = append(doc.CapabilityInvocation, ref)
+		case CapabilityDelegation:
+			doc.CapabilityDelegation = append(doc.CapabilityDelegation, ref)
+		default:
+			return nil, fmt.Errorf("invalid key purpose: '%c'", purpose)
+		}
+		doc.VerificationMethod = append(doc.VerificationMethod, vm)
+	}
+	return doc, nil
+}
+
+// encodeService abbreviates and base64url encodes a service.
+func encodeService(service resolver.ServiceEndpoint) (string, error) {
+	abbreviated := abbreviatedService{
+		ID:   service.ID,
+		Type: abbreviate(service.Type),
+	}
+	var endpoint interface{} = service.ServiceEndpoint
+	if len(service.RoutingKeys) > 0 || len(service.Accept) > 0 {
+		endpoint = abbreviatedEndpoint{
+			URI:         service.ServiceEndpoint,
+			RoutingKeys: service.RoutingKeys,
+			Accept:      service.Accept,
+		}
+	}
+	data, err := json.Marshal(endpoint)
+	if err != nil {
+		return "", err
+	}
+	abbreviated.ServiceEndpoint = data
+	data, err = json.Marshal(abbreviated)
+	if err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(data), nil
+}
+
+// decodeService decodes and expands an abbreviated service. Both the legacy
+// string and the object service endpoint forms are accepted.
+func decodeService(encoded string) (*resolver.ServiceEndpoint, error) {
+	// Tolerate padding, which some implementations include.
+	data, err := encoding.DecodeString(strings.TrimRight(encoded, "="))
+	if err != nil {
+		return nil, err
+	}
+	var abbreviated abbreviatedService
+	if err := json.Unmarshal(data, &abbreviated); err != nil {
+		return nil, err
+	}
+	service := &resolver.ServiceEndpoint{
+		ID:          abbreviated.ID,
+		Type:        expand(abbreviated.Type),
+		RoutingKeys: abbreviated.RoutingKeys,
+		Accept:      abbreviated.Accept,
+	}
+	var uri string
+	if err := json.Unmarshal(abbreviated.ServiceEndpoint, &uri); err == nil {
+		service.ServiceEndpoint = uri
+		return service, nil
+	}
+	var endpoint abbreviatedEndpoint
+	if err := json.Unmarshal(abbreviated.ServiceEndpoint, &endpoint); err != nil {
+		return nil, fmt.Errorf("invalid service endpoint: %v", err)
+	}
+	service.ServiceEndpoint = endpoint.URI
+	service.RoutingKeys = endpoint.RoutingKeys
+	service.Accept = endpoint.Accept
+	return service, nil
+}
+
+// abbreviate returns the abbreviated form of a well known value.
+func abbreviate(value string) string {
+	if abbreviated, ok := abbreviations[value]; ok {
+		return abbreviated
+	}
+	return value
+}
+
+// expand returns the full form of an abbreviated value.
+func expand(value string) string {
+	for full, abbreviated := range abbreviations {
+		if abbreviated == value {
+			return full
+		}
+	}
+	return value
+}
@@ -0,0 +1,138 @@
+// Package peer provides tools for resolving and creating did:peer identifiers
+// for pairwise and n-wise relationships, using numalgo 0, 2 and 4:
+// https://identity.foundation/peer-did-method-spec/
+// Copyright 2021 Textile
+package peer
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	mbase "github.com/multiformats/go-multibase"
+	codec "github.com/multiformats/go-multicodec"
+	mh "github.com/multiformats/go-multihash"
+	varint "github.com/multiformats/go-varint"
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+// NewNumalgo4 creates the long and short forms of a numalgo 4 did:peer from
+// an input document. The document should not have an id, and may use
+// relative ids such as "#key-1" for its verification methods and services.
+func NewNumalgo4(doc *resolver.Document) (long string, short string, err error) {
+	if doc.ID != "" {
+		return "", "", fmt.Errorf("input document must not have an id")
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return "", "", err
+	}
+	// Drop the empty id member, the document is identified by its hash.
+	var members map[string]interface{}
+	if err := json.Unmarshal(data, &members); err != nil {
+		return "", "", err
+	}
+	delete(members, "id")
+	data, err = json.Marshal(members)
+	if err != nil {
+		return "", "", err
+	}
+	encoded, err := mbase.Encode(mbase.Base58BTC, append(varint.ToUvarint(uint64(codec.Json)), data...))
+	if err != nil {
+		return "", "", err
+	}
+	hash, err := hashDocument(encoded)
+	if err != nil {
+		return "", "", err
+	}
+	short = "did:peer:4" + hash
+	return short + ":" + encoded, short, nil
+}
+
+// hashDocument returns the multibase encoded sha2-256 multihash of an encoded
+// document.
+func hashDocument(encoded string) (string, error) {
+	hash, err := mh.Sum([]byte(encoded), mh.SHA2_256, -1)
+	if err != nil {
+		return "", err
+	}
+	return mbase.Encode(mbase.Base58BTC, hash)
+}
+
+// resolveNumalgo4 resolves the long form of a numalgo 4 did:peer from its
+// embedded document, or the short form from a previously seen long form.
+func (r *Resolver) resolveNumalgo4(did, id string) (*resolver.Document, error) {
+	parts := strings.SplitN(id, ":", 2)
+	hash := parts[0]
+	short := "did:peer:4" + hash
+	long := did
+	if len(parts) == 1 {
+		seen, ok := r.seen.get(short)
+		if !ok {
+			return nil, resolver.NewError(resolver.NotFound, "unknown short form did: '%s'", short)
+		}
+		long = seen
+		parts = strings.SplitN(strings.TrimPrefix(long, "did:peer:4"), ":", 2)
+	}
+	encoded := parts[1]
+	expected, err := hashDocument(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if expected != hash {
+		return nil, fmt.Errorf("document hash does not match did")
+	}
+	_, data, err := mbase.Decode(encoded)
+	if err != nil {
+		return nil, err
+	}
+	code, n, err := varint.FromUvarint(data)
+	if err != nil {
+		return nil, err
+	}
+	if code != uint64(codec.Json) {
+		return nil, fmt.Errorf("unsupported document encoding: '%s'", codec.Code(code).String())
+	}
+	var doc resolver.Document
+	if err := json.Unmarshal(data[n:], &doc); err != nil {
+		return nil, err
+	}
+	if doc.ID != "" {
+		return nil, fmt.Errorf("input document must not have an id")
+	}
+	r.seen.add(short, long)
+
+	// The document is contextualized with the did that was requested, and
+	// also known by the other form.
+	doc.ID = did
+	doc.AlsoKnownAs = append(doc.AlsoKnownAs, short)
+	if did == short {
+		doc.AlsoKnownAs[len(doc.AlsoKnownAs)-1] = long
+	}
+	for i := range doc.VerificationMethod {
+		vm := &doc.VerificationMethod[i]
+		vm.ID = resolver.AbsoluteID(did, vm.ID)
+		if vm.Controller == "" {
+			vm.Controller = did
+		}
+	}
+	for _, relationship := range [][]resolver.VerificationMethod{
+		doc.Authentication,
+		doc.AssertionMethod,
+		doc.CapabilityInvocation,
+		doc.CapabilityDelegation,
+		doc.KeyAgreement,
+	} {
+		for i := range relationship {
+			vm := &relationship[i]
+			vm.ID = resolver.AbsoluteID(did, vm.ID)
+			if !vm.IsReference() && vm.Controller == "" {
+				vm.Controller = did
+			}
+		}
+	}
+	for i := range doc.Service {
+		doc.Service[i].ID = resolver.AbsoluteID(did, doc.Service[i].ID)
+	}
+	return &doc, nil
+}
@@ -0,0 +1,116 @@
+// Package peer provides tools for resolving and creating did:peer identifiers
+// for pairwise and n-wise relationships, using numalgo 0, 2 and 4:
+// https://identity.foundation/peer-did-method-spec/
+// Copyright 2021 Textile
+package peer
+
+import (
+	"fmt"
+
+	codec "github.com/multiformats/go-multicodec"
+	"github.com/ockam-network/did"
+	"github.com/textileio/go-did-resolver/multikey"
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+// Resolver resolves did:peer identifiers. Short form numalgo 4 dids can only
+// be resolved once their long form has been resolved by the same Resolver,
+// which remembers a bounded number of them.
+type Resolver struct {
+	// seen holds the long form of recently resolved numalgo 4 dids.
+	seen *seenDIDs
+}
+
+// New creates and returns a new peer Resolver, configured by opts.
+func New(opts ...Option) *Resolver {
+	c := &config{seenSize: DefaultSeenSize}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return &Resolver{
+		seen: newSeenDIDs(c.seenSize),
+	}
+}
+
+// Method returns the method that this resolver is capable of resolving.
+func (r *Resolver) Method() string {
+	return "peer"
+}
+
+// Resolve is the primary resolution method for this resolver.
+func (r *Resolver) Resolve(did string, parsed *did.DID, res resolver.Resolver) (*resolver.Document, error) {
+	if parsed.Method != r.Method() {
+		return nil, fmt.Errorf("unknown did method: '%s'", parsed.Method)
+	}
+	id := fmt.Sprintf("did:peer:%s", parsed.ID)
+	switch parsed.ID[0] {
+	case '0':
+		return resolveNumalgo0(id, parsed.ID[1:])
+	case '2':
+		return resolveNumalgo2(id, parsed.ID[1:])
+	case '4':
+		return r.resolveNumalgo4(id, parsed.ID[1:])
+	default:
+		return nil, fmt.Errorf("unsupported numalgo: '%c'", parsed.ID[0])
+	}
+}
+
+// NewNumalgo0 creates a numalgo 0 did:peer from an inception key.
+func NewNumalgo0(key *multikey.Key) (string, error) {
+	if err := key.Validate(); err != nil {
+		return "", err
+	}
+	encoded, err := key.Encode()
+	if err != nil {
+		return "", err
+	}
+	return "did:peer:0" + encoded, nil
+}
+
+// resolveNumalgo0 creates a did Document from an inception key, in the same
+// way as for did:key.
+func resolveNumalgo0(did, encoded string) (*resolver.Document, error) {
+	key, err := multikey.Decode(encoded)
+	if err != nil {
+		return nil, err
+	}
+	keyID := fmt.Sprintf("%s#%s", did, encoded)
+	doc := &resolver.Document{
+		Context: []string{
+			"https://www.w3.org/ns/did/v1",
+			"https://w3id.org/security/multikey/v1",
+		},
+		ID: did,
+	}
+	vm, err := verificationMethod(key, keyID, did)
+	if err != nil {
+		return nil, err
+	}
+	doc.VerificationMethod = []resolver.VerificationMethod{vm}
+	ref := []resolver.VerificationMethod{resolver.Reference(keyID)}
+	if key.Code == codec.X25519Pub {
+		doc.KeyAgreement = ref
+	} else {
+		doc.Authentication = ref
+		doc.AssertionMethod = ref
+		doc.CapabilityInvocation = ref
+		doc.CapabilityDelegation = ref
+	}
+	return doc, nil
+}
+
+// verificationMethod expresses a key as a Multikey verification method.
+func verificationMethod(key *multikey.Key, id, controller string) (resolver.VerificationMethod, error) {
+	encoded, err := key.Encode()
+	if err != nil {
+		return resolver.VerificationMethod{}, err
+	}
+	return resolver.VerificationMethod{
+		ID:                 id,
+		Type:               "Multikey",
+		Controller:         controller,
+		PublicKeyMultibase: encoded,
+	}, nil
+}
+
+var _ resolver.Resolver = (*Resolver)(nil)
@@ -0,0 +1,286 @@
+// Package peer provides tools for resolving and creating did:peer identifiers
+// for pairwise and n-wise relationships, using numalgo 0, 2 and 4:
+// https://identity.foundation/peer-did-method-spec/
+// Copyright 2021 Textile
+package peer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ockam-network/did"
+	"github.com/textileio/go-did-resolver/multikey"
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+var (
+	Ed25519Key = "z6MktvqCyLxTsXUH1tUZncNdVeEZ7hNh7npPRbUU27GTrYb8"
+	X25519Key  = "z6LSnkZe3JZPCo88XsdQVJi8j1TomzX2yRW7ZnvvWhmrSdmG"
+)
+
+func resolve(t *testing.T, r *Resolver, id string) (*resolver.Document, error) {
+	parsed, err := did.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r.Resolve(id, parsed, r)
+}
+
+func TestUnknownMethod(t *testing.T) {
+	r := New()
+	_, err := resolve(t, r, "did:borg:0"+Ed25519Key)
+	if err == nil || err.Error() != "unknown did method: 'borg'" {
+		t.Error("expected Resolve to return error")
+	}
+}
+
+func TestUnsupportedNumalgo(t *testing.T) {
+	r := New()
+	_, err := resolve(t, r, "did:peer:1zQmZMygzYqNwU6Uhmewx5Xepf2VLp5S4HLSwwgf2aiKZuwa")
+	if err == nil || err.Error() != "unsupported numalgo: '1'" {
+		t.Error("expected Resolve to return error")
+	}
+}
+
+func TestNumalgo0(t *testing.T) {
+	key, err := multikey.Decode(Ed25519Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := NewNumalgo0(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if id != "did:peer:0"+Ed25519Key {
+		t.Errorf("unexpected did: %s", id)
+	}
+	r := New()
+	observed, err := resolve(t, r, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyID := id + "#" + Ed25519Key
+	ref := []resolver.VerificationMethod{resolver.Reference(keyID)}
+	expected := &resolver.Document{
+		Context: []string{
+			"https://www.w3.org/ns/did/v1",
+			"https://w3id.org/security/multikey/v1",
+		},
+		ID: id,
+		VerificationMethod: []resolver.VerificationMethod{
+			{
+				ID:                 keyID,
+				Type:               "Multikey",
+				Controller:         id,
+				PublicKeyMultibase: Ed25519Key,
+			},
+		},
+		Authentication:       ref,
+		AssertionMethod:      ref,
+		CapabilityInvocation: ref,
+		CapabilityDelegation: ref,
+	}
+	if !reflect.DeepEqual(observed, expected) {
+		t.Errorf("expected document to match: %s", expected.ID)
+	}
+}
+
+func TestNumalgo2(t *testing.T) {
+	ed25519, err := multikey.Decode(Ed25519Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x25519, err := multikey.Decode(X25519Key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	services := []resolver.ServiceEndpoint{
+		{
+			Type:            "DIDCommMessaging",
+			ServiceEndpoint: "https://example.com/didcomm",
+			Accept:          []string{"didcomm/v2"},
+		},
+		{
+			Type:            "LinkedDomains",
+			ServiceEndpoint: "https://example.com",
+		},
+	}
+	id, err := NewNumalgo2([]PurposeKey{
+		{Encryption, x25519},
+		{Verification, ed25519},
+	}, services)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(id, "did:peer:2.E"+X25519Key+".V"+Ed25519Key+".S") {
+		t.Errorf("unexpected did: %s", id)
+	}
+	r := New()
+	observed, err := resolve(t, r, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(observed.VerificationMethod) != 2 {
+		t.Fatalf("expected 2 verification methods, got: %d", len(observed.VerificationMethod))
+	}
+	if !reflect.DeepEqual(observed.KeyAgreement, []resolver.VerificationMethod{resolver.Reference(id + "#key-1")}) {
+		t.Error("expected key agreement to reference the first key")
+	}
+	if !reflect.DeepEqual(observed.Authentication, []resolver.VerificationMethod{resolver.Reference(id + "#key-2")}) {
+		t.Error("expected authentication to reference the second key")
+	}
+	services[0].ID = id + "#service"
+	services[1].ID = id + "#service-1"
+	if !reflect.DeepEqual(observed.Service, services) {
+		t.Errorf("expected services to match: %v", observed.Service)
+	}
+}
+
+func TestNumalgo2LegacyService(t *testing.T) {
+	// {"t":"dm","s":"https://example.com/endpoint","r":["did:example:somemediator#somekey"],"a":["didcomm/v2"]}
+	service := "eyJ0IjoiZG0iLCJzIjoiaHR0cHM6Ly9leGFtcGxlLmNvbS9lbmRwb2ludCIsInIiOlsiZGlkOmV4YW1wbGU6c29tZW1lZGlhdG9yI3NvbWVrZXkiXSwiYSI6WyJkaWRjb21tL3YyIl19"
+	id := "did:peer:2.V" + Ed25519Key + ".S" + service
+	r := New()
+	observed, err := resolve(t, r, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []resolver.ServiceEndpoint{
+		{
+			ID:              id + "#service",
+			Type:            "DIDCommMessaging",
+			ServiceEndpoint: "https://example.com/endpoint",
+			RoutingKeys:     []string{"did:example:somemediator#somekey"},
+			Accept:          []string{"didcomm/v2"},
+		},
+	}
+	if !reflect.DeepEqual(observed.Service, expected) {
+		t.Errorf("expected services to match: %v", observed.Service)
+	}
+}
+
+func TestNumalgo2InvalidPurpose(t *testing.T) {
+	r := New()
+	_, err := resolve(t, r, "did:peer:2.X"+Ed25519Key)
+	if err == nil || err.Error() != "invalid key purpose: 'X'" {
+		t.Error("expected Resolve to return error")
+	}
+}
+
+func TestNumalgo4(t *testing.T) {
+	input := &resolver.Document{
+		Context: []string{
+			"https://www.w3.org/ns/did/v1",
+			"https://w3id.org/security/multikey/v1",
+		},
+		VerificationMethod: []resolver.VerificationMethod{
+			{
+				ID:                 "#key-1",
+				Type:               "Multikey",
+				PublicKeyMultibase: Ed25519Key,
+			},
+		},
+		Authentication: []resolver.VerificationMethod{resolver.Reference("#key-1")},
+	}
+	long, short, err := NewNumalgo4(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(long, short+":z") {
+		t.Errorf("unexpected long form: %s", long)
+	}
+	r := New()
+	// The short form is unknown until the long form has been resolved
+	_, err = resolve(t, r, short)
+	if err == nil || err.Error() != "unknown short form did: '"+short+"'" {
+		t.Error("expected Resolve to return error")
+	}
+
+	observed, err := resolve(t, r, long)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &resolver.Document{
+		Context:     input.Context,
+		ID:          long,
+		AlsoKnownAs: []string{short},
+		VerificationMethod: []resolver.VerificationMethod{
+			{
+				ID:                 long + "#key-1",
+				Type:               "Multikey",
+				Controller:         long,
+				PublicKeyMultibase: Ed25519Key,
+			},
+		},
+		Authentication: []resolver.VerificationMethod{resolver.Reference(long + "#key-1")},
+	}
+	if !reflect.DeepEqual(observed, expected) {
+		t.Errorf("expected document to match: %s", expected.ID)
+	}
+
+	observed, err = resolve(t, r, short)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if observed.ID != short || !reflect.DeepEqual(observed.AlsoKnownAs, []string{long}) {
+		t.Error("expected short form document")
+	}
+}
+
+func TestNumalgo4HashMismatch(t *testing.T) {
+	first, _, err := NewNumalgo4(&resolver.Document{Controller: []string{"did:example:1"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _, err := NewNumalgo4(&resolver.Document{Controller: []string{"did:example:2"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The hash of the first with the document of the second
+	id := strings.SplitN(first, ":", 4)[2] + ":" + strings.SplitN(second, ":", 4)[3]
+	r := New()
+	_, err = resolve(t, r, "did:peer:"+id)
+	if err == nil || err.Error() != "document hash does not match did" {
+		t.Error("expected Resolve to return error")
+	}
+}
+
+func TestNumalgo4SeenSize(t *testing.T) {
+	r := New(WithSeenSize(2))
+	var longs, shorts []string
+	for _, controller := range []string{"did:example:1", "did:example:2", "did:example:3"} {
+		long, short, err := NewNumalgo4(&resolver.Document{Controller: []string{controller}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		longs, shorts = append(longs, long), append(shorts, short)
+	}
+	for _, long := range longs[:2] {
+		if _, err := resolve(t, r, long); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Using the first makes the second the least recently used, which is
+	// forgotten when the third is seen.
+	if _, err := resolve(t, r, shorts[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := resolve(t, r, longs[2]); err != nil {
+		t.Fatal(err)
+	}
+	for i, short := range shorts {
+		_, err := resolve(t, r, short)
+		if forgotten := err != nil; forgotten != (i == 1) {
+			t.Errorf("unexpected result for %s: %v", short, err)
+		}
+	}
+
+	r = New(WithSeenSize(0))
+	if _, err := resolve(t, r, longs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := resolve(t, r, shorts[0]); err == nil {
+		t.Error("expected Resolve to return error")
+	}
+}
@@ -0,0 +1,83 @@
+// Package peer provides tools for resolving and creating did:peer identifiers
+// for pairwise and n-wise relationships, using numalgo 0, 2 and 4:
+// https://identity.foundation/peer-did-method-spec/
+// Copyright 2021 Textile
+package peer
+
+import (
+	"container/list"
+	"sync"
+)
+
+// DefaultSeenSize is the default number of long form numalgo 4 dids that a
+// Resolver remembers to resolve their short form.
+const DefaultSeenSize = 1024
+
+// Option configures a Resolver.
+type Option func(*config)
+
+// config holds the settings of a Resolver.
+type config struct {
+	seenSize int
+}
+
+// WithSeenSize sets the number of long form numalgo 4 dids that the Resolver
+// remembers to resolve their short form, which is DefaultSeenSize by default.
+// The least recently used are forgotten first. Zero or less disables short
+// form resolution.
+func WithSeenSize(size int) Option {
+	return func(c *config) {
+		c.seenSize = size
+	}
+}
+
+// seenDID is a long form numalgo 4 did, with its short form.
+type seenDID struct {
+	short string
+	long  string
+}
+
+// seenDIDs is a bounded set of long form numalgo 4 dids, keyed by their short
+// form, that evicts the least recently used first.
+type seenDIDs struct {
+	lock    sync.Mutex
+	size    int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+func newSeenDIDs(size int) *seenDIDs {
+	return &seenDIDs{size: size, order: list.New(), entries: make(map[string]*list.Element)}
+}
+
+// get returns the long form of short, if it has been seen.
+func (s *seenDIDs) get(short string) (string, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	element, ok := s.entries[short]
+	if !ok {
+		return "", false
+	}
+	s.order.MoveToFront(element)
+	return element.Value.(*seenDID).long, true
+}
+
+// add records the long form of short.
+func (s *seenDIDs) add(short, long string) {
+	if s.size <= 0 {
+		return
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if element, ok := s.entries[short]; ok {
+		element.Value.(*seenDID).long = long
+		s.order.MoveToFront(element)
+		return
+	}
+	if s.order.Len() >= s.size {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*seenDID).short)
+	}
+	s.entries[short] = s.order.PushFront(&seenDID{short: short, long: long})
+}
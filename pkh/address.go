@@ -0,0 +1,104 @@
+// Package pkh provides tools for resolving the did:pkh method format, for
+// dids derived from blockchain account ids:
+// https://github.com/w3c-ccg/did-pkh/blob/main/did-pkh-method-draft.md
+// Copyright 2021 Textile
+package pkh
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+
+	mbase "github.com/multiformats/go-multibase"
+	"golang.org/x/crypto/sha3"
+)
+
+// ChecksumAddress returns the EIP-55 mixed-case checksum encoding of an
+// ethereum address.
+// See https://eips.ethereum.org/EIPS/eip-55
+func ChecksumAddress(address string) (string, error) {
+	if len(address) != 42 || !strings.HasPrefix(address, "0x") {
+		return "", fmt.Errorf("invalid ethereum address: '%s'", address)
+	}
+	lower := strings.ToLower(address[2:])
+	if _, err := hex.DecodeString(lower); err != nil {
+		return "", fmt.Errorf("invalid ethereum address: '%s'", address)
+	}
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write([]byte(lower))
+	sum := hash.Sum(nil)
+	checksummed := []byte(lower)
+	for i, c := range checksummed {
+		// Uppercase letters whose nibble in the hash is 8 or more.
+		nibble := sum[i/2] >> 4
+		if i%2 == 1 {
+			nibble = sum[i/2] & 0x0f
+		}
+		if c >= 'a' && nibble >= 8 {
+			checksummed[i] = c - 'a' + 'A'
+		}
+	}
+	return "0x" + string(checksummed), nil
+}
+
+// validateEthereumAddress checks an ethereum address. All lowercase and all
+// uppercase addresses carry no checksum and are accepted, as in EIP-55, and
+// lowercase addresses are used by Ceramic. Mixed-case addresses must have a
+// valid EIP-55 checksum.
+func validateEthereumAddress(address string) error {
+	checksummed, err := ChecksumAddress(address)
+	if err != nil {
+		return err
+	}
+	if address[2:] == strings.ToLower(address[2:]) || address[2:] == strings.ToUpper(address[2:]) {
+		return nil
+	}
+	if address != checksummed {
+		return fmt.Errorf("invalid ethereum address checksum: '%s'", address)
+	}
+	return nil
+}
+
+// validateSolanaAddress checks that a solana address is a base58 encoded
+// ed25519 public key.
+func validateSolanaAddress(address string) error {
+	_, key, err := mbase.Decode("z" + address)
+	if err != nil || len(key) != 32 {
+		return fmt.Errorf("invalid solana address: '%s'", address)
+	}
+	return nil
+}
+
+// tezosPrefixes are the base58check prefixes of implicit tezos account
+// addresses.
+var tezosPrefixes = map[string][]byte{
+	"tz1": {6, 161, 159},
+	"tz2": {6, 161, 161},
+	"tz3": {6, 161, 164},
+}
+
+// validateTezosAddress checks the base58check encoding of an implicit tezos
+// account address.
+func validateTezosAddress(address string) error {
+	if len(address) < 3 {
+		return fmt.Errorf("invalid tezos address: '%s'", address)
+	}
+	prefix, ok := tezosPrefixes[address[:3]]
+	if !ok {
+		return fmt.Errorf("unsupported tezos address: '%s'", address)
+	}
+	_, decoded, err := mbase.Decode("z" + address)
+	// prefix, 20 byte public key hash, 4 byte checksum
+	if err != nil || len(decoded) != len(prefix)+20+4 || !bytes.HasPrefix(decoded, prefix) {
+		return fmt.Errorf("invalid tezos address: '%s'", address)
+	}
+	payload, checksum := decoded[:len(decoded)-4], decoded[len(decoded)-4:]
+	first := sha256.Sum256(payload)
+	second := sha256.Sum256(first[:])
+	if !bytes.Equal(second[:4], checksum) {
+		return fmt.Errorf("invalid tezos address checksum: '%s'", address)
+	}
+	return nil
+}
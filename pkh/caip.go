@@ -0,0 +1,72 @@
+// Package pkh provides tools for resolving the did:pkh method format, for
+// dids derived from blockchain account ids:
+// https://github.com/w3c-ccg/did-pkh/blob/main/did-pkh-method-draft.md
+// Copyright 2021 Textile
+package pkh
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	// https://github.com/ChainAgnostic/CAIPs/blob/master/CAIPs/caip-2.md
+	namespacePattern = regexp.MustCompile(`^[-a-z0-9]{3,8}$`)
+	referencePattern = regexp.MustCompile(`^[-_a-zA-Z0-9]{1,32}$`)
+	// https://github.com/ChainAgnostic/CAIPs/blob/master/CAIPs/caip-10.md
+	addressPattern = regexp.MustCompile(`^[-.%a-zA-Z0-9]{1,128}$`)
+)
+
+// ChainID is a CAIP-2 blockchain id, such as "eip155:1".
+type ChainID struct {
+	Namespace string
+	Reference string
+}
+
+// ParseChainID parses a CAIP-2 blockchain id.
+func ParseChainID(str string) (*ChainID, error) {
+	parts := strings.Split(str, ":")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid chain id: '%s'", str)
+	}
+	if !namespacePattern.MatchString(parts[0]) {
+		return nil, fmt.Errorf("invalid chain id namespace: '%s'", parts[0])
+	}
+	if !referencePattern.MatchString(parts[1]) {
+		return nil, fmt.Errorf("invalid chain id reference: '%s'", parts[1])
+	}
+	return &ChainID{parts[0], parts[1]}, nil
+}
+
+func (c ChainID) String() string {
+	return c.Namespace + ":" + c.Reference
+}
+
+// AccountID is a CAIP-10 account id, such as
+// "eip155:1:0xab16a96d359ec26a11e2c2b3d8f8b8942d5bfcdb".
+type AccountID struct {
+	ChainID
+	Address string
+}
+
+// ParseAccountID parses a CAIP-10 account id.
+func ParseAccountID(str string) (*AccountID, error) {
+	i := strings.LastIndex(str, ":")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid account id: '%s'", str)
+	}
+	chainID, err := ParseChainID(str[:i])
+	if err != nil {
+		return nil, err
+	}
+	address := str[i+1:]
+	if !addressPattern.MatchString(address) {
+		return nil, fmt.Errorf("invalid account address: '%s'", address)
+	}
+	return &AccountID{*chainID, address}, nil
+}
+
+func (a AccountID) String() string {
+	return a.ChainID.String() + ":" + a.Address
+}
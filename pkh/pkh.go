@@ -0,0 +1,87 @@
+// Package pkh provides tools for resolving the did:pkh method format, for
+// dids derived from blockchain account ids:
+// https://github.com/w3c-ccg/did-pkh/blob/main/did-pkh-method-draft.md
+// Copyright 2021 Textile
+package pkh
+
+import (
+	"fmt"
+
+	"github.com/ockam-network/did"
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+// Resolver resolves did:pkh identifiers, without any network access.
+type Resolver struct{}
+
+// New creates and returns a new pkh Resolver.
+func New() *Resolver {
+	return &Resolver{}
+}
+
+// Method returns the method that this resolver is capable of resolving.
+func (r *Resolver) Method() string {
+	return "pkh"
+}
+
+// Resolve is the primary resolution method for this resolver.
+// Supported namespaces are eip155, solana and tezos.
+func (r *Resolver) Resolve(did string, parsed *did.DID, res resolver.Resolver) (*resolver.Document, error) {
+	if parsed.Method != r.Method() {
+		return nil, fmt.Errorf("unknown did method: '%s'", parsed.Method)
+	}
+	account, err := ParseAccountID(parsed.ID)
+	if err != nil {
+		return nil, err
+	}
+	id := fmt.Sprintf("did:pkh:%s", account)
+	vm := resolver.VerificationMethod{
+		ID:                  id + "#blockchainAccountId",
+		Controller:          id,
+		BlockchainAccountID: account.String(),
+	}
+	var context string
+	switch account.Namespace {
+	case "eip155":
+		if err := validateEthereumAddress(account.Address); err != nil {
+			return nil, err
+		}
+		vm.Type = "EcdsaSecp256k1RecoveryMethod2020"
+		context = "https://w3id.org/security/suites/secp256k1recovery-2020/v2"
+	case "solana":
+		if err := validateSolanaAddress(account.Address); err != nil {
+			return nil, err
+		}
+		// Solana addresses are ed25519 public keys.
+		vm.ID = id + "#controller"
+		vm.Type = "Ed25519VerificationKey2018"
+		vm.PublicKeyBase58 = account.Address
+		context = "https://w3id.org/security/suites/ed25519-2018/v1"
+	case "tezos":
+		if err := validateTezosAddress(account.Address); err != nil {
+			return nil, err
+		}
+		switch account.Address[:3] {
+		case "tz1":
+			vm.Type = "Ed25519PublicKeyBLAKE2BDigestSize20Base58CheckEncoded2021"
+		case "tz2":
+			vm.Type = "EcdsaSecp256k1RecoveryMethod2020"
+		case "tz3":
+			vm.Type = "P256PublicKeyBLAKE2BDigestSize20Base58CheckEncoded2021"
+		}
+		context = "https://w3id.org/security/v3-unstable"
+	default:
+		return nil, fmt.Errorf("unsupported namespace: '%s'", account.Namespace)
+	}
+	ref := []resolver.VerificationMethod{resolver.Reference(vm.ID)}
+	doc := &resolver.Document{
+		Context:            []string{"https://www.w3.org/ns/did/v1", context},
+		ID:                 id,
+		VerificationMethod: []resolver.VerificationMethod{vm},
+		Authentication:     ref,
+		AssertionMethod:    ref,
+	}
+	return doc, nil
+}
+
+var _ resolver.Resolver = (*Resolver)(nil)
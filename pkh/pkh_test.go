@@ -0,0 +1,153 @@
+// Package pkh provides tools for resolving the did:pkh method format, for
+// dids derived from blockchain account ids:
+// https://github.com/w3c-ccg/did-pkh/blob/main/did-pkh-method-draft.md
+// Copyright 2021 Textile
+package pkh
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/ockam-network/did"
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+func resolve(t *testing.T, id string) (*resolver.Document, error) {
+	parsed, err := did.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	return r.Resolve(id, parsed, r)
+}
+
+func TestUnknownMethod(t *testing.T) {
+	_, err := resolve(t, "did:borg:eip155:1:0xb9c5714089478a327f09197987f16f9e5d936e8a")
+	if err == nil || err.Error() != "unknown did method: 'borg'" {
+		t.Error("expected Resolve to return error")
+	}
+}
+
+func TestParseAccountID(t *testing.T) {
+	account, err := ParseAccountID("eip155:1:0xab16a96d359ec26a11e2c2b3d8f8b8942d5bfcdb")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &AccountID{ChainID{"eip155", "1"}, "0xab16a96d359ec26a11e2c2b3d8f8b8942d5bfcdb"}
+	if !reflect.DeepEqual(account, expected) {
+		t.Errorf("unexpected account id: %s", account)
+	}
+	invalid := []string{
+		"eip155:0xab16a96d359ec26a11e2c2b3d8f8b8942d5bfcdb",
+		"EIP155:1:0xab16a96d359ec26a11e2c2b3d8f8b8942d5bfcdb",
+		"eip155:1:",
+		"eip155:1:0x!",
+	}
+	for _, str := range invalid {
+		if _, err := ParseAccountID(str); err == nil {
+			t.Errorf("expected ParseAccountID to return error: %s", str)
+		}
+	}
+}
+
+// TestChecksumAddress checks against the examples in EIP-55.
+func TestChecksumAddress(t *testing.T) {
+	addresses := []string{
+		"0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+		"0xfB6916095ca1df60bB79Ce92cE3Ea74c37c5d359",
+		"0xdbF03B407c01E7cD3CBea99509d93f8DDDC8C6FB",
+		"0xD1220A0cf47c7B9Be7A2E6BA89F429762e7b9aDb",
+	}
+	for _, address := range addresses {
+		observed, err := ChecksumAddress(strings.ToLower(address))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if observed != address {
+			t.Errorf("expected %s, got: %s", address, observed)
+		}
+	}
+}
+
+func TestResolveEthereum(t *testing.T) {
+	id := "did:pkh:eip155:1:0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed"
+	observed, err := resolve(t, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ref := []resolver.VerificationMethod{resolver.Reference(id + "#blockchainAccountId")}
+	expected := &resolver.Document{
+		Context: []string{
+			"https://www.w3.org/ns/did/v1",
+			"https://w3id.org/security/suites/secp256k1recovery-2020/v2",
+		},
+		ID: id,
+		VerificationMethod: []resolver.VerificationMethod{
+			{
+				ID:                  id + "#blockchainAccountId",
+				Type:                "EcdsaSecp256k1RecoveryMethod2020",
+				Controller:          id,
+				BlockchainAccountID: "eip155:1:0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAed",
+			},
+		},
+		Authentication:  ref,
+		AssertionMethod: ref,
+	}
+	if !reflect.DeepEqual(observed, expected) {
+		t.Errorf("expected document to match: %s", expected.ID)
+	}
+	// Lowercase and uppercase addresses have no checksum
+	if _, err := resolve(t, strings.ToLower(id)); err != nil {
+		t.Error(err)
+	}
+	if _, err := resolve(t, "did:pkh:eip155:1:0x5AAEB6053F3E94C9B9A09F33669435E7EF1BEAED"); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInvalidEthereumChecksum(t *testing.T) {
+	_, err := resolve(t, "did:pkh:eip155:1:0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD")
+	if err == nil || err.Error() != "invalid ethereum address checksum: '0x5aAeb6053F3E94C9b9A09f33669435E7Ef1BeAeD'" {
+		t.Error("expected Resolve to return error")
+	}
+}
+
+func TestResolveSolana(t *testing.T) {
+	id := "did:pkh:solana:4sGjMW1sUnHzSxGspuhpqLDx6wiyjNtZ:CKg5d12Jhpej1JqtmxLJgaFqqeYjxgPqToJ4LBdvG9Ev"
+	observed, err := resolve(t, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm := observed.VerificationMethod[0]
+	if vm.Type != "Ed25519VerificationKey2018" || vm.PublicKeyBase58 != "CKg5d12Jhpej1JqtmxLJgaFqqeYjxgPqToJ4LBdvG9Ev" {
+		t.Errorf("unexpected verification method: %s", vm.Type)
+	}
+	_, err = resolve(t, "did:pkh:solana:4sGjMW1sUnHzSxGspuhpqLDx6wiyjNtZ:CKg5d12Jhpej1Jqt")
+	if err == nil || err.Error() != "invalid solana address: 'CKg5d12Jhpej1Jqt'" {
+		t.Error("expected Resolve to return error")
+	}
+}
+
+func TestResolveTezos(t *testing.T) {
+	id := "did:pkh:tezos:NetXdQprcVkpaWU:tz1TzrmTBSuiVHV2VfMnGRMYvTEPCP42oSM8"
+	observed, err := resolve(t, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vm := observed.VerificationMethod[0]
+	if vm.Type != "Ed25519PublicKeyBLAKE2BDigestSize20Base58CheckEncoded2021" {
+		t.Errorf("unexpected verification method: %s", vm.Type)
+	}
+	_, err = resolve(t, "did:pkh:tezos:NetXdQprcVkpaWU:tz1TzrmTBSuiVHV2VfMnGRMYvTEPCP42oSM9")
+	if err == nil || err.Error() != "invalid tezos address checksum: 'tz1TzrmTBSuiVHV2VfMnGRMYvTEPCP42oSM9'" {
+		t.Error("expected Resolve to return error")
+	}
+}
+
+func TestUnsupportedNamespace(t *testing.T) {
+	_, err := resolve(t, "did:pkh:bip122:000000000019d6689c085ae165831e93:128Lkh3S7CkDTBZ8W7BbpsN3YYizJMp8p6")
+	if err == nil || err.Error() != "unsupported namespace: 'bip122'" {
+		t.Error("expected Resolve to return error")
+	}
+}
@@ -0,0 +1,96 @@
+// Package plc provides tools for resolving the did:plc method format, by
+// fetching and verifying the operation log from a plc directory:
+// https://github.com/did-method-plc/did-method-plc
+// Copyright 2021 Textile
+package plc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+)
+
+// CBOR major types.
+const (
+	majorText  = 3
+	majorArray = 4
+	majorMap   = 5
+)
+
+// encodeCBOR returns the DAG-CBOR encoding of a json value, as decoded by
+// encoding/json. Operations only hold strings, arrays, maps, booleans and
+// null, so numbers are not supported.
+func encodeCBOR(value interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	if err := writeCBOR(&b, value); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func writeCBOR(b *bytes.Buffer, value interface{}) error {
+	switch value := value.(type) {
+	case nil:
+		b.WriteByte(0xf6)
+	case bool:
+		if value {
+			b.WriteByte(0xf5)
+		} else {
+			b.WriteByte(0xf4)
+		}
+	case string:
+		writeHeader(b, majorText, uint64(len(value)))
+		b.WriteString(value)
+	case []interface{}:
+		writeHeader(b, majorArray, uint64(len(value)))
+		for _, v := range value {
+			if err := writeCBOR(b, v); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		// Keys are sorted by length, then bytewise.
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if len(keys[i]) != len(keys[j]) {
+				return len(keys[i]) < len(keys[j])
+			}
+			return keys[i] < keys[j]
+		})
+		writeHeader(b, majorMap, uint64(len(value)))
+		for _, key := range keys {
+			writeHeader(b, majorText, uint64(len(key)))
+			b.WriteString(key)
+			if err := writeCBOR(b, value[key]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("unsupported operation value: %T", value)
+	}
+	return nil
+}
+
+// writeHeader writes the smallest encoding of a major type and argument.
+func writeHeader(b *bytes.Buffer, major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		b.WriteByte(major | byte(n))
+	case n <= 0xff:
+		b.Write([]byte{major | 24, byte(n)})
+	case n <= 0xffff:
+		b.WriteByte(major | 25)
+		binary.Write(b, binary.BigEndian, uint16(n))
+	case n <= 0xffffffff:
+		b.WriteByte(major | 26)
+		binary.Write(b, binary.BigEndian, uint32(n))
+	default:
+		b.WriteByte(major | 27)
+		binary.Write(b, binary.BigEndian, n)
+	}
+}
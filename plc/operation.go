@@ -0,0 +1,240 @@
+// Package plc provides tools for resolving the did:plc method format, by
+// fetching and verifying the operation log from a plc directory:
+// https://github.com/did-method-plc/did-method-plc
+// Copyright 2021 Textile
+package plc
+
+import (
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	cid "github.com/ipfs/go-cid"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/textileio/go-did-resolver/multikey"
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+// Operation types.
+const (
+	TypeOperation = "plc_operation"
+	TypeTombstone = "plc_tombstone"
+	// TypeCreate is the legacy genesis operation type.
+	TypeCreate = "create"
+)
+
+// maxRotationKeys is the maximum number of rotation keys of an operation.
+const maxRotationKeys = 5
+
+var didEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// Operation is a signed did:plc operation.
+type Operation struct {
+	Type                string             `json:"type"`
+	RotationKeys        []string           `json:"rotationKeys,omitempty"`
+	VerificationMethods map[string]string  `json:"verificationMethods,omitempty"`
+	AlsoKnownAs         []string           `json:"alsoKnownAs,omitempty"`
+	Services            map[string]Service `json:"services,omitempty"`
+	Prev                *string            `json:"prev"`
+	Sig                 string             `json:"sig"`
+
+	// Legacy create operation fields.
+	SigningKey  string `json:"signingKey,omitempty"`
+	RecoveryKey string `json:"recoveryKey,omitempty"`
+	Handle      string `json:"handle,omitempty"`
+	Service     string `json:"service,omitempty"`
+}
+
+// Service is a service of a did:plc operation.
+type Service struct {
+	Type     string `json:"type"`
+	Endpoint string `json:"endpoint"`
+}
+
+// LogEntry is an entry of a plc directory audit log.
+type LogEntry struct {
+	DID       string          `json:"did"`
+	Operation json.RawMessage `json:"operation"`
+	CID       string          `json:"cid"`
+	Nullified bool            `json:"nullified"`
+	CreatedAt string          `json:"createdAt"`
+}
+
+// normalize converts a legacy create operation to a plc_operation.
+func (op *Operation) normalize() {
+	if op.Type != TypeCreate {
+		return
+	}
+	*op = Operation{
+		Type:                TypeOperation,
+		RotationKeys:        []string{op.RecoveryKey, op.SigningKey},
+		VerificationMethods: map[string]string{"atproto": op.SigningKey},
+		AlsoKnownAs:         []string{"at://" + op.Handle},
+		Services: map[string]Service{
+			"atproto_pds": {Type: "AtprotoPersonalDataServer", Endpoint: op.Service},
+		},
+		Prev: op.Prev,
+		Sig:  op.Sig,
+	}
+}
+
+// decodeDidKey decodes a did:key into its multikey.
+func decodeDidKey(did string) (*multikey.Key, error) {
+	if !strings.HasPrefix(did, "did:key:") {
+		return nil, fmt.Errorf("invalid did:key: '%s'", did)
+	}
+	return multikey.Decode(strings.TrimPrefix(did, "did:key:"))
+}
+
+// signedOperation is a decoded operation, along with its encodings.
+type signedOperation struct {
+	*Operation
+	// unsigned is the DAG-CBOR encoding of the operation without its sig.
+	unsigned []byte
+	// signed is the DAG-CBOR encoding of the whole operation.
+	signed []byte
+}
+
+// decodeOperation decodes an operation, and encodes it for hashing and
+// signature verification.
+func decodeOperation(data []byte) (*signedOperation, error) {
+	var op Operation
+	if err := json.Unmarshal(data, &op); err != nil {
+		return nil, fmt.Errorf("invalid operation: %v", err)
+	}
+	var members map[string]interface{}
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, fmt.Errorf("invalid operation: %v", err)
+	}
+	signed, err := encodeCBOR(members)
+	if err != nil {
+		return nil, err
+	}
+	delete(members, "sig")
+	unsigned, err := encodeCBOR(members)
+	if err != nil {
+		return nil, err
+	}
+	return &signedOperation{&op, unsigned, signed}, nil
+}
+
+// cid returns the dag-cbor CIDv1 of the signed operation.
+func (op *signedOperation) cid() (string, error) {
+	hash, err := mh.Sum(op.signed, mh.SHA2_256, -1)
+	if err != nil {
+		return "", err
+	}
+	return cid.NewCidV1(cid.DagCBOR, hash).String(), nil
+}
+
+// did returns the did derived from a signed genesis operation.
+func (op *signedOperation) did() string {
+	hash := sha256.Sum256(op.signed)
+	return "did:plc:" + strings.ToLower(didEncoding.EncodeToString(hash[:]))[:24]
+}
+
+// verify checks that the operation is signed by one of the rotation keys.
+func (op *signedOperation) verify(rotationKeys []string) error {
+	sig, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(op.Sig, "="))
+	if err != nil {
+		return fmt.Errorf("invalid operation signature: %v", err)
+	}
+	for _, rotationKey := range rotationKeys {
+		key, err := decodeDidKey(rotationKey)
+		if err != nil {
+			return err
+		}
+		if key.Verify(op.unsigned, sig) == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("operation is not signed by a rotation key")
+}
+
+// validate checks the keys of a normalized operation.
+func (op *Operation) validate() error {
+	switch op.Type {
+	case TypeTombstone:
+		return nil
+	case TypeOperation:
+	default:
+		return fmt.Errorf("unsupported operation type: '%s'", op.Type)
+	}
+	if len(op.RotationKeys) == 0 || len(op.RotationKeys) > maxRotationKeys {
+		return fmt.Errorf("invalid number of rotation keys: %d", len(op.RotationKeys))
+	}
+	for _, rotationKey := range op.RotationKeys {
+		if _, err := decodeDidKey(rotationKey); err != nil {
+			return err
+		}
+	}
+	for _, vm := range op.VerificationMethods {
+		if _, err := decodeDidKey(vm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// verifyLog checks the chain of operations of an audit log, and returns the
+// current operation. Nullified operations have been superseded by a recovery,
+// and are skipped.
+func verifyLog(did string, entries []LogEntry) (*Operation, error) {
+	var current *signedOperation
+	var prev string
+	for _, entry := range entries {
+		if entry.Nullified {
+			continue
+		}
+		op, err := decodeOperation(entry.Operation)
+		if err != nil {
+			return nil, err
+		}
+		id, err := op.cid()
+		if err != nil {
+			return nil, err
+		}
+		if entry.CID != "" && entry.CID != id {
+			return nil, fmt.Errorf("operation cid mismatch: '%s'", entry.CID)
+		}
+		var rotationKeys []string
+		if current == nil {
+			if op.Prev != nil {
+				return nil, fmt.Errorf("genesis operation must not have a prev")
+			}
+			if op.Type == TypeTombstone {
+				return nil, fmt.Errorf("genesis operation must not be a tombstone")
+			}
+			if op.did() != did {
+				return nil, fmt.Errorf("genesis operation does not match did")
+			}
+			op.normalize()
+			rotationKeys = op.RotationKeys
+		} else {
+			if current.Type == TypeTombstone {
+				return nil, fmt.Errorf("operation after tombstone: '%s'", id)
+			}
+			if op.Type == TypeCreate {
+				return nil, fmt.Errorf("create operation must be the genesis operation")
+			}
+			if op.Prev == nil || *op.Prev != prev {
+				return nil, fmt.Errorf("operation prev does not match previous operation: '%s'", id)
+			}
+			rotationKeys = current.RotationKeys
+		}
+		if err := op.validate(); err != nil {
+			return nil, err
+		}
+		if err := op.verify(rotationKeys); err != nil {
+			return nil, err
+		}
+		current, prev = op, id
+	}
+	if current == nil {
+		return nil, resolver.NewError(resolver.NotFound, "no operations for did: '%s'", did)
+	}
+	return current.Operation, nil
+}
@@ -0,0 +1,170 @@
+// Package plc provides tools for resolving the did:plc method format, by
+// fetching and verifying the operation log from a plc directory:
+// https://github.com/did-method-plc/did-method-plc
+// Copyright 2021 Textile
+package plc
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ockam-network/did"
+	"github.com/textileio/go-did-resolver/internal/httpclient"
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+// DefaultDirectory is the url of the public plc directory.
+const DefaultDirectory = "https://plc.directory"
+
+// idPattern matches the method-specific id of a did:plc.
+var idPattern = regexp.MustCompile(`^[a-z2-7]{24}$`)
+
+// DefaultTimeout is the time limit of requests to the directory, unless an
+// http client is given with WithHTTPClient.
+const DefaultTimeout = httpclient.DefaultTimeout
+
+// Option configures a Resolver.
+type Option func(*Resolver)
+
+// WithHTTPClient sets the client used for requests to the directory.
+func WithHTTPClient(client *http.Client) Option {
+	return func(r *Resolver) {
+		r.client = httpclient.Or(client)
+	}
+}
+
+// Resolver resolves did:plc identifiers from a plc directory. The directory
+// is not trusted: the operation log is verified locally.
+type Resolver struct {
+	url    string
+	client *http.Client
+}
+
+// New creates and returns a new plc Resolver for the directory at url, or
+// DefaultDirectory if url is empty, configured by opts.
+func New(url string, opts ...Option) *Resolver {
+	if url == "" {
+		url = DefaultDirectory
+	}
+	r := &Resolver{url: strings.TrimSuffix(url, "/"), client: httpclient.Default}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Method returns the method that this resolver is capable of resolving.
+func (r *Resolver) Method() string {
+	return "plc"
+}
+
+// Resolve is the primary resolution method for this resolver.
+func (r *Resolver) Resolve(did string, parsed *did.DID, res resolver.Resolver) (*resolver.Document, error) {
+	doc, _, err := r.ResolveWithMetadata(did, parsed, res, nil)
+	return doc, err
+}
+
+// ResolveWithMetadata resolves the did from its verified operation log, and
+// returns the creation and update times and deactivation status as metadata.
+func (r *Resolver) ResolveWithMetadata(did string, parsed *did.DID, res resolver.Resolver, options *resolver.ResolutionOptions) (*resolver.Document, resolver.DocumentMetadata, error) {
+	if parsed.Method != r.Method() {
+		return nil, resolver.DocumentMetadata{}, fmt.Errorf("unknown did method: '%s'", parsed.Method)
+	}
+	if !idPattern.MatchString(parsed.ID) {
+		return nil, resolver.DocumentMetadata{}, fmt.Errorf("invalid did:plc identifier: '%s'", parsed.ID)
+	}
+	id := fmt.Sprintf("did:plc:%s", parsed.ID)
+	entries, err := r.fetchLog(id)
+	if err != nil {
+		return nil, resolver.DocumentMetadata{}, err
+	}
+	op, err := verifyLog(id, entries)
+	if err != nil {
+		return nil, resolver.DocumentMetadata{}, err
+	}
+	var active []LogEntry
+	for _, entry := range entries {
+		if !entry.Nullified {
+			active = append(active, entry)
+		}
+	}
+	metadata := resolver.DocumentMetadata{
+		Created:   active[0].CreatedAt,
+		Updated:   active[len(active)-1].CreatedAt,
+		VersionID: active[len(active)-1].CID,
+	}
+	doc := &resolver.Document{
+		Context: []string{
+			"https://www.w3.org/ns/did/v1",
+			"https://w3id.org/security/multikey/v1",
+		},
+		ID: id,
+	}
+	if op.Type == TypeTombstone {
+		metadata.Deactivated = true
+		return doc, metadata, nil
+	}
+	doc.AlsoKnownAs = op.AlsoKnownAs
+	for _, name := range sortedKeys(op.VerificationMethods) {
+		doc.VerificationMethod = append(doc.VerificationMethod, resolver.VerificationMethod{
+			ID:                 id + "#" + name,
+			Type:               "Multikey",
+			Controller:         id,
+			PublicKeyMultibase: strings.TrimPrefix(op.VerificationMethods[name], "did:key:"),
+		})
+	}
+	var names []string
+	for name := range op.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		doc.Service = append(doc.Service, resolver.ServiceEndpoint{
+			ID:              id + "#" + name,
+			Type:            op.Services[name].Type,
+			ServiceEndpoint: op.Services[name].Endpoint,
+		})
+	}
+	return doc, metadata, nil
+}
+
+// fetchLog fetches the audit log of a did from the directory.
+func (r *Resolver) fetchLog(did string) ([]LogEntry, error) {
+	resp, err := r.client.Get(r.url + "/" + did + "/log/audit")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, resolver.NewError(resolver.NotFound, "did not found: '%s'", did)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch log for '%s': '%s'", did, resp.Status)
+	}
+	var entries []LogEntry
+	if err := json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, err
+	}
+	for _, entry := range entries {
+		if entry.DID != did {
+			return nil, fmt.Errorf("log entry for wrong did: '%s'", entry.DID)
+		}
+	}
+	return entries, nil
+}
+
+// sortedKeys returns the keys of m in order, so that documents are stable.
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+var _ resolver.MetadataResolver = (*Resolver)(nil)
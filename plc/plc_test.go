@@ -0,0 +1,336 @@
+// Package plc provides tools for resolving the did:plc method format, by
+// fetching and verifying the operation log from a plc directory:
+// https://github.com/did-method-plc/did-method-plc
+// Copyright 2021 Textile
+package plc
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	codec "github.com/multiformats/go-multicodec"
+	"github.com/ockam-network/did"
+	"github.com/textileio/go-did-resolver/internal/httpclient"
+	"github.com/textileio/go-did-resolver/multikey"
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+// signer is a P-256 rotation or signing key.
+type signer struct {
+	private *ecdsa.PrivateKey
+	did     string
+}
+
+func newSigner(t *testing.T) *signer {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := &multikey.Key{Code: codec.P256Pub, Bytes: elliptic.MarshalCompressed(elliptic.P256(), private.X, private.Y)}
+	encoded, err := key.Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &signer{private, "did:key:" + encoded}
+}
+
+// sign adds a low-S signature to the operation.
+func (s *signer) sign(t *testing.T, op map[string]interface{}) {
+	delete(op, "sig")
+	data, err := encodeCBOR(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := sha256.Sum256(data)
+	r, sv, err := ecdsa.Sign(rand.Reader, s.private, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := elliptic.P256().Params().N
+	if sv.Cmp(new(big.Int).Rsh(n, 1)) > 0 {
+		sv.Sub(n, sv)
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	sv.FillBytes(sig[32:])
+	op["sig"] = base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// operation creates an unsigned plc_operation.
+func operation(prev interface{}, handle string, rotationKeys ...*signer) map[string]interface{} {
+	var keys []interface{}
+	for _, key := range rotationKeys {
+		keys = append(keys, key.did)
+	}
+	return map[string]interface{}{
+		"type":                TypeOperation,
+		"rotationKeys":        keys,
+		"verificationMethods": map[string]interface{}{"atproto": rotationKeys[0].did},
+		"alsoKnownAs":         []interface{}{"at://" + handle},
+		"services": map[string]interface{}{
+			"atproto_pds": map[string]interface{}{
+				"type":     "AtprotoPersonalDataServer",
+				"endpoint": "https://pds.example.com",
+			},
+		},
+		"prev": prev,
+	}
+}
+
+// directory is a stand-in for a plc directory.
+type directory struct {
+	did     string
+	entries []LogEntry
+}
+
+// add appends an operation to the log, and returns its cid.
+func (d *directory) add(t *testing.T, op map[string]interface{}) string {
+	data, err := json.Marshal(op)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := decodeOperation(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.did == "" {
+		d.did = signed.did()
+	}
+	id, err := signed.cid()
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.entries = append(d.entries, LogEntry{
+		DID:       d.did,
+		Operation: data,
+		CID:       id,
+		CreatedAt: fmt.Sprintf("2021-06-%02dT00:00:00.000Z", len(d.entries)+1),
+	})
+	return id
+}
+
+func (d *directory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/"+d.did+"/log/audit" {
+		http.NotFound(w, r)
+		return
+	}
+	json.NewEncoder(w).Encode(d.entries)
+}
+
+func resolve(t *testing.T, d *directory, id string) (*resolver.Document, resolver.DocumentMetadata, error) {
+	server := httptest.NewServer(d)
+	defer server.Close()
+	parsed, err := did.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New(server.URL)
+	return r.ResolveWithMetadata(id, parsed, r, nil)
+}
+
+func TestEncodeCBOR(t *testing.T) {
+	data, err := encodeCBOR(map[string]interface{}{"b": "x", "aa": nil, "c": []interface{}{true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected, _ := hex.DecodeString("a3616261786163" + "81f5" + "626161f6")
+	if !bytes.Equal(data, expected) {
+		t.Errorf("unexpected encoding: %x", data)
+	}
+}
+
+func TestUnknownMethod(t *testing.T) {
+	_, _, err := resolve(t, &directory{}, "did:borg:ewvi7nxzyoun6zhxrhs64oiz")
+	if err == nil || err.Error() != "unknown did method: 'borg'" {
+		t.Error("expected Resolve to return error")
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	_, _, err := resolve(t, &directory{}, "did:plc:ewvi7nxzyoun6zhxrhs64oiz")
+	var rerr *resolver.Error
+	if !errors.As(err, &rerr) || rerr.Code != resolver.NotFound {
+		t.Errorf("expected notFound error: %v", err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	rotation, signing := newSigner(t), newSigner(t)
+	d := &directory{}
+	genesis := operation(nil, "alice.test", rotation)
+	rotation.sign(t, genesis)
+	prev := d.add(t, genesis)
+
+	// Rotate to a new key and handle, signed by the genesis rotation key.
+	update := operation(prev, "alice.example.com", signing)
+	rotation.sign(t, update)
+	prev = d.add(t, update)
+
+	doc, metadata, err := resolve(t, d, d.did)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &resolver.Document{
+		Context: []string{
+			"https://www.w3.org/ns/did/v1",
+			"https://w3id.org/security/multikey/v1",
+		},
+		ID:          d.did,
+		AlsoKnownAs: []string{"at://alice.example.com"},
+		VerificationMethod: []resolver.VerificationMethod{
+			{
+				ID:                 d.did + "#atproto",
+				Type:               "Multikey",
+				Controller:         d.did,
+				PublicKeyMultibase: signing.did[len("did:key:"):],
+			},
+		},
+		Service: []resolver.ServiceEndpoint{
+			{
+				ID:              d.did + "#atproto_pds",
+				Type:            "AtprotoPersonalDataServer",
+				ServiceEndpoint: "https://pds.example.com",
+			},
+		},
+	}
+	if !reflect.DeepEqual(doc, expected) {
+		t.Errorf("unexpected document: %+v", doc)
+	}
+	if metadata.Created != "2021-06-01T00:00:00.000Z" || metadata.Updated != "2021-06-02T00:00:00.000Z" || metadata.VersionID != prev {
+		t.Errorf("unexpected metadata: %+v", metadata)
+	}
+
+	// The genesis rotation key has been rotated out.
+	next := operation(prev, "mallory.test", rotation)
+	rotation.sign(t, next)
+	d.add(t, next)
+	_, _, err = resolve(t, d, d.did)
+	if err == nil || err.Error() != "operation is not signed by a rotation key" {
+		t.Error("expected Resolve to return error")
+	}
+}
+
+func TestTamperedLog(t *testing.T) {
+	rotation := newSigner(t)
+	d := &directory{}
+	genesis := operation(nil, "alice.test", rotation)
+	rotation.sign(t, genesis)
+	prev := d.add(t, genesis)
+	update := operation(prev, "alice.example.com", rotation)
+	rotation.sign(t, update)
+	d.add(t, update)
+
+	tests := []struct {
+		name   string
+		tamper func(op map[string]interface{})
+		index  int
+		err    string
+	}{
+		{"genesis", func(op map[string]interface{}) {
+			op["alsoKnownAs"] = []interface{}{"at://mallory.test"}
+		}, 0, "genesis operation does not match did"},
+		{"signature", func(op map[string]interface{}) {
+			op["alsoKnownAs"] = []interface{}{"at://mallory.test"}
+		}, 1, "operation is not signed by a rotation key"},
+		{"prev", func(op map[string]interface{}) {
+			op["prev"] = nil
+			rotation.sign(t, op)
+		}, 1, "operation prev does not match previous operation"},
+	}
+	for _, tt := range tests {
+		tampered := &directory{did: d.did, entries: append([]LogEntry{}, d.entries...)}
+		var op map[string]interface{}
+		json.Unmarshal(tampered.entries[tt.index].Operation, &op)
+		tt.tamper(op)
+		tampered.entries[tt.index].Operation, _ = json.Marshal(op)
+		tampered.entries[tt.index].CID = ""
+		_, _, err := resolve(t, tampered, d.did)
+		if err == nil || !strings.HasPrefix(err.Error(), tt.err) {
+			t.Errorf("%s: expected Resolve to return error, got: %v", tt.name, err)
+		}
+	}
+
+	// The directory cid must match the operation.
+	tampered := &directory{did: d.did, entries: append([]LogEntry{}, d.entries...)}
+	tampered.entries[1].CID = tampered.entries[0].CID
+	if _, _, err := resolve(t, tampered, d.did); err == nil {
+		t.Error("expected Resolve to return error")
+	}
+}
+
+func TestNullified(t *testing.T) {
+	rotation, recovery := newSigner(t), newSigner(t)
+	d := &directory{}
+	genesis := operation(nil, "alice.test", recovery, rotation)
+	recovery.sign(t, genesis)
+	prev := d.add(t, genesis)
+	// An operation that was later overridden by the recovery key.
+	nullified := operation(prev, "mallory.test", rotation)
+	rotation.sign(t, nullified)
+	d.add(t, nullified)
+	d.entries[1].Nullified = true
+	recovered := operation(prev, "alice.example.com", recovery, rotation)
+	recovery.sign(t, recovered)
+	d.add(t, recovered)
+
+	doc, _, err := resolve(t, d, d.did)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(doc.AlsoKnownAs, []string{"at://alice.example.com"}) {
+		t.Errorf("unexpected alsoKnownAs: %v", doc.AlsoKnownAs)
+	}
+}
+
+func TestTombstone(t *testing.T) {
+	rotation := newSigner(t)
+	d := &directory{}
+	genesis := operation(nil, "alice.test", rotation)
+	rotation.sign(t, genesis)
+	prev := d.add(t, genesis)
+	tombstone := map[string]interface{}{"type": TypeTombstone, "prev": prev}
+	rotation.sign(t, tombstone)
+	d.add(t, tombstone)
+
+	doc, metadata, err := resolve(t, d, d.did)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !metadata.Deactivated || len(doc.VerificationMethod) != 0 {
+		t.Errorf("expected deactivated document: %+v", doc)
+	}
+}
+
+func TestWithHTTPClient(t *testing.T) {
+	server := httptest.NewServer(&directory{})
+	defer server.Close()
+	id := "did:plc:ewvi7nxzyoun6zhxrhs64oiz"
+	parsed, err := did.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := &httpclient.CountingTransport{}
+	r := New(server.URL, WithHTTPClient(&http.Client{Transport: transport}))
+	_, _, err = r.ResolveWithMetadata(id, parsed, r, nil)
+	var rerr *resolver.Error
+	if !errors.As(err, &rerr) || rerr.Code != resolver.NotFound {
+		t.Errorf("expected notFound error: %v", err)
+	}
+	if transport.Requests() != 1 {
+		t.Errorf("expected the transport to be used, got %d requests", transport.Requests())
+	}
+}
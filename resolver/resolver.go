@@ -6,7 +6,12 @@
 package resolver
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"reflect"
+	"strings"
+	"sync"
 
 	parse "github.com/ockam-network/did"
 )
@@ -16,32 +21,98 @@ import (
 // TODO: Should we include the non-standard publicKey field for compatability reasons?
 // See https://w3c.github.io/did-spec-registries/#publickey
 type Document struct {
-	Context            []string             `json:"@context"` // https://w3id.org/did/v1
-	ID                 string               `json:"id"`
-	Controller         []string             `json:"controller,omitempty"`
-	VerificationMethod []VerificationMethod `json:"verificationMethod,omitempty"`
-	Authentication     []VerificationMethod `json:"authentication,omitempty"`
-	KeyAgreement       []VerificationMethod `json:"keyAgreement,omitempty"`
-	Service            []ServiceEndpoint    `json:"service,omitempty"`
+	Context              []string             `json:"@context"` // https://w3id.org/did/v1
+	ID                   string               `json:"id"`
+	AlsoKnownAs          []string             `json:"alsoKnownAs,omitempty"`
+	Controller           []string             `json:"controller,omitempty"`
+	VerificationMethod   []VerificationMethod `json:"verificationMethod,omitempty"`
+	Authentication       []VerificationMethod `json:"authentication,omitempty"`
+	AssertionMethod      []VerificationMethod `json:"assertionMethod,omitempty"`
+	CapabilityInvocation []VerificationMethod `json:"capabilityInvocation,omitempty"`
+	CapabilityDelegation []VerificationMethod `json:"capabilityDelegation,omitempty"`
+	KeyAgreement         []VerificationMethod `json:"keyAgreement,omitempty"`
+	Service              []ServiceEndpoint    `json:"service,omitempty"`
 }
 
 // ServiceEndpoint descrives a network address, such as an http url, at which services operate on behalf of a did subject.
 // See https://www.w3.org/TR/did-core/#dfn-service-endpoints
 type ServiceEndpoint struct {
-	ID              string `json:"id"`
-	Type            string `json:"type,omitempty"`
-	ServiceEndpoint string `json:"serviceEndpoint,omitempty"`
-	Description     string `json:"description,omitempty"`
+	ID              string   `json:"id"`
+	Type            string   `json:"type,omitempty"`
+	ServiceEndpoint string   `json:"serviceEndpoint,omitempty"`
+	Description     string   `json:"description,omitempty"`
+	RoutingKeys     []string `json:"routingKeys,omitempty"`
+	Accept          []string `json:"accept,omitempty"`
 }
 
 // VerificationMethod describes how to authenticate or authorize interactions with a did subject.
 // See https://www.w3.org/TR/did-core/#dfn-verification-method.
 type VerificationMethod struct {
-	ID                 string `json:"id,omitempty"`
-	Type               string `json:"type,omitempty"`
-	Controller         string `json:"controller,omitempty"`
-	PublicKeyMultibase string `json:"publicKeyMultibase,omitempty"`
-	PublicKey          string `json:"publicKey,omitempty"`
+	ID                  string `json:"id,omitempty"`
+	Type                string `json:"type,omitempty"`
+	Controller          string `json:"controller,omitempty"`
+	PublicKeyMultibase  string `json:"publicKeyMultibase,omitempty"`
+	PublicKeyBase58     string `json:"publicKeyBase58,omitempty"`
+	PublicKey           string `json:"publicKey,omitempty"`
+	PublicKeyJwk        *JWK   `json:"publicKeyJwk,omitempty"`
+	BlockchainAccountID string `json:"blockchainAccountId,omitempty"`
+}
+
+// Reference returns a VerificationMethod that refers to a verification method
+// by id, for use in verification relationships such as authentication.
+// See https://www.w3.org/TR/did-core/#referring-to-verification-methods
+func Reference(id string) VerificationMethod {
+	return VerificationMethod{ID: id}
+}
+
+// AbsoluteID resolves a relative DID URL such as "#key-1" against did, and
+// returns other ids unchanged.
+// See https://www.w3.org/TR/did-core/#relative-did-urls
+func AbsoluteID(did, id string) string {
+	if strings.HasPrefix(id, "#") {
+		return did + id
+	}
+	return id
+}
+
+// IsReference reports whether the VerificationMethod only refers to another
+// verification method by id.
+func (vm VerificationMethod) IsReference() bool {
+	return vm == VerificationMethod{ID: vm.ID}
+}
+
+// MarshalJSON encodes references as plain id strings, and embedded
+// verification methods as objects.
+func (vm VerificationMethod) MarshalJSON() ([]byte, error) {
+	if vm.ID != "" && vm.IsReference() {
+		return json.Marshal(vm.ID)
+	}
+	type embedded VerificationMethod
+	return json.Marshal(embedded(vm))
+}
+
+// UnmarshalJSON decodes either a plain id string reference or an embedded
+// verification method object.
+func (vm *VerificationMethod) UnmarshalJSON(data []byte) error {
+	var id string
+	if err := json.Unmarshal(data, &id); err == nil {
+		*vm = Reference(id)
+		return nil
+	}
+	type embedded VerificationMethod
+	return json.Unmarshal(data, (*embedded)(vm))
+}
+
+// JWK is a JSON Web Key representation of a public key.
+// See https://tools.ietf.org/html/rfc7517 and https://w3c-ccg.github.io/lds-jws2020/.
+type JWK struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Use string `json:"use,omitempty"`
 }
 
 // VerificationString describes how to authenticate or authorize interactions with a did subject.
@@ -56,6 +127,10 @@ type ResolutionOptions struct {
 	// Accept indicates the media type of the caller's preferred representation of the did document.
 	// This property must not be used with the resolve function.
 	Accept string `json:"accept,omitempty"`
+	// PublicKeyFormat indicates the verification method type that should be used to express public keys, for
+	// resolvers that support more than one, such as did:key.
+	// See https://w3c-ccg.github.io/did-method-key/#resolve-options
+	PublicKeyFormat string `json:"publicKeyFormat,omitempty"`
 }
 
 // ResolutionMetadata defined a metadata structure consisting of values relating to the results of the did resolution process.
@@ -70,6 +145,51 @@ type ResolutionMetadata struct {
 	Error       string `json:"error,omitempty"`
 }
 
+// Error codes for ResolutionMetadata.Error.
+// See https://w3c.github.io/did-spec-registries/#error
+const (
+	// InvalidDid indicates that the did supplied to the resolution function does not conform to valid syntax.
+	InvalidDid = "invalidDid"
+	// NotFound indicates that the resolver was unable to find the did document resulting from the resolution request.
+	NotFound = "notFound"
+	// InvalidPublicKey indicates that the public key material encoded in or referenced by the did is invalid.
+	InvalidPublicKey = "invalidPublicKey"
+	// InternalError indicates an unexpected error during resolution, such as an unavailable or misbehaving service
+	// that the did method depends on.
+	InternalError = "internalError"
+)
+
+// Error is a did resolution error that carries a ResolutionMetadata error code.
+type Error struct {
+	// Code is the error code reported in ResolutionMetadata.Error.
+	Code string
+	// Err is the underlying error.
+	Err error
+}
+
+// NewError creates and returns a new resolution Error with the given code.
+func NewError(code string, format string, args ...interface{}) *Error {
+	return &Error{Code: code, Err: fmt.Errorf(format, args...)}
+}
+
+func (e *Error) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// errorCode returns the resolution error code for err, defaulting to InvalidDid.
+func errorCode(err error) string {
+	var e *Error
+	if errors.As(err, &e) {
+		return e.Code
+	}
+	return InvalidDid
+}
+
 // DocumentMetadata defines a metadata structure consisting of values relating the resolved did document.
 // See https://w3c.github.io/did-core/#did-document-metadata
 // See https://w3c.github.io/did-spec-registries/#did-document-metadata
@@ -77,11 +197,15 @@ type ResolutionMetadata struct {
 // Updated is a string formatted as an XML Datetime normalized to UTC 00:00:00 and without sub-second decimal precision.
 // Deactivated is a boolean value indicating if the associated did document has been deactivated.
 // VersionID indicates the version of the last update operation for the document version which was resolved.
+// CanonicalID is the canonical did of the did subject, if it differs from the resolved did.
+// EquivalentID lists other dids that are logically equivalent to the resolved did.
 type DocumentMetadata struct {
-	Created     string `json:"created,omitempty"`
-	Updated     string `json:"updated,omitempty"`
-	Deactivated bool   `json:"deactivated,omitempty"`
-	VersionID   string `json:"versionId,omitempty"`
+	Created      string   `json:"created,omitempty"`
+	Updated      string   `json:"updated,omitempty"`
+	Deactivated  bool     `json:"deactivated,omitempty"`
+	VersionID    string   `json:"versionId,omitempty"`
+	CanonicalID  string   `json:"canonicalId,omitempty"`
+	EquivalentID []string `json:"equivalentId,omitempty"`
 }
 
 // Resolver defines a basic did resolver implementation.
@@ -92,6 +216,20 @@ type Resolver interface {
 	Resolve(did string, parsed *parse.DID, resolver Resolver) (*Document, error)
 }
 
+// OptionsResolver is a Resolver that is also able to take ResolutionOptions into account.
+type OptionsResolver interface {
+	Resolver
+	// ResolveWithOptions is the same as Resolve, but the result may be controlled by options, which may be nil.
+	ResolveWithOptions(did string, parsed *parse.DID, resolver Resolver, options *ResolutionOptions) (*Document, error)
+}
+
+// MetadataResolver is a Resolver that is also able to return DocumentMetadata.
+type MetadataResolver interface {
+	Resolver
+	// ResolveWithMetadata is the same as ResolveWithOptions, but also returns metadata about the resolved document.
+	ResolveWithMetadata(did string, parsed *parse.DID, resolver Resolver, options *ResolutionOptions) (*Document, DocumentMetadata, error)
+}
+
 // wrappedResolve is a simple function type for wrapping a did Resolver.
 type wrappedResolve func() (*Document, error)
 
@@ -101,10 +239,14 @@ type Cache interface {
 	Get(parsed *parse.DID, resolve wrappedResolve) (*Document, error)
 }
 
-// memoryCache is a simple in-memory did document cache.
-type memoryCache map[string]*Document
+// memoryCache is a simple in-memory did document cache, which is safe for
+// concurrent use.
+type memoryCache struct {
+	mu   sync.RWMutex
+	docs map[string]*Document
+}
 
-func (c memoryCache) Get(parsed *parse.DID, resolve wrappedResolve) (*Document, error) {
+func (c *memoryCache) Get(parsed *parse.DID, resolve wrappedResolve) (*Document, error) {
 	if len(parsed.Params) > 0 {
 		for _, p := range parsed.Params {
 			if p.Name == "no-cache" && p.Value == "true" {
@@ -112,7 +254,9 @@ func (c memoryCache) Get(parsed *parse.DID, resolve wrappedResolve) (*Document,
 			}
 		}
 	}
-	cached, ok := c[parsed.String()]
+	c.mu.RLock()
+	cached, ok := c.docs[parsed.String()]
+	c.mu.RUnlock()
 	if ok {
 		return cached, nil
 	}
@@ -120,62 +264,232 @@ func (c memoryCache) Get(parsed *parse.DID, resolve wrappedResolve) (*Document,
 	if err != nil {
 		return nil, err
 	}
-	c[parsed.String()] = doc
+	c.mu.Lock()
+	c.docs[parsed.String()] = doc
+	c.mu.Unlock()
 	return doc, nil
 }
 
-// Registry is the singleton Resolver registery for resolving dids.
+// Fallback controls when a Registry tries the next Resolver registered for a
+// method, after one fails.
+type Fallback int
+
+const (
+	// FallbackOnError tries the next Resolver after any error.
+	FallbackOnError Fallback = iota
+	// FallbackOnNotFound only tries the next Resolver after a NotFound error,
+	// such as a local mirror that does not have the did.
+	FallbackOnNotFound
+	// FallbackNever only uses the first Resolver registered for a method.
+	FallbackNever
+)
+
+// methods holds the resolvers and aliases of a Registry.
+type methods struct {
+	mu        sync.RWMutex
+	resolvers map[string][]Resolver
+	aliases   map[string]string
+	fallback  Fallback
+}
+
+// Registry is the singleton Resolver registery for resolving dids. Each method
+// has an ordered chain of resolvers, which are tried in turn. Resolvers and
+// aliases may be registered and unregistered while resolving.
 type Registry struct {
-	registry map[string]Resolver
-	cache    Cache
+	methods *methods
+	cache   Cache
 }
 
-// New creates and returns a new resolver Registry.
+// New creates and returns a new resolver Registry. Resolvers for the same
+// method are tried in the given order, falling back on any error.
 func New(resolvers []Resolver, useCache bool) Registry {
 	var cache Cache
 	if useCache {
-		cache = memoryCache{}
+		cache = &memoryCache{docs: make(map[string]*Document)}
 	}
-	registry := make(map[string]Resolver)
+	r := Registry{
+		&methods{
+			resolvers: make(map[string][]Resolver),
+			aliases:   make(map[string]string),
+		},
+		cache,
+	}
+	r.Register(resolvers...)
+	return r
+}
+
+// Register adds resolvers to the end of the chains of their methods.
+func (r Registry) Register(resolvers ...Resolver) {
+	r.methods.mu.Lock()
+	defer r.methods.mu.Unlock()
 	for _, resolver := range resolvers {
-		registry[resolver.Method()] = resolver
+		if resolver == nil {
+			continue
+		}
+		method := resolver.Method()
+		r.methods.resolvers[method] = append(r.methods.resolvers[method], resolver)
 	}
-	return Registry{
-		registry,
-		cache,
+}
+
+// Unregister removes a resolver from the chain of its method. Resolutions that
+// are already running may still use it.
+func (r Registry) Unregister(resolver Resolver) {
+	if resolver == nil || !reflect.TypeOf(resolver).Comparable() {
+		return
+	}
+	r.methods.mu.Lock()
+	defer r.methods.mu.Unlock()
+	method := resolver.Method()
+	var chain []Resolver
+	for _, res := range r.methods.resolvers[method] {
+		if res != resolver {
+			chain = append(chain, res)
+		}
+	}
+	if len(chain) == 0 {
+		delete(r.methods.resolvers, method)
+	} else {
+		r.methods.resolvers[method] = chain
+	}
+}
+
+// Alias makes dids of the alias method resolve with the resolvers of method,
+// e.g. Alias("threeid", "3"). The did is rewritten to use method before it is
+// resolved. An empty method removes the alias.
+func (r Registry) Alias(alias, method string) {
+	r.methods.mu.Lock()
+	defer r.methods.mu.Unlock()
+	if method == "" {
+		delete(r.methods.aliases, alias)
+		return
+	}
+	r.methods.aliases[alias] = method
+}
+
+// SetFallback sets when the next resolver of a method is tried.
+func (r Registry) SetFallback(fallback Fallback) {
+	r.methods.mu.Lock()
+	defer r.methods.mu.Unlock()
+	r.methods.fallback = fallback
+}
+
+// chain returns the method that an alias stands for, its current resolvers,
+// and the fallback behaviour.
+func (r Registry) chain(method string) (string, []Resolver, Fallback) {
+	r.methods.mu.RLock()
+	defer r.methods.mu.RUnlock()
+	if m, ok := r.methods.aliases[method]; ok {
+		method = m
 	}
+	return method, r.methods.resolvers[method], r.methods.fallback
 }
 
 // Parse parses a did url into a did struct.
 func (r Registry) Parse(did string) (*parse.DID, error) {
-	return parse.Parse(did)
+	return Parse(did)
+}
+
+// Parse parses a did url into a did struct. Unlike the underlying parser, it accepts "_" and percent-encoded
+// characters in the method-specific id, which are valid in did core. "_" is common in base64url encoded ids, and
+// percent-encoding is used for ports in did:web.
+func Parse(did string) (*parse.DID, error) {
+	end := strings.IndexAny(did, ";/?#")
+	if end < 0 {
+		end = len(did)
+	}
+	id := did[:end]
+	if !strings.ContainsAny(id, "_%") {
+		return parse.Parse(did)
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] == '%' && (i+2 >= len(id) || !isHex(id[i+1]) || !isHex(id[i+2])) {
+			return nil, fmt.Errorf("invalid percent-encoding in did: '%s'", did)
+		}
+	}
+	parsed, err := parse.Parse(strings.NewReplacer("_", "-", "%", "-").Replace(id) + did[end:])
+	if err != nil {
+		return nil, err
+	}
+	// The replaced characters are in the same positions, so restore them.
+	parsed.ID = id[len("did:")+len(parsed.Method)+1:]
+	parsed.IDStrings = strings.Split(parsed.ID, ":")
+	return parsed, nil
 }
 
-// Resolve resolves a did url using a Resolver from its internal registry.
-// Currently, resolutionOptions are ignored, as the defaults to not pertain to the resolve function.
+func isHex(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F'
+}
+
+// Resolve resolves a did url using the Resolvers registered for its method, or the method it is an alias of.
+// The resolutionOptions are passed on to resolvers that implement OptionsResolver, and ignored otherwise.
+// Results are only cached when no PublicKeyFormat is requested, and never for methods with a resolver that
+// implements MetadataResolver, as the cache does not hold DocumentMetadata.
 // See https://w3c.github.io/did-core/#did-resolution-options for details.
 func (r Registry) Resolve(did string, resolutionOptions *ResolutionOptions) (ResolutionMetadata, *Document, DocumentMetadata, error) {
 	parsed, err := r.Parse(did)
 	if err != nil {
-		return ResolutionMetadata{Error: "invalidDid"}, nil, DocumentMetadata{}, err
+		return ResolutionMetadata{Error: InvalidDid}, nil, DocumentMetadata{}, err
 	}
-	resolver, ok := r.registry[parsed.Method]
-	var doc *Document
-	if ok && resolver != nil {
-		if r.cache != nil {
-			doc, err = r.cache.Get(parsed, func() (*Document, error) {
-				return resolver.Resolve(parsed.String(), parsed, resolver)
-			})
-		} else {
-			doc, err = resolver.Resolve(parsed.String(), parsed, resolver)
-		}
-		if err != nil {
-			return ResolutionMetadata{Error: "invalidDid"}, nil, DocumentMetadata{}, err
+	method, resolvers, fallback := r.chain(parsed.Method)
+	if len(resolvers) == 0 {
+		return ResolutionMetadata{Error: NotFound}, nil, DocumentMetadata{}, fmt.Errorf("unknown did method: '%s'", parsed.Method)
+	}
+	if method != parsed.Method {
+		aliased := *parsed
+		aliased.Method = method
+		parsed = &aliased
+	}
+	var metadata DocumentMetadata
+	resolve := func() (*Document, error) {
+		var doc *Document
+		for _, resolver := range resolvers {
+			doc, metadata, err = resolveWith(resolver, parsed, resolutionOptions)
+			if err == nil {
+				return doc, nil
+			}
+			if fallback == FallbackNever || (fallback == FallbackOnNotFound && errorCode(err) != NotFound) {
+				break
+			}
 		}
-		if doc == nil {
-			return ResolutionMetadata{Error: "notFound"}, nil, DocumentMetadata{}, fmt.Errorf("resolver returned nil for: '%s'", parsed.String())
+		return nil, err
+	}
+	withMetadata := false
+	for _, resolver := range resolvers {
+		if _, ok := resolver.(MetadataResolver); ok {
+			withMetadata = true
 		}
-		return ResolutionMetadata{}, doc, DocumentMetadata{}, nil
 	}
-	return ResolutionMetadata{Error: "notFound"}, nil, DocumentMetadata{}, fmt.Errorf("unknown did method: '%s'", parsed.Method)
+	var doc *Document
+	if r.cache != nil && !withMetadata && (resolutionOptions == nil || resolutionOptions.PublicKeyFormat == "") {
+		doc, err = r.cache.Get(parsed, resolve)
+	} else {
+		doc, err = resolve()
+	}
+	if err != nil {
+		return ResolutionMetadata{Error: errorCode(err)}, nil, DocumentMetadata{}, err
+	}
+	return ResolutionMetadata{}, doc, metadata, nil
+}
+
+// resolveWith resolves a parsed did with a single resolver. A nil document is
+// reported as a NotFound error.
+func resolveWith(resolver Resolver, parsed *parse.DID, options *ResolutionOptions) (*Document, DocumentMetadata, error) {
+	var doc *Document
+	var metadata DocumentMetadata
+	var err error
+	switch res := resolver.(type) {
+	case MetadataResolver:
+		doc, metadata, err = res.ResolveWithMetadata(parsed.String(), parsed, resolver, options)
+	case OptionsResolver:
+		doc, err = res.ResolveWithOptions(parsed.String(), parsed, resolver, options)
+	default:
+		doc, err = resolver.Resolve(parsed.String(), parsed, resolver)
+	}
+	if err != nil {
+		return nil, DocumentMetadata{}, err
+	}
+	if doc == nil {
+		return nil, DocumentMetadata{}, NewError(NotFound, "resolver returned nil for: '%s'", parsed.String())
+	}
+	return doc, metadata, nil
 }
@@ -5,8 +5,11 @@
 package resolver
 
 import (
+	"encoding/json"
 	"fmt"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 
 	did "github.com/ockam-network/did"
@@ -32,6 +35,39 @@ func TestParseError(t *testing.T) {
 	}
 }
 
+// TestParseUnderscore parses a did with "_" in its method-specific id.
+func TestParseUnderscore(t *testing.T) {
+	parsed, err := Parse("did:basic:abc_def:g_h?versionId=1#key_1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.ID != "abc_def:g_h" || !reflect.DeepEqual(parsed.IDStrings, []string{"abc_def", "g_h"}) {
+		t.Errorf("unexpected id: %s", parsed.ID)
+	}
+	if parsed.Query != "versionId=1" || parsed.Fragment != "key_1" {
+		t.Errorf("unexpected did url: %s", parsed.String())
+	}
+}
+
+// TestParsePercentEncoded parses a did with percent-encoded characters in its method-specific id.
+func TestParsePercentEncoded(t *testing.T) {
+	parsed, err := Parse("did:web:localhost%3A8443:user:a%2Fb#key-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if parsed.ID != "localhost%3A8443:user:a%2Fb" || !reflect.DeepEqual(parsed.IDStrings, []string{"localhost%3A8443", "user", "a%2Fb"}) {
+		t.Errorf("unexpected id: %s", parsed.ID)
+	}
+	if parsed.Fragment != "key-1" {
+		t.Errorf("unexpected did url: %s", parsed.String())
+	}
+	for _, id := range []string{"did:web:localhost%3", "did:web:localhost%zz:user"} {
+		if _, err := Parse(id); err == nil {
+			t.Errorf("expected Parse to return error for: %s", id)
+		}
+	}
+}
+
 type basicResolver struct{}
 
 func (r basicResolver) Resolve(did string, parsed *did.DID, resolver Resolver) (*Document, error) {
@@ -252,3 +288,204 @@ func TestResolverErrorWithCache(t *testing.T) {
 	}
 	// TODO: We SHOULD actually check to make sure the cache wasn't updated.
 }
+
+type optionsResolver struct {
+	basicResolver
+}
+
+func (r optionsResolver) ResolveWithOptions(did string, parsed *did.DID, resolver Resolver, options *ResolutionOptions) (*Document, error) {
+	doc, err := r.Resolve(did, parsed, resolver)
+	if err != nil {
+		return nil, err
+	}
+	if options != nil {
+		doc.Controller = []string{options.PublicKeyFormat}
+	}
+	return doc, nil
+}
+
+// TestResolveWithOptions calls resolve with options, and expects them to be
+// passed on to an OptionsResolver.
+func TestResolveWithOptions(t *testing.T) {
+	r := New([]Resolver{
+		optionsResolver{},
+	}, true)
+	_, observed, _, err := r.Resolve("did:basic:123456789", &ResolutionOptions{PublicKeyFormat: "Multikey"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(observed.Controller, []string{"Multikey"}) {
+		t.Error("expected options to be passed to resolver")
+	}
+}
+
+type metadataResolver struct {
+	basicResolver
+}
+
+func (r metadataResolver) ResolveWithMetadata(did string, parsed *did.DID, resolver Resolver, options *ResolutionOptions) (*Document, DocumentMetadata, error) {
+	doc, err := r.Resolve(did, parsed, resolver)
+	if err != nil {
+		return nil, DocumentMetadata{}, err
+	}
+	return doc, DocumentMetadata{CanonicalID: "did:basic:canonical"}, nil
+}
+
+// TestResolveWithMetadata calls resolve, and expects the document metadata of
+// a MetadataResolver to be returned, even with the cache enabled.
+func TestResolveWithMetadata(t *testing.T) {
+	r := New([]Resolver{
+		metadataResolver{},
+	}, true)
+	for i := 0; i < 2; i++ {
+		_, _, metadata, err := r.Resolve("did:basic:123456789", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if metadata.CanonicalID != "did:basic:canonical" {
+			t.Error("expected metadata to be returned from resolver")
+		}
+	}
+}
+
+type chainResolver struct {
+	name string
+	err  error
+}
+
+func (r *chainResolver) Resolve(did string, parsed *did.DID, resolver Resolver) (*Document, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	return &Document{ID: did, Controller: []string{r.name}}, nil
+}
+
+func (r *chainResolver) Method() string {
+	return "chain"
+}
+
+// TestFallback registers several resolvers for a method, and expects them to
+// be tried in order.
+func TestFallback(t *testing.T) {
+	mirror := &chainResolver{name: "mirror", err: NewError(NotFound, "not mirrored")}
+	broken := &chainResolver{name: "broken", err: fmt.Errorf("connection refused")}
+	network := &chainResolver{name: "network"}
+	r := New([]Resolver{mirror, broken, network}, false)
+
+	_, doc, _, err := r.Resolve("did:chain:123456789", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(doc.Controller, []string{"network"}) {
+		t.Errorf("unexpected resolver: %v", doc.Controller)
+	}
+
+	r.SetFallback(FallbackOnNotFound)
+	_, _, _, err = r.Resolve("did:chain:123456789", nil)
+	if err == nil || err.Error() != "connection refused" {
+		t.Error("expected Resolve to return error")
+	}
+
+	r.SetFallback(FallbackNever)
+	metadata, _, _, err := r.Resolve("did:chain:123456789", nil)
+	if err == nil || metadata.Error != NotFound {
+		t.Error("expected Resolve to return notFound error")
+	}
+
+	// Without the failing resolvers, the network resolver is used again.
+	r.Unregister(mirror)
+	r.Unregister(broken)
+	_, doc, _, err = r.Resolve("did:chain:123456789", nil)
+	if err != nil || !reflect.DeepEqual(doc.Controller, []string{"network"}) {
+		t.Errorf("unexpected result: %v %v", doc, err)
+	}
+	r.Unregister(network)
+	_, _, _, err = r.Resolve("did:chain:123456789", nil)
+	if err == nil || err.Error() != "unknown did method: 'chain'" {
+		t.Error("expected Resolve to return error")
+	}
+}
+
+// TestAlias resolves a did through a method alias, and expects the did to be
+// rewritten to the aliased method.
+func TestAlias(t *testing.T) {
+	r := New([]Resolver{&chainResolver{name: "chain"}}, true)
+	r.Alias("link", "chain")
+	_, doc, _, err := r.Resolve("did:link:123456789", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.ID != "did:chain:123456789" {
+		t.Errorf("unexpected id: %s", doc.ID)
+	}
+	r.Alias("link", "")
+	_, _, _, err = r.Resolve("did:link:123456789", nil)
+	if err == nil || err.Error() != "unknown did method: 'link'" {
+		t.Error("expected Resolve to return error")
+	}
+}
+
+// TestConcurrentRegister registers and unregisters resolvers while resolving,
+// which should be caught by the race detector if it is not safe.
+func TestConcurrentRegister(t *testing.T) {
+	r := New([]Resolver{&chainResolver{name: "first"}}, true)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			if _, _, _, err := r.Resolve(fmt.Sprintf("did:chain:%d", i), nil); err != nil {
+				t.Error(err)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			extra := &chainResolver{name: "extra"}
+			r.Register(extra)
+			r.Unregister(extra)
+		}()
+	}
+	wg.Wait()
+}
+
+// TestVerificationMethodReference checks that references are encoded as plain
+// strings, and decoded back again.
+func TestVerificationMethodReference(t *testing.T) {
+	doc := &Document{
+		Context: []string{"https://www.w3.org/ns/did/v1"},
+		ID:      "did:basic:123456789",
+		VerificationMethod: []VerificationMethod{
+			{
+				ID:                 "did:basic:123456789#key",
+				Type:               "Multikey",
+				Controller:         "did:basic:123456789",
+				PublicKeyMultibase: "z6MktvqCyLxTsXUH1tUZncNdVeEZ7hNh7npPRbUU27GTrYb8",
+			},
+		},
+		Authentication: []VerificationMethod{Reference("did:basic:123456789#key")},
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"authentication":["did:basic:123456789#key"]`) {
+		t.Errorf("expected reference to be a string: %s", data)
+	}
+	var observed Document
+	if err := json.Unmarshal(data, &observed); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(&observed, doc) {
+		t.Errorf("expected document to match: %s", doc.ID)
+	}
+}
+
+func TestAbsoluteID(t *testing.T) {
+	did := "did:basic:123456789"
+	if observed := AbsoluteID(did, "#key"); observed != did+"#key" {
+		t.Errorf("unexpected id: %s", observed)
+	}
+	if observed := AbsoluteID(did, "did:other:1#key"); observed != "did:other:1#key" {
+		t.Errorf("unexpected id: %s", observed)
+	}
+}
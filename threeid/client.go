@@ -7,9 +7,10 @@ package threeid
 
 import (
 	"encoding/json"
-	"fmt"
 	"net/http"
 	"time"
+
+	"github.com/textileio/go-did-resolver/internal/httpclient"
 )
 
 const (
@@ -76,26 +77,53 @@ type AnchorProof struct {
 
 // HTTPClient interfaces with the ceramic network via local or remote ceramic daemon.
 type HTTPClient struct {
+	// APIURL is the url of the Ceramic api, e.g. DefaultHost + DefaultAPIPath.
 	APIURL string
+	// HTTP makes the api requests. If nil, they are limited to DefaultTimeout.
+	HTTP *http.Client
+	// Retry controls how failed requests are retried.
+	Retry RetryPolicy
 }
 
-// Load fetches the remote Ceramic document and returns it.
+// Load fetches the remote Ceramic document and returns it. Errors are
+// resolver.Errors wrapping an APIError.
 func (client *HTTPClient) Load(docID DocIdentifier) (*DocResponse, error) {
-	var c = &http.Client{Timeout: 10 * time.Second}
-	resp, err := c.Get(client.APIURL + "/documents/" + docID.String())
+	resp, err := client.get(client.APIURL + "/documents/" + docID.String())
 	if err != nil {
-		return nil, err
+		return nil, apiError(ErrUnavailable, 0, "%v", err)
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, responseError(resp)
+	}
 
-	if resp.StatusCode == http.StatusOK {
-		var state DocResponse
-		json.NewDecoder(resp.Body).Decode(&state)
-		return &state, nil
+	var state DocResponse
+	if err := json.NewDecoder(resp.Body).Decode(&state); err != nil {
+		return nil, apiError(ErrMalformedResponse, resp.StatusCode, "invalid json: %v", err)
+	}
+	if err := validateResponse(docID, &state); err != nil {
+		return nil, err
+	}
+	return &state, nil
+}
 
+// get fetches url, retrying network errors, 429 and 5xx responses as allowed
+// by the retry policy.
+func (client *HTTPClient) get(url string) (*http.Response, error) {
+	c := httpclient.Or(client.HTTP)
+	backoff := client.Retry.Backoff
+	for attempt := 0; ; attempt++ {
+		resp, err := c.Get(url)
+		retry := err != nil || resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500
+		if !retry || attempt >= client.Retry.Retries {
+			return resp, err
+		}
+		if resp != nil {
+			resp.Body.Close()
+		}
+		time.Sleep(backoff)
+		backoff *= 2
 	}
-	// TODO: Should return a nicer error here
-	return nil, fmt.Errorf(resp.Status)
 }
 
 // LoadDocument loads a ceramic document using a local or remote ceramic daemon.
@@ -14,7 +14,7 @@ import (
 
 func TestBasicClient(t *testing.T) {
 	client := HTTPClient{
-		DefaultHost + DefaultAPIPath,
+		APIURL: DefaultHost + DefaultAPIPath,
 	}
 	docID, err := fromString("ceramic://kjzl6cwe1jw14aa2ugzr1zumd8f2wtyl986skkv8kimbhxf39ajwek6gqrnxvfa")
 	if err != nil {
@@ -0,0 +1,114 @@
+// Package threeid provides tools for resolving the did:3 method format for
+// the ceramic network:
+// https://github.com/ceramicnetwork/CIP/blob/main/CIPs/CIP-79/CIP-79.md
+// Copyright 2021 Textile
+// Copyright 2021 Ceramic Network
+package threeid
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	resolver "github.com/textileio/go-did-resolver/resolver"
+)
+
+// maxErrorBodySize limits how much of an error response body is read.
+const maxErrorBodySize = 64 << 10
+
+var (
+	// ErrNotFound indicates that the Ceramic daemon has no document for the
+	// requested id.
+	ErrNotFound = errors.New("ceramic document not found")
+	// ErrUnavailable indicates that the Ceramic daemon could not be reached,
+	// or failed to handle the request.
+	ErrUnavailable = errors.New("ceramic daemon unavailable")
+	// ErrMalformedResponse indicates that the Ceramic daemon responded with
+	// something other than the requested document.
+	ErrMalformedResponse = errors.New("malformed ceramic response")
+)
+
+// APIError is an error loading a document from the Ceramic api. Errors
+// returned by HTTPClient are resolver.Errors wrapping an APIError, so both
+// errors.Is with its Kind and errors.As work on them.
+type APIError struct {
+	// Kind is ErrNotFound, ErrUnavailable or ErrMalformedResponse.
+	Kind error
+	// StatusCode is the http status of the response, or zero without one.
+	StatusCode int
+	// Message describes the error, from the error body of the daemon when it
+	// has one.
+	Message string
+}
+
+func (e *APIError) Error() string {
+	msg := e.Kind.Error()
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(" (%d)", e.StatusCode)
+	}
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// Unwrap returns the Kind of the error.
+func (e *APIError) Unwrap() error {
+	return e.Kind
+}
+
+// Code returns the resolution error code of the error.
+func (e *APIError) Code() string {
+	if e.Kind == ErrNotFound {
+		return resolver.NotFound
+	}
+	return resolver.InternalError
+}
+
+// apiError returns an APIError wrapped in a resolver.Error with its code.
+func apiError(kind error, status int, format string, args ...interface{}) error {
+	err := &APIError{Kind: kind, StatusCode: status, Message: fmt.Sprintf(format, args...)}
+	return &resolver.Error{Code: err.Code(), Err: err}
+}
+
+// responseError returns the error of a response that is not 200 OK, with the
+// message of the daemon's {"error": "..."} body when it has one.
+func responseError(resp *http.Response) error {
+	kind := ErrMalformedResponse
+	switch {
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		kind = ErrNotFound
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500:
+		kind = ErrUnavailable
+	}
+	message := resp.Status
+	body, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxErrorBodySize))
+	if err == nil {
+		var daemonError struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(body, &daemonError) == nil && daemonError.Error != "" {
+			message = daemonError.Error
+		}
+	}
+	return apiError(kind, resp.StatusCode, "%s", message)
+}
+
+// validateResponse checks that a response is the document of docID, with
+// content.
+func validateResponse(docID DocIdentifier, resp *DocResponse) error {
+	returned, err := fromString(resp.DocID)
+	if err != nil {
+		return apiError(ErrMalformedResponse, http.StatusOK, "invalid document id: '%s'", resp.DocID)
+	}
+	if returned.Type() != docID.Type() || !returned.CID().Equals(docID.CID()) {
+		return apiError(ErrMalformedResponse, http.StatusOK, "document id does not match requested id: '%s'", resp.DocID)
+	}
+	if resp.State.Content == nil {
+		return apiError(ErrMalformedResponse, http.StatusOK, "document has no content")
+	}
+	return nil
+}
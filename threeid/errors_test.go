@@ -0,0 +1,122 @@
+// Package threeid provides tools for resolving the did:3 method format for
+// the ceramic network:
+// https://github.com/ceramicnetwork/CIP/blob/main/CIPs/CIP-79/CIP-79.md
+// Copyright 2021 Textile
+// Copyright 2021 Ceramic Network
+package threeid
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+// responseServer responds to every request with the status and body.
+func responseServer(status int, body string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+}
+
+func TestLoadErrors(t *testing.T) {
+	docID, err := fromString(strings.TrimPrefix(Fake3ID, "did:3:"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := fromString("kjzl6cwe1jw148t9pgxvoty45b02rztk3f9zaf45d5yxwarikwgrds8rcke8uuv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content := `{"publicKeys": {}}`
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		kind    error
+		code    string
+		message string
+	}{
+		{"not found", http.StatusNotFound, `{"error": "stream not found"}`, ErrNotFound, resolver.NotFound, "stream not found"},
+		{"unavailable", http.StatusServiceUnavailable, `unavailable`, ErrUnavailable, resolver.InternalError, "503 Service Unavailable"},
+		{"daemon error", http.StatusInternalServerError, `{"error": "failed to load"}`, ErrUnavailable, resolver.InternalError, "failed to load"},
+		{"bad request", http.StatusBadRequest, `{"error": "invalid stream id"}`, ErrMalformedResponse, resolver.InternalError, "invalid stream id"},
+		{"invalid json", http.StatusOK, `{"docId": `, ErrMalformedResponse, resolver.InternalError, "invalid json"},
+		{"missing id", http.StatusOK, `{"state": {"content": ` + content + `}}`, ErrMalformedResponse, resolver.InternalError, "invalid document id"},
+		{"other id", http.StatusOK, `{"docId": "` + other.String() + `", "state": {"content": ` + content + `}}`, ErrMalformedResponse, resolver.InternalError, "does not match"},
+		{"no content", http.StatusOK, `{"docId": "` + docID.String() + `", "state": {}}`, ErrMalformedResponse, resolver.InternalError, "no content"},
+	}
+	for _, test := range tests {
+		server := responseServer(test.status, test.body)
+		client := &HTTPClient{APIURL: server.URL + DefaultAPIPath}
+		_, err := client.Load(docID)
+		server.Close()
+		if !errors.Is(err, test.kind) {
+			t.Errorf("%s: unexpected error: %v", test.name, err)
+			continue
+		}
+		var resolutionErr *resolver.Error
+		if !errors.As(err, &resolutionErr) || resolutionErr.Code != test.code {
+			t.Errorf("%s: unexpected error code: %v", test.name, err)
+		}
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || !strings.Contains(apiErr.Message, test.message) {
+			t.Errorf("%s: unexpected error message: %v", test.name, err)
+		}
+	}
+
+	server := responseServer(http.StatusOK, "")
+	server.Close()
+	client := &HTTPClient{APIURL: server.URL + DefaultAPIPath}
+	if _, err := client.Load(docID); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected daemon to be unavailable: %v", err)
+	}
+}
+
+func TestResolveErrorCodes(t *testing.T) {
+	server := responseServer(http.StatusNotFound, `{"error": "stream not found"}`)
+	defer server.Close()
+	registry := resolver.New([]resolver.Resolver{New(WithAPIURL(server.URL + DefaultAPIPath))}, false)
+	metadata, _, _, err := registry.Resolve(Fake3ID, nil)
+	if err == nil || metadata.Error != resolver.NotFound {
+		t.Errorf("expected not found: %v", err)
+	}
+}
+
+// stateClient is a Client that returns a fixed document state.
+type stateClient struct {
+	state *DocState
+}
+
+func (c stateClient) LoadDocument(docID DocIdentifier) (*DocState, error) {
+	return c.state, nil
+}
+
+func TestResolveInvalidContent(t *testing.T) {
+	raw := func(str string) *json.RawMessage {
+		content := json.RawMessage(str)
+		return &content
+	}
+	tests := []struct {
+		name  string
+		state *DocState
+		code  string
+	}{
+		{"nil state", nil, resolver.InternalError},
+		{"nil content", &DocState{}, resolver.InternalError},
+		{"invalid content", &DocState{Content: raw(`[]`)}, resolver.InternalError},
+		{"invalid key", &DocState{Content: raw(`{"publicKeys": {"key": "not a key"}}`)}, resolver.InvalidPublicKey},
+	}
+	for _, test := range tests {
+		registry := resolver.New([]resolver.Resolver{New(WithClient(stateClient{test.state}))}, false)
+		metadata, _, _, err := registry.Resolve(Fake3ID, nil)
+		if err == nil || metadata.Error != test.code {
+			t.Errorf("%s: unexpected error: %s %v", test.name, metadata.Error, err)
+		}
+	}
+}
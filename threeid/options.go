@@ -0,0 +1,91 @@
+// Package threeid provides tools for resolving the did:3 method format for
+// the ceramic network:
+// https://github.com/ceramicnetwork/CIP/blob/main/CIPs/CIP-79/CIP-79.md
+// Copyright 2021 Textile
+// Copyright 2021 Ceramic Network
+package threeid
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/textileio/go-did-resolver/internal/httpclient"
+)
+
+// DefaultTimeout is the default time limit of requests to the Ceramic api.
+const DefaultTimeout = httpclient.DefaultTimeout
+
+// RetryPolicy controls how failed requests to the Ceramic api are retried.
+// Network errors, 429 Too Many Requests and 5xx responses are retried.
+type RetryPolicy struct {
+	// Retries is the number of times a request is retried. Zero disables
+	// retries.
+	Retries int
+	// Backoff is the delay before the first retry, which doubles with each
+	// retry.
+	Backoff time.Duration
+}
+
+// Option configures a Resolver.
+type Option func(*config)
+
+// config holds the settings of a Resolver.
+type config struct {
+	apiURL    string
+	client    Client
+	transport http.RoundTripper
+	timeout   time.Duration
+	retry     RetryPolicy
+}
+
+// WithAPIURL sets the url of the Ceramic api, which is DefaultHost +
+// DefaultAPIPath by default.
+func WithAPIURL(url string) Option {
+	return func(c *config) {
+		c.apiURL = url
+	}
+}
+
+// WithClient sets the Client used to load documents. The other options do not
+// apply to it.
+func WithClient(client Client) Option {
+	return func(c *config) {
+		c.client = client
+	}
+}
+
+// WithTransport sets the transport of requests to the Ceramic api, which is
+// http.DefaultTransport by default.
+func WithTransport(transport http.RoundTripper) Option {
+	return func(c *config) {
+		c.transport = transport
+	}
+}
+
+// WithTimeout sets the time limit of requests to the Ceramic api, which is
+// DefaultTimeout by default. Zero removes the limit.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.timeout = timeout
+	}
+}
+
+// WithRetryPolicy sets how failed requests to the Ceramic api are retried.
+// By default, they are not.
+func WithRetryPolicy(policy RetryPolicy) Option {
+	return func(c *config) {
+		c.retry = policy
+	}
+}
+
+// httpClient returns the Client of the configuration.
+func (c *config) httpClient() Client {
+	if c.client != nil {
+		return c.client
+	}
+	return &HTTPClient{
+		APIURL: c.apiURL,
+		HTTP:   &http.Client{Transport: c.transport, Timeout: c.timeout},
+		Retry:  c.retry,
+	}
+}
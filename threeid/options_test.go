@@ -0,0 +1,117 @@
+// Package threeid provides tools for resolving the did:3 method format for
+// the ceramic network:
+// https://github.com/ceramicnetwork/CIP/blob/main/CIPs/CIP-79/CIP-79.md
+// Copyright 2021 Textile
+// Copyright 2021 Ceramic Network
+package threeid
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	did "github.com/ockam-network/did"
+	"github.com/textileio/go-did-resolver/internal/httpclient"
+)
+
+// ceramicServer serves the document of mockClient from a Ceramic api, after
+// failing the first requests.
+func ceramicServer(t *testing.T, failures int32, delay time.Duration) (*httptest.Server, *int32) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= failures {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if !strings.HasPrefix(r.URL.Path, DefaultAPIPath+"/documents/") {
+			http.NotFound(w, r)
+			return
+		}
+		time.Sleep(delay)
+		state, err := (&mockClient{}).LoadDocument(nil)
+		if err != nil {
+			t.Error(err)
+		}
+		docID, err := fromString(strings.TrimPrefix(Fake3ID, "did:3:"))
+		if err != nil {
+			t.Error(err)
+		}
+		json.NewEncoder(w).Encode(DocResponse{DocID: docID.String(), State: *state})
+	}))
+	return server, &requests
+}
+
+func resolveFake(r *Resolver) error {
+	parsed, err := did.Parse(Fake3ID)
+	if err != nil {
+		return err
+	}
+	_, err = r.Resolve(Fake3ID, parsed, r)
+	return err
+}
+
+func TestNewDefaults(t *testing.T) {
+	client, ok := New().client.(*HTTPClient)
+	if !ok {
+		t.Fatal("expected an HTTPClient")
+	}
+	if client.APIURL != DefaultHost+DefaultAPIPath || client.HTTP.Timeout != DefaultTimeout || client.Retry.Retries != 0 {
+		t.Errorf("unexpected client: %+v", client)
+	}
+	mock := &mockClient{}
+	if New(WithClient(mock)).client != mock {
+		t.Error("expected the client option to be used")
+	}
+}
+
+func TestAPIURL(t *testing.T) {
+	server, requests := ceramicServer(t, 0, 0)
+	defer server.Close()
+	if err := resolveFake(New(WithAPIURL(server.URL + DefaultAPIPath))); err != nil {
+		t.Fatal(err)
+	}
+	if *requests != 1 {
+		t.Errorf("unexpected requests: %d", *requests)
+	}
+}
+
+func TestTransportAndTimeout(t *testing.T) {
+	server, _ := ceramicServer(t, 0, 100*time.Millisecond)
+	defer server.Close()
+	transport := &httpclient.CountingTransport{}
+	if err := resolveFake(New(WithAPIURL(server.URL+DefaultAPIPath), WithTransport(transport))); err != nil {
+		t.Fatal(err)
+	}
+	if transport.Requests() != 1 {
+		t.Errorf("expected the transport to be used, got %d requests", transport.Requests())
+	}
+	if err := resolveFake(New(WithAPIURL(server.URL+DefaultAPIPath), WithTimeout(10*time.Millisecond))); err == nil {
+		t.Error("expected Resolve to time out")
+	}
+}
+
+func TestRetryPolicy(t *testing.T) {
+	server, requests := ceramicServer(t, 2, 0)
+	defer server.Close()
+	r := New(WithAPIURL(server.URL+DefaultAPIPath), WithRetryPolicy(RetryPolicy{Retries: 1, Backoff: time.Millisecond}))
+	if err := resolveFake(r); err == nil {
+		t.Error("expected Resolve to return error")
+	}
+	if *requests != 2 {
+		t.Errorf("unexpected requests: %d", *requests)
+	}
+
+	server, requests = ceramicServer(t, 2, 0)
+	defer server.Close()
+	r = New(WithAPIURL(server.URL+DefaultAPIPath), WithRetryPolicy(RetryPolicy{Retries: 2, Backoff: time.Millisecond}))
+	if err := resolveFake(r); err != nil {
+		t.Fatal(err)
+	}
+	if *requests != 3 {
+		t.Errorf("unexpected requests: %d", *requests)
+	}
+}
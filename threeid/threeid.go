@@ -13,8 +13,8 @@ import (
 	cid "github.com/ipfs/go-cid"
 	multibase "github.com/multiformats/go-multibase"
 	codec "github.com/multiformats/go-multicodec"
-	varint "github.com/multiformats/go-varint"
 	did "github.com/ockam-network/did"
+	"github.com/textileio/go-did-resolver/multikey"
 	resolver "github.com/textileio/go-did-resolver/resolver"
 )
 
@@ -23,9 +23,15 @@ type Resolver struct {
 	client Client
 }
 
-// New creates and returns a new key Resolver.
-func New() *Resolver {
-	return &Resolver{}
+// New creates and returns a new key Resolver, configured by opts. Without
+// options, documents are loaded from the Ceramic api at DefaultHost +
+// DefaultAPIPath.
+func New(opts ...Option) *Resolver {
+	c := &config{apiURL: DefaultHost + DefaultAPIPath, timeout: DefaultTimeout}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return &Resolver{client: c.httpClient()}
 }
 
 // Method returns the method that this resolver is capable of resolving.
@@ -74,32 +80,28 @@ func resolve(client Client, id string, commit cid.Cid) (*resolver.Document, erro
 		PublicKeys map[string]string `json:"publicKeys,omitempty"`
 	}
 
+	if state == nil || state.Content == nil {
+		return nil, apiError(ErrMalformedResponse, 0, "document has no content")
+	}
 	var content Content
 	err = json.Unmarshal(*state.Content, &content)
 	if err != nil {
-		return nil, err
+		return nil, apiError(ErrMalformedResponse, 0, "invalid document content: %v", err)
 	}
 
 	// Loop through content.publicKeys to sort things out
 	for keyName, keyValue := range content.PublicKeys {
-		_, keyBuf, err := multibase.Decode(keyValue)
-		if err != nil {
-			return nil, err
-		}
-		keyType, n, err := varint.FromUvarint(keyBuf)
+		key, err := multikey.Decode(keyValue)
 		if err != nil {
-			return nil, err
-		}
-		if n != 2 {
-			return nil, fmt.Errorf("error parsing varint")
+			return nil, resolver.NewError(resolver.InvalidPublicKey, "invalid public key '%s': %v", keyName, err)
 		}
-		publicKeyBase58, err := multibase.Encode(multibase.Base58BTC, keyBuf[2:])
+		publicKeyBase58, err := multibase.Encode(multibase.Base58BTC, key.Bytes)
 		if err != nil {
 			return nil, err
 		}
 		keyID := fmt.Sprintf("%s#%s", did, keyName)
-		switch keyType {
-		case uint64(codec.Secp256k1Pub):
+		switch key.Code {
+		case codec.Secp256k1Pub:
 			doc.VerificationMethod = append(doc.VerificationMethod, resolver.VerificationMethod{
 				ID:                 keyID,
 				Type:               "Secp256k1VerificationKey2018",
@@ -110,7 +112,7 @@ func resolve(client Client, id string, commit cid.Cid) (*resolver.Document, erro
 				Type:      "Secp256k1SignatureAuthentication2018",
 				PublicKey: keyID,
 			})
-		case uint64(codec.X25519Pub):
+		case codec.X25519Pub:
 			// Old key format, likely not needed in the future
 			doc.VerificationMethod = append(doc.VerificationMethod, resolver.VerificationMethod{
 				ID:                 keyID,
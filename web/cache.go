@@ -0,0 +1,202 @@
+// Package web provides tools for resolving dids formed by taking an http(s)
+// domain and producing a well-known URI to access the did document.
+// See https://tools.ietf.org/html/rfc5785 for details.
+// Copyright 2021 Textile
+// Copyright 2021 Decentralized Identity Foundation
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCacheSize is the default number of documents held by the HTTP cache
+// of a Resolver.
+const DefaultCacheSize = 1024
+
+// WithCacheSize sets the number of documents held by the HTTP cache of the
+// Resolver, which is DefaultCacheSize by default. Zero or less disables the
+// cache.
+func WithCacheSize(size int) Option {
+	return func(c *config) {
+		c.cacheSize = size
+	}
+}
+
+// cacheEntry is a cached response body, with its validators and the time
+// until which it is fresh.
+type cacheEntry struct {
+	body         []byte
+	contentType  string
+	etag         string
+	lastModified string
+	expires      time.Time
+}
+
+// httpCache holds response bodies by url, following the caching semantics of
+// a private cache: https://tools.ietf.org/html/rfc7234
+// Responses are stored when they have validators or a freshness lifetime,
+// unless they have a no-store directive. Stale entries are revalidated with
+// conditional requests.
+type httpCache struct {
+	lock    sync.Mutex
+	size    int
+	entries map[string]*cacheEntry
+	now     func() time.Time
+}
+
+func newHTTPCache(size int) *httpCache {
+	if size <= 0 {
+		return nil
+	}
+	return &httpCache{size: size, entries: make(map[string]*cacheEntry), now: time.Now}
+}
+
+// get returns the entry for url, and whether it is fresh.
+func (c *httpCache) get(url string) (*cacheEntry, bool) {
+	if c == nil {
+		return nil, false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	entry, ok := c.entries[url]
+	if !ok {
+		return nil, false
+	}
+	return entry, c.now().Before(entry.expires)
+}
+
+// validators returns the conditional request headers that revalidate entry.
+func (e *cacheEntry) validators() http.Header {
+	header := http.Header{}
+	if e == nil {
+		return header
+	}
+	if e.etag != "" {
+		header.Set("If-None-Match", e.etag)
+	}
+	if e.lastModified != "" {
+		header.Set("If-Modified-Since", e.lastModified)
+	}
+	return header
+}
+
+// store caches body for url, according to the headers of its response, and
+// returns the entry, which is only stored when cacheable.
+func (c *httpCache) store(url string, body []byte, header http.Header) *cacheEntry {
+	entry := &cacheEntry{
+		body:         body,
+		contentType:  header.Get("Content-Type"),
+		etag:         header.Get("ETag"),
+		lastModified: header.Get("Last-Modified"),
+	}
+	if c == nil {
+		return entry
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	lifetime, store := freshness(header, c.now())
+	if !store || (lifetime <= 0 && entry.etag == "" && entry.lastModified == "") {
+		delete(c.entries, url)
+		return entry
+	}
+	entry.expires = c.now().Add(lifetime)
+	if _, ok := c.entries[url]; !ok && len(c.entries) >= c.size {
+		c.evict()
+	}
+	c.entries[url] = entry
+	return entry
+}
+
+// refresh updates the entry for url after a 304 Not Modified response, and
+// returns it.
+func (c *httpCache) refresh(url string, entry *cacheEntry, header http.Header) *cacheEntry {
+	updated := *entry
+	if etag := header.Get("ETag"); etag != "" {
+		updated.etag = etag
+	}
+	if lastModified := header.Get("Last-Modified"); lastModified != "" {
+		updated.lastModified = lastModified
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	lifetime, store := freshness(header, c.now())
+	if !store {
+		delete(c.entries, url)
+		return &updated
+	}
+	updated.expires = c.now().Add(lifetime)
+	c.entries[url] = &updated
+	return &updated
+}
+
+// remove deletes the entry for url.
+func (c *httpCache) remove(url string) {
+	if c == nil {
+		return
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	delete(c.entries, url)
+}
+
+// evict removes the entry that expires first.
+func (c *httpCache) evict() {
+	var first string
+	var expires time.Time
+	for url, entry := range c.entries {
+		if first == "" || entry.expires.Before(expires) {
+			first, expires = url, entry.expires
+		}
+	}
+	delete(c.entries, first)
+}
+
+// freshness returns the freshness lifetime of a response from its
+// Cache-Control, Expires and Age headers, and whether it may be stored.
+// Responses with no-cache must be revalidated, so have no lifetime.
+func freshness(header http.Header, now time.Time) (time.Duration, bool) {
+	var lifetime time.Duration
+	maxAge, noCache := false, false
+	for _, directive := range strings.Split(header.Get("Cache-Control"), ",") {
+		name, value := strings.TrimSpace(directive), ""
+		if i := strings.Index(name, "="); i >= 0 {
+			name, value = strings.TrimSpace(name[:i]), strings.Trim(strings.TrimSpace(name[i+1:]), `"`)
+		}
+		switch strings.ToLower(name) {
+		case "no-store":
+			return 0, false
+		case "no-cache":
+			noCache = true
+		case "max-age":
+			seconds, err := strconv.ParseInt(value, 10, 64)
+			if err != nil || seconds < 0 {
+				noCache = true
+			}
+			lifetime, maxAge = time.Duration(seconds)*time.Second, true
+		}
+	}
+	if noCache {
+		return 0, true
+	}
+	if !maxAge {
+		if expires := header.Get("Expires"); expires != "" {
+			t, err := http.ParseTime(expires)
+			if err != nil {
+				return 0, true
+			}
+			date, err := http.ParseTime(header.Get("Date"))
+			if err != nil {
+				date = now
+			}
+			lifetime = t.Sub(date)
+		}
+	}
+	if age, err := strconv.ParseInt(header.Get("Age"), 10, 64); err == nil && age > 0 {
+		lifetime -= time.Duration(age) * time.Second
+	}
+	return lifetime, true
+}
@@ -0,0 +1,156 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/ockam-network/did"
+)
+
+// cacheServer serves a document for did:web:example.com with the headers, and
+// counts requests and full responses.
+type cacheServer struct {
+	header    http.Header
+	requests  int
+	responses int
+	last      *http.Request
+}
+
+func (s *cacheServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.requests++
+	s.last = r
+	for key, values := range s.header {
+		w.Header()[key] = values
+	}
+	if etag := s.header.Get("ETag"); etag != "" && r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	s.responses++
+	json.NewEncoder(w).Encode(testDocument("did:web:example.com", "1234"))
+}
+
+func resolveCached(t *testing.T, r *Resolver) string {
+	id := "did:web:example.com"
+	parsed, err := did.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc, metadata, err := r.ResolveWithMetadata(id, parsed, r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.ID != id {
+		t.Errorf("unexpected document: %s", doc.ID)
+	}
+	return metadata.Updated
+}
+
+func TestCacheMaxAge(t *testing.T) {
+	handler := &cacheServer{header: http.Header{"Cache-Control": {"public, max-age=60"}, "Etag": {`"v1"`}}}
+	server := httptest.NewTLSServer(handler)
+	defer server.Close()
+	r := New(WithClient(server.Client()))
+	now := time.Now()
+	r.cache.now = func() time.Time { return now }
+
+	resolveCached(t, r)
+	resolveCached(t, r)
+	if handler.requests != 1 {
+		t.Errorf("expected fresh document to be cached, got %d requests", handler.requests)
+	}
+	now = now.Add(time.Minute)
+	resolveCached(t, r)
+	if handler.requests != 2 || handler.responses != 1 {
+		t.Errorf("expected stale document to be revalidated, got %d requests", handler.requests)
+	}
+	if handler.last.Header.Get("If-None-Match") != `"v1"` {
+		t.Error("expected conditional request")
+	}
+	resolveCached(t, r)
+	if handler.requests != 2 {
+		t.Errorf("expected revalidated document to be fresh, got %d requests", handler.requests)
+	}
+}
+
+func TestCacheNoStore(t *testing.T) {
+	handler := &cacheServer{header: http.Header{"Cache-Control": {"no-store"}, "Etag": {`"v1"`}}}
+	server := httptest.NewTLSServer(handler)
+	defer server.Close()
+	r := New(WithClient(server.Client()))
+
+	resolveCached(t, r)
+	resolveCached(t, r)
+	if handler.responses != 2 || handler.last.Header.Get("If-None-Match") != "" {
+		t.Errorf("expected document not to be stored, got %d responses", handler.responses)
+	}
+}
+
+func TestCacheDisabled(t *testing.T) {
+	handler := &cacheServer{header: http.Header{"Cache-Control": {"max-age=60"}}}
+	server := httptest.NewTLSServer(handler)
+	defer server.Close()
+	r := New(WithClient(server.Client()), WithCacheSize(0))
+
+	resolveCached(t, r)
+	resolveCached(t, r)
+	if handler.responses != 2 {
+		t.Errorf("expected document not to be cached, got %d responses", handler.responses)
+	}
+}
+
+func TestConditionalGet(t *testing.T) {
+	lastModified := "Wed, 21 Oct 2015 07:28:00 GMT"
+	handler := &cacheServer{header: http.Header{"Cache-Control": {"no-cache"}, "Etag": {`"v1"`}, "Last-Modified": {lastModified}}}
+	server := httptest.NewTLSServer(handler)
+	defer server.Close()
+	r := New(WithClient(server.Client()))
+
+	for i := 0; i < 3; i++ {
+		if updated := resolveCached(t, r); updated != "2015-10-21T07:28:00Z" {
+			t.Errorf("unexpected updated: %s", updated)
+		}
+	}
+	if handler.requests != 3 || handler.responses != 1 {
+		t.Errorf("expected document to be revalidated, got %d responses", handler.responses)
+	}
+	if handler.last.Header.Get("If-Modified-Since") != lastModified {
+		t.Error("expected conditional request")
+	}
+
+	handler.header.Set("Etag", `"v2"`)
+	resolveCached(t, r)
+	if handler.responses != 2 {
+		t.Error("expected modified document to be fetched")
+	}
+}
+
+func TestFreshness(t *testing.T) {
+	now := time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		header   http.Header
+		lifetime time.Duration
+		store    bool
+	}{
+		{http.Header{}, 0, true},
+		{http.Header{"Cache-Control": {"max-age=60"}}, time.Minute, true},
+		{http.Header{"Cache-Control": {`max-age="60", must-revalidate`}}, time.Minute, true},
+		{http.Header{"Cache-Control": {"max-age=60"}, "Age": {"20"}}, 40 * time.Second, true},
+		{http.Header{"Cache-Control": {"no-cache, max-age=60"}}, 0, true},
+		{http.Header{"Cache-Control": {"max-age=60, no-store"}}, 0, false},
+		{http.Header{"Cache-Control": {"no-cache, no-store"}}, 0, false},
+		{http.Header{"Cache-Control": {"max-age=invalid"}}, 0, true},
+		{http.Header{"Expires": {"Fri, 01 Jan 2021 00:10:00 GMT"}}, 10 * time.Minute, true},
+		{http.Header{"Expires": {"Fri, 01 Jan 2021 00:10:00 GMT"}, "Date": {"Fri, 01 Jan 2021 00:05:00 GMT"}}, 5 * time.Minute, true},
+		{http.Header{"Expires": {"0"}}, 0, true},
+	}
+	for _, test := range tests {
+		lifetime, store := freshness(test.header, now)
+		if lifetime != test.lifetime || store != test.store {
+			t.Errorf("unexpected freshness for %v: %s %v", test.header, lifetime, store)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+// Package web provides tools for resolving dids formed by taking an http(s)
+// domain and producing a well-known URI to access the did document.
+// See https://tools.ietf.org/html/rfc5785 for details.
+// Copyright 2021 Textile
+// Copyright 2021 Decentralized Identity Foundation
+package web
+
+import (
+	"net/url"
+	"strings"
+)
+
+// WithInsecureHTTP fetches from the hosts over plain http instead of https.
+// A host is a domain, such as "localhost", or a pattern of its subdomains, such
+// as "*.test". This is INSECURE, as documents can be modified in transit, and
+// must only be used for local development and tests.
+func WithInsecureHTTP(hosts ...string) Option {
+	return func(c *config) {
+		c.insecureHosts = append(c.insecureHosts, hosts...)
+	}
+}
+
+// WithInsecureBaseURL fetches from base instead of https://{host}, for a host
+// with an optional port, such as "example.com:3000". For example, with a base
+// http://127.0.0.1:8080/example, the document of did:web:example.com is
+// fetched from http://127.0.0.1:8080/example/.well-known/did.json. This is
+// INSECURE, as the document is not served by its domain, and must only be used
+// for local development and tests.
+func WithInsecureBaseURL(host, base string) Option {
+	return func(c *config) {
+		if c.baseURLs == nil {
+			c.baseURLs = make(map[string]string)
+		}
+		c.baseURLs[strings.ToLower(host)] = strings.TrimSuffix(base, "/")
+	}
+}
+
+// insecureLocation returns the url to fetch for u, and whether one of the
+// insecure options applies to it.
+func (r *Resolver) insecureLocation(u *url.URL) (string, bool) {
+	if u.Scheme != "https" {
+		return "", false
+	}
+	if base, ok := r.baseURLs[strings.ToLower(u.Host)]; ok {
+		return base + u.EscapedPath(), true
+	}
+	host := strings.ToLower(u.Hostname())
+	for _, pattern := range r.insecureHosts {
+		pattern = strings.ToLower(pattern)
+		if host == pattern || strings.HasPrefix(pattern, "*.") && strings.HasSuffix(host, pattern[1:]) {
+			insecure := *u
+			insecure.Scheme = "http"
+			return insecure.String(), true
+		}
+	}
+	return "", false
+}
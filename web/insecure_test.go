@@ -0,0 +1,130 @@
+package web
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+func TestInsecureHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/user/alice/did.json" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(testDocument("did:web:"+strings.Replace(r.Host, ":", "%3A", 1)+":user:alice", "1234"))
+	}))
+	defer server.Close()
+	id := "did:web:" + strings.Replace(strings.TrimPrefix(server.URL, "http://"), ":", "%3A", 1) + ":user:alice"
+	parsed, err := resolver.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := New(WithClient(server.Client()))
+	if _, err := r.Resolve(id, parsed, r); err == nil {
+		t.Error("expected Resolve to return error")
+	}
+	r = New(WithClient(server.Client()), WithInsecureHTTP("127.0.0.1"))
+	doc, err := r.Resolve(id, parsed, r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.ID != id {
+		t.Errorf("unexpected document: %s", doc.ID)
+	}
+}
+
+func TestInsecureBaseURL(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/dev/.well-known/did.json" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(testDocument("did:web:example.com", "1234"))
+	}))
+	defer server.Close()
+	id := "did:web:example.com"
+	parsed, err := resolver.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New(WithClient(server.Client()), WithInsecureBaseURL("Example.com", server.URL+"/dev/"))
+	if _, err := r.Resolve(id, parsed, r); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestInsecureLocation(t *testing.T) {
+	r := New(WithInsecureHTTP("localhost", "*.test"), WithInsecureBaseURL("example.com:3000", "http://127.0.0.1:8080"))
+	tests := map[string]string{
+		"https://localhost:8443/.well-known/did.json":    "http://localhost:8443/.well-known/did.json",
+		"https://LocalHost/user/did.json":                "http://LocalHost/user/did.json",
+		"https://id.example.test/.well-known/did.json":   "http://id.example.test/.well-known/did.json",
+		"https://example.com:3000/user/alice/did.json":   "http://127.0.0.1:8080/user/alice/did.json",
+		"https://example.com/.well-known/did.json":       "",
+		"https://test/.well-known/did.json":              "",
+		"https://localhost.example/.well-known/did.json": "",
+	}
+	for location, expected := range tests {
+		u, err := url.Parse(location)
+		if err != nil {
+			t.Fatal(err)
+		}
+		observed, ok := r.insecureLocation(u)
+		if ok != (expected != "") || observed != expected {
+			t.Errorf("unexpected location for %s: %s", location, observed)
+		}
+	}
+}
+
+// TestLocalMirror tries a local mirror of did:web documents before the
+// network, and falls back to the network for dids that are not mirrored.
+func TestLocalMirror(t *testing.T) {
+	mirrored := "did:web:example.com:user:alice"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/user/alice/did.json" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(testDocument(mirrored, "mirror"))
+	}))
+	defer server.Close()
+
+	mirror := New(WithClient(server.Client()), WithInsecureBaseURL("example.com", server.URL))
+	network := New(WithClient(docClient{testDocument("did:web:example.com:user:bob", "network")}))
+	registry := resolver.New([]resolver.Resolver{mirror, network}, false)
+	registry.SetFallback(resolver.FallbackOnNotFound)
+
+	_, doc, _, err := registry.Resolve(mirrored, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Controller[0] != "mirror" {
+		t.Errorf("unexpected resolver: %s", doc.Controller[0])
+	}
+	_, doc, _, err = registry.Resolve("did:web:example.com:user:bob", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Controller[0] != "network" {
+		t.Errorf("unexpected resolver: %s", doc.Controller[0])
+	}
+
+	// Errors other than a missing document do not fall back.
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer broken.Close()
+	mirror = New(WithClient(broken.Client()), WithInsecureBaseURL("example.com", broken.URL))
+	registry = resolver.New([]resolver.Resolver{mirror, network}, false)
+	registry.SetFallback(resolver.FallbackOnNotFound)
+	if _, _, _, err := registry.Resolve("did:web:example.com:user:bob", nil); err == nil {
+		t.Error("expected Resolve to return error")
+	}
+}
@@ -0,0 +1,243 @@
+// Package web provides tools for resolving dids formed by taking an http(s)
+// domain and producing a well-known URI to access the did document.
+// See https://tools.ietf.org/html/rfc5785 for details.
+// Copyright 2021 Textile
+// Copyright 2021 Decentralized Identity Foundation
+package web
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// DefaultMaxBodySize is the default limit on the size of fetched documents.
+const DefaultMaxBodySize = 1 << 20
+
+// Option configures a Resolver.
+type Option func(*config)
+
+// config holds the settings of a Resolver.
+type config struct {
+	client      Getter
+	timeout     time.Duration
+	userAgent   string
+	proxy       func(*http.Request) (*url.URL, error)
+	tlsConfig   *tls.Config
+	maxBodySize int64
+
+	ipPolicy       IPPolicy
+	allowedDomains []string
+	deniedDomains  []string
+	maxRedirects   int
+	decodeTimeout  time.Duration
+
+	cacheSize int
+	strict    bool
+
+	insecureHosts []string
+	baseURLs      map[string]string
+}
+
+// WithClient sets the client used to fetch documents, such as an
+// *http.Client. Without it, a Resolver uses the package Client, unless
+// WithTimeout, WithProxy or WithTLSConfig are used. Options that require an
+// *http.Client are an error with any other client.
+func WithClient(client Getter) Option {
+	return func(c *config) {
+		c.client = client
+	}
+}
+
+// WithTimeout sets the time limit of a request, including reading the body.
+// It requires an *http.Client.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.timeout = timeout
+	}
+}
+
+// WithUserAgent sets the User-Agent header of requests. It only applies to
+// clients that can Do an *http.Request, such as an *http.Client.
+func WithUserAgent(userAgent string) Option {
+	return func(c *config) {
+		c.userAgent = userAgent
+	}
+}
+
+// WithProxy sets the proxy of requests, e.g. http.ProxyURL(u) or
+// http.ProxyFromEnvironment. It requires an *http.Client, whose Transport is
+// nil or an *http.Transport.
+func WithProxy(proxy func(*http.Request) (*url.URL, error)) Option {
+	return func(c *config) {
+		c.proxy = proxy
+	}
+}
+
+// WithTLSConfig sets the TLS configuration of requests. It requires an
+// *http.Client, whose Transport is nil or an *http.Transport.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(c *config) {
+		c.tlsConfig = tlsConfig
+	}
+}
+
+// WithMaxBodySize limits the size of fetched documents, which is
+// DefaultMaxBodySize by default. Zero or less removes the limit.
+func WithMaxBodySize(size int64) Option {
+	return func(c *config) {
+		c.maxBodySize = size
+	}
+}
+
+// doer is a client that can Do requests with headers.
+type doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// httpClient returns the client of the configuration. An *http.Client is
+// copied, so that the timeout, proxy, TLS configuration and network policy are
+// not shared. The proxy, TLS configuration and network policy are applied to a
+// copy of the client's *http.Transport. Other clients and transports can't be
+// configured, so the options that require them are an error rather than
+// ignored.
+func (c *config) httpClient() (Getter, error) {
+	client := c.client
+	if client == nil {
+		if c.timeout == 0 && c.proxy == nil && c.tlsConfig == nil && c.ipPolicy == nil &&
+			c.maxRedirects < 0 && len(c.allowedDomains) == 0 && len(c.deniedDomains) == 0 {
+			return nil, nil
+		}
+		client = &http.Client{}
+	}
+	hc, ok := client.(*http.Client)
+	if !ok {
+		if c.timeout != 0 || c.proxy != nil || c.tlsConfig != nil || c.ipPolicy != nil || c.maxRedirects >= 0 {
+			return nil, fmt.Errorf("timeout, proxy, tls config, ip policy and max redirects require an *http.Client: '%T'", client)
+		}
+		return client, nil
+	}
+	copied := *hc
+	if c.timeout != 0 {
+		copied.Timeout = c.timeout
+	}
+	if c.maxRedirects >= 0 || len(c.allowedDomains) > 0 || len(c.deniedDomains) > 0 {
+		copied.CheckRedirect = c.checkRedirect(hc.CheckRedirect)
+	}
+	if c.proxy != nil || c.tlsConfig != nil || c.ipPolicy != nil {
+		var transport *http.Transport
+		switch t := copied.Transport.(type) {
+		case nil:
+			transport = http.DefaultTransport.(*http.Transport)
+		case *http.Transport:
+			transport = t
+		default:
+			return nil, fmt.Errorf("proxy, tls config and ip policy require an *http.Transport: '%T'", t)
+		}
+		transport = transport.Clone()
+		if c.proxy != nil {
+			transport.Proxy = c.proxy
+		}
+		if c.tlsConfig != nil {
+			transport.TLSClientConfig = c.tlsConfig
+		}
+		if c.ipPolicy != nil {
+			transport.DialContext = c.dialContext()
+			transport.DialTLSContext = nil
+		}
+		copied.Transport = transport
+	}
+	return &copied, nil
+}
+
+// Get fetches url with the client of the Resolver, if its domain is allowed,
+// and limits the size of the response body and the time to read it.
+func (r *Resolver) Get(rawURL string) (*http.Response, error) {
+	return r.get(rawURL, nil)
+}
+
+// get is Get with additional request headers, which are only sent by clients
+// that can Do an *http.Request.
+func (r *Resolver) get(rawURL string, header http.Header) (*http.Response, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkDomain(u, r.allowedDomains, r.deniedDomains); err != nil {
+		return nil, err
+	}
+	if location, ok := r.insecureLocation(u); ok {
+		rawURL = location
+	}
+	client := r.client
+	if client == nil {
+		client = Client
+	}
+	var resp *http.Response
+	cancel := context.CancelFunc(nil)
+	if d, ok := client.(doer); ok && (r.userAgent != "" || r.decodeTimeout > 0 || len(header) > 0) {
+		var req *http.Request
+		if req, err = http.NewRequest(http.MethodGet, rawURL, nil); err != nil {
+			return nil, err
+		}
+		for key, values := range header {
+			req.Header[key] = values
+		}
+		if r.userAgent != "" {
+			req.Header.Set("User-Agent", r.userAgent)
+		}
+		if r.decodeTimeout > 0 {
+			var ctx context.Context
+			ctx, cancel = context.WithCancel(context.Background())
+			req = req.WithContext(ctx)
+		}
+		resp, err = d.Do(req)
+	} else {
+		resp, err = client.Get(rawURL)
+	}
+	if err != nil {
+		if cancel != nil {
+			cancel()
+		}
+		return nil, err
+	}
+	if cancel != nil {
+		if resp.Body == nil {
+			cancel()
+		} else {
+			resp.Body = newTimedBody(resp.Body, r.decodeTimeout, cancel)
+		}
+	}
+	if resp.Body != nil && r.maxBodySize > 0 {
+		resp.Body = &limitedBody{resp.Body, r.maxBodySize, r.maxBodySize}
+	}
+	return resp, nil
+}
+
+// limitedBody is a response body that fails once it exceeds its limit.
+type limitedBody struct {
+	io.ReadCloser
+	limit     int64
+	remaining int64
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	if int64(len(p)) > b.remaining+1 {
+		p = p[:b.remaining+1]
+	}
+	n, err := b.ReadCloser.Read(p)
+	if int64(n) > b.remaining {
+		n = int(b.remaining)
+		b.remaining = 0
+		return n, fmt.Errorf("response body exceeds %d bytes", b.limit)
+	}
+	b.remaining -= int64(n)
+	return n, err
+}
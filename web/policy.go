@@ -0,0 +1,229 @@
+// Package web provides tools for resolving dids formed by taking an http(s)
+// domain and producing a well-known URI to access the did document.
+// See https://tools.ietf.org/html/rfc5785 for details.
+// Copyright 2021 Textile
+// Copyright 2021 Decentralized Identity Foundation
+package web
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// IPPolicy decides whether a Resolver may connect to an ip address, by
+// returning an error for addresses that are not allowed.
+type IPPolicy func(ip net.IP) error
+
+// nonPublic are the ranges of addresses that are not publicly routable.
+var nonPublic = parseCIDRs(
+	"0.0.0.0/8",       // "this" network
+	"10.0.0.0/8",      // private
+	"100.64.0.0/10",   // shared address space
+	"127.0.0.0/8",     // loopback
+	"169.254.0.0/16",  // link-local, including cloud metadata services
+	"172.16.0.0/12",   // private
+	"192.0.0.0/24",    // protocol assignments
+	"192.0.2.0/24",    // documentation
+	"192.168.0.0/16",  // private
+	"198.18.0.0/15",   // benchmarking
+	"198.51.100.0/24", // documentation
+	"203.0.113.0/24",  // documentation
+	"224.0.0.0/4",     // multicast
+	"240.0.0.0/4",     // reserved, including broadcast
+	"::/128",          // unspecified
+	"::1/128",         // loopback
+	"64:ff9b::/96",    // IPv4/IPv6 translation
+	"64:ff9b:1::/48",  // local-use IPv4/IPv6 translation
+	"100::/64",        // discard
+	"2001::/32",       // Teredo, which can embed any IPv4 address
+	"2001:db8::/32",   // documentation
+	"2002::/16",       // 6to4, which can embed any IPv4 address
+	"fc00::/7",        // unique local
+	"fe80::/10",       // link-local
+	"ff00::/8",        // multicast
+)
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	var nets []*net.IPNet
+	for _, cidr := range cidrs {
+		_, n, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets = append(nets, n)
+	}
+	return nets
+}
+
+// PublicOnly is an IPPolicy that only allows publicly routable addresses. It
+// rejects loopback, private, link-local, multicast and other special purpose
+// addresses, including IPv4 addresses mapped to IPv6.
+func PublicOnly(ip net.IP) error {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	for _, n := range nonPublic {
+		if n.Contains(ip) {
+			return fmt.Errorf("address not allowed: '%s'", ip)
+		}
+	}
+	return nil
+}
+
+// WithIPPolicy checks the address of every connection, after DNS resolution,
+// against policy, e.g. PublicOnly. As each connection is checked, a host that
+// resolves to a different address between lookups is caught too. It requires
+// an *http.Client, whose Transport is nil or an *http.Transport, and a
+// Resolver with any other client refuses to fetch, rather than fetch without
+// the policy. With a proxy, the address of the proxy is checked, and the proxy
+// is trusted to resolve hosts.
+func WithIPPolicy(policy IPPolicy) Option {
+	return func(c *config) {
+		c.ipPolicy = policy
+	}
+}
+
+// WithAllowedDomains only allows fetching from the domains, and their
+// subdomains. Redirects are checked too, when the client is an *http.Client.
+func WithAllowedDomains(domains ...string) Option {
+	return func(c *config) {
+		c.allowedDomains = append(c.allowedDomains, domains...)
+	}
+}
+
+// WithDeniedDomains rejects fetching from the domains, and their subdomains.
+// Redirects are checked too, when the client is an *http.Client.
+func WithDeniedDomains(domains ...string) Option {
+	return func(c *config) {
+		c.deniedDomains = append(c.deniedDomains, domains...)
+	}
+}
+
+// WithMaxRedirects limits the number of redirects followed by a request, and
+// zero disables redirects. It requires an *http.Client.
+func WithMaxRedirects(max int) Option {
+	return func(c *config) {
+		c.maxRedirects = max
+	}
+}
+
+// WithDecodeTimeout limits the time spent reading and decoding a response
+// body, after the response headers are received. It only applies to clients
+// that can Do an *http.Request, such as an *http.Client.
+func WithDecodeTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.decodeTimeout = timeout
+	}
+}
+
+// checkDomain returns an error if the host of u is not allowed by the
+// domain lists.
+func checkDomain(u *url.URL, allowed, denied []string) error {
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	for _, domain := range denied {
+		if matchDomain(host, domain) {
+			return fmt.Errorf("domain not allowed: '%s'", host)
+		}
+	}
+	if len(allowed) == 0 {
+		return nil
+	}
+	for _, domain := range allowed {
+		if matchDomain(host, domain) {
+			return nil
+		}
+	}
+	return fmt.Errorf("domain not allowed: '%s'", host)
+}
+
+// matchDomain reports whether host is domain or one of its subdomains.
+func matchDomain(host, domain string) bool {
+	domain = strings.TrimSuffix(strings.ToLower(domain), ".")
+	return host == domain || strings.HasSuffix(host, "."+domain)
+}
+
+// checkRedirect returns the redirect policy of the configuration, which
+// applies the domain lists and redirect limit before next.
+func (c *config) checkRedirect(next func(*http.Request, []*http.Request) error) func(*http.Request, []*http.Request) error {
+	max := c.maxRedirects
+	if max < 0 {
+		// The default limit of http.Client.
+		max = 10
+	}
+	allowed, denied := c.allowedDomains, c.deniedDomains
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) > max {
+			return fmt.Errorf("stopped after %d redirects", max)
+		}
+		if err := checkDomain(req.URL, allowed, denied); err != nil {
+			return err
+		}
+		if next != nil {
+			return next(req, via)
+		}
+		return nil
+	}
+}
+
+// dialContext returns a dial function that checks the address of each
+// connection against the ip policy of the configuration.
+func (c *config) dialContext() func(ctx context.Context, network, address string) (net.Conn, error) {
+	policy := c.ipPolicy
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil {
+				return fmt.Errorf("invalid address: '%s'", address)
+			}
+			return policy(ip)
+		},
+	}
+	return dialer.DialContext
+}
+
+// timedBody is a response body that cancels its request once its timeout
+// expires.
+type timedBody struct {
+	io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	cancel  context.CancelFunc
+	expired int32
+}
+
+func newTimedBody(body io.ReadCloser, timeout time.Duration, cancel context.CancelFunc) *timedBody {
+	b := &timedBody{ReadCloser: body, timeout: timeout, cancel: cancel}
+	b.timer = time.AfterFunc(timeout, func() {
+		atomic.StoreInt32(&b.expired, 1)
+		cancel()
+	})
+	return b
+}
+
+func (b *timedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && atomic.LoadInt32(&b.expired) == 1 {
+		err = fmt.Errorf("response body exceeds decode timeout of %s", b.timeout)
+	}
+	return n, err
+}
+
+func (b *timedBody) Close() error {
+	b.timer.Stop()
+	b.cancel()
+	return b.ReadCloser.Close()
+}
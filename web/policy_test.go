@@ -0,0 +1,177 @@
+package web
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ockam-network/did"
+)
+
+func TestPublicOnly(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.216.34":      true,
+		"2606:2800:220:1::1": true,
+		"127.0.0.1":          false,
+		"10.1.2.3":           false,
+		"172.20.0.1":         false,
+		"192.168.1.1":        false,
+		"169.254.169.254":    false,
+		"100.64.0.1":         false,
+		"0.0.0.0":            false,
+		"255.255.255.255":    false,
+		"::1":                false,
+		"::":                 false,
+		"fd00::1":            false,
+		"fe80::1":            false,
+		"::ffff:127.0.0.1":   false,
+		"::ffff:10.0.0.1":    false,
+		// 6to4 of 127.0.0.1 and 10.0.0.1
+		"2002:7f00:1::1": false,
+		"2002:a00:1::1":  false,
+		// Teredo, with an obfuscated client address
+		"2001:0:4136:e378:8000:63bf:3fff:fdd2": false,
+		// local-use IPv4/IPv6 translation of 10.0.0.1
+		"64:ff9b:1::a00:1": false,
+	}
+	for addr, allowed := range tests {
+		err := PublicOnly(net.ParseIP(addr))
+		if allowed && err != nil {
+			t.Errorf("expected %s to be allowed: %v", addr, err)
+		}
+		if !allowed && err == nil {
+			t.Errorf("expected %s not to be allowed", addr)
+		}
+	}
+}
+
+func TestIPPolicy(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	if _, err := New(WithClient(server.Client())).Get(server.URL); err != nil {
+		t.Fatal(err)
+	}
+	for _, location := range []string{server.URL, strings.Replace(server.URL, "127.0.0.1", "localhost", 1)} {
+		_, err := New(WithClient(server.Client()), WithIPPolicy(PublicOnly)).Get(location)
+		if err == nil || !strings.Contains(err.Error(), "address not allowed") {
+			t.Errorf("expected Get to return error for: %s: %v", location, err)
+		}
+	}
+}
+
+func TestIPPolicyRequiresHTTPClient(t *testing.T) {
+	id := "did:web:example.com"
+	parsed, err := did.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := WithClient(docClient{testDocument(id, "1234")})
+	opts := []Option{
+		WithIPPolicy(PublicOnly),
+		WithTimeout(time.Second),
+		WithProxy(http.ProxyFromEnvironment),
+		WithTLSConfig(&tls.Config{}),
+		WithMaxRedirects(0),
+	}
+	for _, opt := range opts {
+		r := New(client, opt)
+		if r.Err() == nil {
+			t.Error("expected Err to return error")
+		}
+		if _, err := r.Resolve(id, parsed, r); err == nil || err != r.Err() {
+			t.Errorf("expected Resolve to return configuration error: %v", err)
+		}
+	}
+	r := New(client, WithAllowedDomains("example.com"))
+	if _, err := r.Resolve(id, parsed, r); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDomainLists(t *testing.T) {
+	tests := []struct {
+		id      string
+		opts    []Option
+		allowed bool
+	}{
+		{"did:web:example.com", []Option{WithAllowedDomains("example.com")}, true},
+		{"did:web:id.example.com", []Option{WithAllowedDomains("example.com")}, true},
+		{"did:web:example.org", []Option{WithAllowedDomains("example.com")}, false},
+		{"did:web:badexample.com", []Option{WithAllowedDomains("example.com")}, false},
+		{"did:web:example.com", []Option{WithDeniedDomains("Example.com")}, false},
+		{"did:web:id.example.com", []Option{WithDeniedDomains("example.com")}, false},
+		{"did:web:example.org", []Option{WithDeniedDomains("example.com")}, true},
+		{"did:web:id.example.com", []Option{WithAllowedDomains("example.com"), WithDeniedDomains("id.example.com")}, false},
+	}
+	for _, test := range tests {
+		parsed, err := did.Parse(test.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		opts := append(test.opts, WithClient(docClient{testDocument(test.id, "1234")}))
+		r := New(opts...)
+		_, err = r.Resolve(test.id, parsed, r)
+		if test.allowed && err != nil {
+			t.Errorf("expected %s to be allowed: %v", test.id, err)
+		}
+		if !test.allowed && (err == nil || !strings.Contains(err.Error(), "domain not allowed")) {
+			t.Errorf("expected %s not to be allowed", test.id)
+		}
+	}
+}
+
+func TestMaxRedirects(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusFound)
+		case "/b":
+			http.Redirect(w, r, "/c", http.StatusFound)
+		case "/denied":
+			http.Redirect(w, r, "https://example.com/c", http.StatusFound)
+		}
+	}))
+	defer server.Close()
+
+	if _, err := New(WithClient(server.Client()), WithMaxRedirects(1)).Get(server.URL + "/a"); err == nil {
+		t.Error("expected Get to return error")
+	}
+	if _, err := New(WithClient(server.Client()), WithMaxRedirects(2)).Get(server.URL + "/a"); err != nil {
+		t.Error(err)
+	}
+	if _, err := New(WithClient(server.Client()), WithMaxRedirects(0)).Get(server.URL + "/b"); err == nil {
+		t.Error("expected Get to return error")
+	}
+	_, err := New(WithClient(server.Client()), WithDeniedDomains("example.com")).Get(server.URL + "/denied")
+	if err == nil || !strings.Contains(err.Error(), "domain not allowed") {
+		t.Error("expected Get to return error")
+	}
+}
+
+func TestDecodeTimeout(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("{"))
+		w.(http.Flusher).Flush()
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer server.Close()
+
+	resp, err := New(WithClient(server.Client()), WithDecodeTimeout(50*time.Millisecond)).Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	_, err = ioutil.ReadAll(resp.Body)
+	if err == nil || !strings.Contains(err.Error(), "decode timeout") {
+		t.Errorf("expected ReadAll to return error: %v", err)
+	}
+}
@@ -0,0 +1,115 @@
+// Package web provides tools for resolving dids formed by taking an http(s)
+// domain and producing a well-known URI to access the did document.
+// See https://tools.ietf.org/html/rfc5785 for details.
+// Copyright 2021 Textile
+// Copyright 2021 Decentralized Identity Foundation
+package web
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+// DocumentContentType is the media type of served did documents.
+const DocumentContentType = "application/did+json"
+
+// published is a document served by a Publisher.
+type published struct {
+	data     []byte
+	etag     string
+	modified time.Time
+}
+
+// Publisher is an http.Handler that serves did:web documents at the locations
+// they are resolved from: /.well-known/did.json for a did of a domain, and
+// /{path}/did.json for a did with a path. The document served for a request is
+// the one whose did is derived from its host and path, so a document is only
+// served from its own location.
+type Publisher struct {
+	lock   sync.RWMutex
+	docs   map[string]*published
+	maxAge time.Duration
+}
+
+// NewPublisher creates and returns a new Publisher, whose documents may be
+// cached by clients for maxAge.
+func NewPublisher(maxAge time.Duration) *Publisher {
+	return &Publisher{docs: make(map[string]*published), maxAge: maxAge}
+}
+
+// Publish serves doc, or replaces the document served for its did. The did
+// must be a did:web in the form derived from its url, with a lower case
+// domain and percent-encoded port.
+func (p *Publisher) Publish(doc *resolver.Document) error {
+	parsed, err := resolver.Parse(doc.ID)
+	if err != nil {
+		return err
+	}
+	if parsed.Method != "web" || parsed.Path != "" || parsed.Query != "" || parsed.Fragment != "" {
+		return fmt.Errorf("not a did:web: '%s'", doc.ID)
+	}
+	location, err := URL(parsed.IDStrings, "did.json")
+	if err != nil {
+		return err
+	}
+	if id, err := DIDFromURL(location); err != nil || id != doc.ID {
+		return fmt.Errorf("did does not round trip through its url '%s': '%s'", location, doc.ID)
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	sum := sha256.Sum256(data)
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	p.docs[doc.ID] = &published{
+		data:     data,
+		etag:     strconv.Quote(hex.EncodeToString(sum[:16])),
+		modified: time.Now().UTC().Truncate(time.Second),
+	}
+	return nil
+}
+
+// Unpublish stops serving the document of did.
+func (p *Publisher) Unpublish(did string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	delete(p.docs, did)
+}
+
+// ServeHTTP serves the document whose did is derived from the host and path of
+// the request, with conditional request support.
+func (p *Publisher) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	id, err := DIDFromURL("https://" + r.Host + r.URL.EscapedPath())
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+	p.lock.RLock()
+	doc, ok := p.docs[id]
+	p.lock.RUnlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", DocumentContentType)
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(p.maxAge.Seconds())))
+	w.Header().Set("ETag", doc.etag)
+	http.ServeContent(w, r, "did.json", doc.modified, bytes.NewReader(doc.data))
+}
+
+var _ http.Handler = (*Publisher)(nil)
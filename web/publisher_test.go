@@ -0,0 +1,117 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+func TestPublisher(t *testing.T) {
+	publisher := NewPublisher(time.Minute)
+	for _, id := range []string{"did:web:example.com", "did:web:example.com:user:alice"} {
+		if err := publisher.Publish(testDocument(id, "1234")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	server := httptest.NewTLSServer(publisher)
+	defer server.Close()
+	r := New(WithClient(server.Client()), WithStrict(true))
+
+	for _, id := range []string{"did:web:example.com", "did:web:example.com:user:alice"} {
+		parsed, err := resolver.Parse(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc, metadata, err := r.ResolveWithMetadata(id, parsed, r, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc.ID != id || doc.Controller[0] != "1234" {
+			t.Errorf("unexpected document: %s", doc.ID)
+		}
+		if metadata.Updated == "" {
+			t.Error("expected updated metadata")
+		}
+	}
+
+	// Documents are only served from their own location.
+	id := "did:web:example.com:user:bob"
+	parsed, err := resolver.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Resolve(id, parsed, r); err == nil {
+		t.Error("expected Resolve to return error")
+	}
+	resp, err := server.Client().Get(server.URL + "/.well-known/did.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected document not to be served for another host: %s", resp.Status)
+	}
+
+	publisher.Unpublish("did:web:example.com")
+	resp, err = server.Client().Get("https://example.com/.well-known/did.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected unpublished document not to be served: %s", resp.Status)
+	}
+}
+
+func TestPublisherHeaders(t *testing.T) {
+	publisher := NewPublisher(time.Hour)
+	if err := publisher.Publish(testDocument("did:web:example.com", "1234")); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "https://example.com/.well-known/did.json", nil)
+	w := httptest.NewRecorder()
+	publisher.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("unexpected status: %d", w.Code)
+	}
+	header := w.Result().Header
+	if header.Get("Content-Type") != DocumentContentType || header.Get("Cache-Control") != "public, max-age=3600" {
+		t.Errorf("unexpected headers: %v", header)
+	}
+	if header.Get("ETag") == "" || header.Get("Last-Modified") == "" {
+		t.Errorf("expected validators: %v", header)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "https://example.com/.well-known/did.json", nil)
+	req.Header.Set("If-None-Match", header.Get("ETag"))
+	w = httptest.NewRecorder()
+	publisher.ServeHTTP(w, req)
+	if w.Code != http.StatusNotModified {
+		t.Errorf("expected not modified: %d", w.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "https://example.com/.well-known/did.json", nil)
+	w = httptest.NewRecorder()
+	publisher.ServeHTTP(w, req)
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("expected method not allowed: %d", w.Code)
+	}
+}
+
+func TestPublishInvalid(t *testing.T) {
+	publisher := NewPublisher(time.Minute)
+	for _, id := range []string{
+		"did:key:z6MkhaXgBZDvotDkL5257faiztiGiC2QtKLGpbnnEGta2doK",
+		"did:web:Example.com",
+		"did:web:example.com%3A443:%75ser",
+		"did:web:example.com#key-1",
+	} {
+		if err := publisher.Publish(testDocument(id, "1234")); err == nil {
+			t.Errorf("expected Publish to return error for: %s", id)
+		}
+	}
+}
@@ -0,0 +1,115 @@
+// Package web provides tools for resolving dids formed by taking an http(s)
+// domain and producing a well-known URI to access the did document.
+// See https://tools.ietf.org/html/rfc5785 for details.
+// Copyright 2021 Textile
+// Copyright 2021 Decentralized Identity Foundation
+package web
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/net/idna"
+)
+
+// URL returns the https url of a file, at the location described by the id
+// strings of a did:web method-specific id. The first is the host, with an
+// optional percent-encoded port, and the rest are percent-encoded path
+// segments. Without path segments, the file is in /.well-known.
+// See https://w3c-ccg.github.io/did-method-web/#read-resolve
+func URL(ids []string, file string) (string, error) {
+	if len(ids) == 0 {
+		return "", fmt.Errorf("missing domain")
+	}
+	host, err := Host(ids[0])
+	if err != nil {
+		return "", err
+	}
+	if len(ids) == 1 {
+		return "https://" + host + "/.well-known/" + file, nil
+	}
+	var path []string
+	for _, segment := range ids[1:] {
+		decoded, err := url.PathUnescape(segment)
+		if err != nil || decoded == "" || decoded == "." || decoded == ".." {
+			return "", fmt.Errorf("invalid path segment: '%s'", segment)
+		}
+		path = append(path, url.PathEscape(decoded))
+	}
+	return "https://" + host + "/" + strings.Join(path, "/") + "/" + file, nil
+}
+
+// Host decodes the percent-encoded host and optional port of a did:web, and
+// normalizes the host to lower case IDNA ASCII form.
+func Host(segment string) (string, error) {
+	decoded, err := url.PathUnescape(segment)
+	if err != nil || !utf8.ValidString(decoded) {
+		return "", fmt.Errorf("invalid domain: '%s'", segment)
+	}
+	host, port := decoded, ""
+	if i := strings.LastIndex(decoded, ":"); i >= 0 {
+		host, port = decoded[:i], decoded[i+1:]
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 || strings.HasPrefix(port, "0") {
+			return "", fmt.Errorf("invalid port: '%s'", port)
+		}
+	}
+	ascii, err := toASCII(host)
+	if err != nil {
+		return "", fmt.Errorf("invalid domain: '%s'", decoded)
+	}
+	if port != "" {
+		return ascii + ":" + port, nil
+	}
+	return ascii, nil
+}
+
+// DIDFromURL returns the did:web for the url of a did.json document. This is
+// the reverse of the transformation applied when resolving.
+func DIDFromURL(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "https" || u.Host == "" || u.User != nil || u.RawQuery != "" || u.Fragment != "" {
+		return "", fmt.Errorf("invalid did:web url: '%s'", rawURL)
+	}
+	host, err := toASCII(u.Hostname())
+	if err != nil {
+		return "", fmt.Errorf("invalid domain: '%s'", u.Hostname())
+	}
+	id := "did:web:" + host
+	if u.Port() != "" {
+		id += "%3A" + u.Port()
+	}
+	path := strings.TrimSuffix(u.EscapedPath(), "/did.json")
+	if path == u.EscapedPath() {
+		return "", fmt.Errorf("invalid did:web url: '%s'", rawURL)
+	}
+	if path == "/.well-known" || path == "" {
+		return id, nil
+	}
+	for _, segment := range strings.Split(strings.TrimPrefix(path, "/"), "/") {
+		decoded, err := url.PathUnescape(segment)
+		if err != nil || decoded == "" {
+			return "", fmt.Errorf("invalid did:web url: '%s'", rawURL)
+		}
+		id += ":" + strings.ReplaceAll(url.PathEscape(decoded), ":", "%3A")
+	}
+	return id, nil
+}
+
+// idnaProfile maps and validates hosts as for lookup, as per UTS #46, and also
+// checks the DNS length limits of the result.
+// See https://www.unicode.org/reports/tr46/
+var idnaProfile = idna.New(idna.MapForLookup(), idna.BidiRule(), idna.VerifyDNSLength(true))
+
+// toASCII converts a domain name to its lower case IDNA ASCII form.
+func toASCII(domain string) (string, error) {
+	if domain == "" {
+		return "", fmt.Errorf("empty domain")
+	}
+	return idnaProfile.ToASCII(domain)
+}
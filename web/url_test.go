@@ -0,0 +1,120 @@
+package web
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+func TestURL(t *testing.T) {
+	tests := map[string]string{
+		"did:web:example.com":                              "https://example.com/.well-known/did.json",
+		"did:web:Example.COM:user:alice":                   "https://example.com/user/alice/did.json",
+		"did:web:localhost%3A8443":                         "https://localhost:8443/.well-known/did.json",
+		"did:web:example.com%3A3000:user:alice":            "https://example.com:3000/user/alice/did.json",
+		"did:web:example.com:user%20name:a%2Fb":            "https://example.com/user%20name/a%2Fb/did.json",
+		"did:web:b%C3%BCcher.example":                      "https://xn--bcher-kva.example/.well-known/did.json",
+		"did:web:xn--bcher-kva.example%3A443:m%C3%BCnchen": "https://xn--bcher-kva.example:443/m%C3%BCnchen/did.json",
+	}
+	for id, expected := range tests {
+		parsed, err := resolver.Parse(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		observed, err := URL(parsed.IDStrings, "did.json")
+		if err != nil {
+			t.Errorf("unexpected error for %s: %v", id, err)
+			continue
+		}
+		if observed != expected {
+			t.Errorf("expected %s, got %s", expected, observed)
+		}
+	}
+}
+
+func TestURLInvalid(t *testing.T) {
+	for _, id := range []string{
+		"did:web:example.com%3A",
+		"did:web:example.com%3A99999",
+		"did:web:example.com%2Fpath",
+		"did:web:user%40example.com",
+		"did:web:example.com:%2E%2E",
+	} {
+		parsed, err := resolver.Parse(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := URL(parsed.IDStrings, "did.json"); err == nil {
+			t.Errorf("expected URL to return error for: %s", id)
+		}
+	}
+}
+
+func TestToASCII(t *testing.T) {
+	tests := map[string]string{
+		"Example.COM":    "example.com",
+		"bücher.example": "xn--bcher-kva.example",
+		"BÜCHER.example": "xn--bcher-kva.example",
+		"münchen.de":     "xn--mnchen-3ya.de",
+		"例え.jp":          "xn--r8jz45g.jp",
+		"ｅｘａｍｐｌｅ.com":    "example.com",
+	}
+	for domain, expected := range tests {
+		observed, err := toASCII(domain)
+		if err != nil {
+			t.Errorf("unexpected error for %s: %v", domain, err)
+			continue
+		}
+		if observed != expected {
+			t.Errorf("expected %s, got %s", expected, observed)
+		}
+	}
+	for _, domain := range []string{
+		"",
+		"exa_mple.com",
+		"a..example",
+		"-example.com",
+		strings.Repeat("a", 64) + ".com",
+	} {
+		if _, err := toASCII(domain); err == nil {
+			t.Errorf("expected toASCII to return error for: %s", domain)
+		}
+	}
+}
+
+func TestDIDFromURL(t *testing.T) {
+	tests := map[string]string{
+		"https://example.com/.well-known/did.json":     "did:web:example.com",
+		"https://localhost:8443/.well-known/did.json":  "did:web:localhost%3A8443",
+		"https://example.com:3000/user/alice/did.json": "did:web:example.com%3A3000:user:alice",
+		"https://bücher.example/a%3Ab/did.json":        "did:web:xn--bcher-kva.example:a%3Ab",
+	}
+	for u, expected := range tests {
+		observed, err := DIDFromURL(u)
+		if err != nil {
+			t.Errorf("unexpected error for %s: %v", u, err)
+			continue
+		}
+		if observed != expected {
+			t.Errorf("expected %s, got %s", expected, observed)
+		}
+		// The transformation round trips.
+		parsed, err := resolver.Parse(observed)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := URL(parsed.IDStrings, "did.json"); err != nil {
+			t.Error(err)
+		}
+	}
+	for _, u := range []string{
+		"http://example.com/.well-known/did.json",
+		"https://example.com/did.jsonl",
+		"https://example.com/.well-known/did.json?versionId=1",
+	} {
+		if _, err := DIDFromURL(u); err == nil {
+			t.Errorf("expected DIDFromURL to return error for: %s", u)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+// Package web provides tools for resolving dids formed by taking an http(s)
+// domain and producing a well-known URI to access the did document.
+// See https://tools.ietf.org/html/rfc5785 for details.
+// Copyright 2021 Textile
+// Copyright 2021 Decentralized Identity Foundation
+package web
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+// ContentTypes are the media types accepted for did documents in strict mode.
+var ContentTypes = []string{
+	"application/did+json",
+	"application/did+ld+json",
+	"application/json",
+}
+
+// WithStrict enables strict validation of fetched documents. The response must
+// have one of the ContentTypes, the body must be a single json object without
+// duplicate keys, and the id and controller of every verification method must
+// be under the did, or one of the controllers of the document. All problems
+// are reported together as ValidationErrors.
+func WithStrict(strict bool) Option {
+	return func(c *config) {
+		c.strict = strict
+	}
+}
+
+// ValidationError is a problem found by strict validation of a document.
+type ValidationError struct {
+	// Field locates the problem, such as "Content-Type" or
+	// "verificationMethod[0].controller".
+	Field string
+	// Message describes the problem.
+	Message string
+}
+
+func (e ValidationError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+// ValidationErrors lists the problems found by strict validation of a
+// document.
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return "invalid did document: " + strings.Join(messages, "; ")
+}
+
+// add appends a problem with the field.
+func (e *ValidationErrors) add(field, format string, args ...interface{}) {
+	*e = append(*e, ValidationError{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// validateResponse checks the content type and json encoding of a fetched
+// document.
+func validateResponse(contentType string, body []byte) ValidationErrors {
+	var errs ValidationErrors
+	if contentType == "" {
+		errs.add("Content-Type", "missing media type")
+	} else if mediaType, _, err := mime.ParseMediaType(contentType); err != nil {
+		errs.add("Content-Type", "invalid media type: '%s'", contentType)
+	} else if !contains(ContentTypes, mediaType) {
+		errs.add("Content-Type", "unsupported media type: '%s'", mediaType)
+	}
+	if err := checkJSON(body); err != nil {
+		errs.add("body", "%s", err)
+	}
+	return errs
+}
+
+// validateDocument checks that the verification methods of doc are under did,
+// or one of the controllers of doc.
+func validateDocument(did string, doc *resolver.Document) ValidationErrors {
+	var errs ValidationErrors
+	controllers := append([]string{did}, doc.Controller...)
+	check := func(field string, methods []resolver.VerificationMethod) {
+		for i, vm := range methods {
+			if !underController(vm.ID, controllers) {
+				errs.add(fmt.Sprintf("%s[%d].id", field, i), "not under a controller: '%s'", vm.ID)
+			}
+			if vm.IsReference() {
+				continue
+			}
+			if !contains(controllers, vm.Controller) {
+				errs.add(fmt.Sprintf("%s[%d].controller", field, i), "not a controller: '%s'", vm.Controller)
+			}
+		}
+	}
+	check("verificationMethod", doc.VerificationMethod)
+	check("authentication", doc.Authentication)
+	check("assertionMethod", doc.AssertionMethod)
+	check("capabilityInvocation", doc.CapabilityInvocation)
+	check("capabilityDelegation", doc.CapabilityDelegation)
+	check("keyAgreement", doc.KeyAgreement)
+	return errs
+}
+
+// underController reports whether id is a relative fragment, or a did url
+// with a fragment under one of the controllers.
+func underController(id string, controllers []string) bool {
+	if strings.HasPrefix(id, "#") && len(id) > 1 {
+		return true
+	}
+	for _, controller := range controllers {
+		if strings.HasPrefix(id, controller+"#") && len(id) > len(controller)+1 {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// checkJSON returns an error if data is not a single json object, or has
+// objects with duplicate keys.
+func checkJSON(data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if token != json.Delim('{') {
+		return fmt.Errorf("not a json object")
+	}
+	if err := checkObject(decoder, ""); err != nil {
+		return err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return fmt.Errorf("unexpected data after json value")
+	}
+	return nil
+}
+
+// checkObject reads the members of an object, after its opening delimiter,
+// and returns an error for duplicate keys.
+func checkObject(decoder *json.Decoder, path string) error {
+	keys := make(map[string]bool)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return err
+		}
+		key := token.(string)
+		if keys[key] {
+			return fmt.Errorf("duplicate key: '%s'", path+key)
+		}
+		keys[key] = true
+		if err := checkValue(decoder, path+key+"."); err != nil {
+			return err
+		}
+	}
+	_, err := decoder.Token()
+	return err
+}
+
+// checkValue reads a json value, checking objects for duplicate keys.
+func checkValue(decoder *json.Decoder, path string) error {
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	switch token {
+	case json.Delim('{'):
+		return checkObject(decoder, path)
+	case json.Delim('['):
+		for i := 0; decoder.More(); i++ {
+			if err := checkValue(decoder, fmt.Sprintf("%s%d.", path, i)); err != nil {
+				return err
+			}
+		}
+		_, err := decoder.Token()
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package web
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ockam-network/did"
+)
+
+// resolveStrict resolves did:web:example.com in strict mode, from a server
+// responding with the content type and body.
+func resolveStrict(t *testing.T, contentType, body string) error {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+	id := "did:web:example.com"
+	parsed, err := did.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New(WithClient(server.Client()), WithStrict(true))
+	_, err = r.Resolve(id, parsed, r)
+	return err
+}
+
+const strictDocument = `{
+	"@context": ["https://www.w3.org/ns/did/v1"],
+	"id": "did:web:example.com",
+	"controller": ["did:example:123"],
+	"verificationMethod": [
+		{"id": "did:web:example.com#owner", "type": "Multikey", "controller": "did:web:example.com", "publicKeyMultibase": "z6Mk"},
+		{"id": "did:example:123#key-1", "type": "Multikey", "controller": "did:example:123", "publicKeyMultibase": "z6Mk"}
+	],
+	"authentication": ["#owner", "did:example:123#key-1"]
+}`
+
+func TestStrict(t *testing.T) {
+	for _, contentType := range []string{"application/did+json", "application/did+ld+json", "application/json; charset=utf-8"} {
+		if err := resolveStrict(t, contentType, strictDocument); err != nil {
+			t.Errorf("unexpected error for %s: %v", contentType, err)
+		}
+	}
+}
+
+func TestStrictErrors(t *testing.T) {
+	tests := []struct {
+		contentType string
+		body        string
+		fields      []string
+	}{
+		{"", strictDocument, []string{"Content-Type"}},
+		{"text/html", strictDocument, []string{"Content-Type"}},
+		{"application/did+json", strictDocument + `{}`, []string{"body"}},
+		{"application/did+json", strictDocument + ` garbage`, []string{"body"}},
+		{"application/did+json", `[]`, []string{"body"}},
+		{"application/did+json", strings.Replace(strictDocument, `"@context"`, `"id": "did:web:example.com", "@context"`, 1), []string{"body"}},
+		{"text/plain", strings.Replace(strictDocument, `"controller": "did:web:example.com"`, `"controller": "did:web:example.com", "type": "Multikey"`, 1), []string{"Content-Type", "body"}},
+		{"application/did+json", strings.Replace(strictDocument, "did:web:example.com#owner", "did:web:example.org#owner", 1), []string{"verificationMethod[0].id"}},
+		{"application/did+json", strings.Replace(strictDocument, `"controller": "did:example:123"`, `"controller": "did:example:456"`, 1), []string{"verificationMethod[1].controller"}},
+		{"application/did+json", strings.Replace(strictDocument, `"#owner"`, `"did:example:456#key-1"`, 1), []string{"authentication[0].id"}},
+	}
+	for i, test := range tests {
+		err := resolveStrict(t, test.contentType, test.body)
+		var errs ValidationErrors
+		if !errors.As(err, &errs) {
+			t.Errorf("%d: expected Resolve to return validation errors: %v", i, err)
+			continue
+		}
+		if len(errs) != len(test.fields) {
+			t.Errorf("%d: unexpected errors: %v", i, errs)
+			continue
+		}
+		for j, field := range test.fields {
+			if errs[j].Field != field {
+				t.Errorf("%d: unexpected field: %s", i, errs[j].Field)
+			}
+		}
+	}
+}
+
+func TestNotStrict(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Write([]byte(strictDocument))
+	}))
+	defer server.Close()
+	id := "did:web:example.com"
+	parsed, err := did.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New(WithClient(server.Client()))
+	if _, err := r.Resolve(id, parsed, r); err != nil {
+		t.Error(err)
+	}
+}
@@ -8,8 +8,9 @@ package web
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
-	"strings"
+	"time"
 
 	"github.com/ockam-network/did"
 	"github.com/textileio/go-did-resolver/resolver"
@@ -23,7 +24,11 @@ type Getter interface {
 	Get(url string) (resp *http.Response, err error)
 }
 
-// Client is the default client used to Get *http.Responses.
+// Client is the default client used to Get *http.Responses, by resolvers
+// created without WithClient.
+//
+// Deprecated: Use New with WithClient, or the other options, to configure the
+// client of each Resolver.
 var Client Getter
 
 // The init function sets the Client var to instance of http.Client.
@@ -34,11 +39,47 @@ func init() {
 
 // Resolver takes an http(s) domain and resolves a well-known URI-based did
 // document.
-type Resolver struct{}
+type Resolver struct {
+	client         Getter
+	userAgent      string
+	maxBodySize    int64
+	allowedDomains []string
+	deniedDomains  []string
+	decodeTimeout  time.Duration
+	cache          *httpCache
+	strict         bool
+	insecureHosts  []string
+	baseURLs       map[string]string
+	err            error
+}
+
+// New creates and returns a new Resolver, configured by opts. If opts can't be
+// applied to the client, every request of the Resolver fails with the error
+// reported by Err.
+func New(opts ...Option) *Resolver {
+	c := &config{maxBodySize: DefaultMaxBodySize, maxRedirects: -1, cacheSize: DefaultCacheSize}
+	for _, opt := range opts {
+		opt(c)
+	}
+	client, err := c.httpClient()
+	return &Resolver{
+		client:         client,
+		userAgent:      c.userAgent,
+		maxBodySize:    c.maxBodySize,
+		allowedDomains: c.allowedDomains,
+		deniedDomains:  c.deniedDomains,
+		decodeTimeout:  c.decodeTimeout,
+		cache:          newHTTPCache(c.cacheSize),
+		strict:         c.strict,
+		insecureHosts:  c.insecureHosts,
+		baseURLs:       c.baseURLs,
+		err:            err,
+	}
+}
 
-// New creates and returns a new Resolver.
-func New() *Resolver {
-	return &Resolver{}
+// Err returns the error of an invalid configuration, or nil.
+func (r *Resolver) Err() error {
+	return r.err
 }
 
 // Method returns the method that this resolver is capable of resolving.
@@ -48,41 +89,89 @@ func (r *Resolver) Method() string {
 
 // Resolve is the primary resolution method for this resolver.
 // For a did did:web:example.com, the resolver will attempt to access the
-// document at https://example.com/.well-known/did.json
+// document at https://example.com/.well-known/did.json, and for a did
+// did:web:example.com%3A3000:user:alice, the document at
+// https://example.com:3000/user/alice/did.json
 func (r *Resolver) Resolve(did string, parsed *did.DID, res resolver.Resolver) (*resolver.Document, error) {
+	doc, _, err := r.ResolveWithMetadata(did, parsed, res, nil)
+	return doc, err
+}
+
+// ResolveWithMetadata resolves the did like Resolve, and reports the
+// Last-Modified time of the document as updated. Documents are cached as
+// allowed by their Cache-Control headers, and revalidated with conditional
+// requests when stale.
+func (r *Resolver) ResolveWithMetadata(did string, parsed *did.DID, res resolver.Resolver, options *resolver.ResolutionOptions) (*resolver.Document, resolver.DocumentMetadata, error) {
 	if parsed.Method != r.Method() {
-		return nil, fmt.Errorf("unknown did method: '%s'", parsed.Method)
+		return nil, resolver.DocumentMetadata{}, fmt.Errorf("unknown did method: '%s'", parsed.Method)
+	}
+	url, err := URL(parsed.IDStrings, "did.json")
+	if err != nil {
+		return nil, resolver.DocumentMetadata{}, err
+	}
+	entry, err := r.fetch(url)
+	if err != nil {
+		return nil, resolver.DocumentMetadata{}, err
 	}
-	path := parsed.ID + DocPath
-	id := strings.Split(parsed.ID, ":")
-	if len(id) > 1 {
-		path = strings.Join(id, "/") + "/did.json"
+
+	var state resolver.Document
+	err = json.Unmarshal(entry.body, &state)
+	if r.strict {
+		errs := validateResponse(entry.contentType, entry.body)
+		if err == nil {
+			errs = append(errs, validateDocument(did, &state)...)
+		}
+		if len(errs) > 0 {
+			return nil, resolver.DocumentMetadata{}, errs
+		}
 	}
-	url := "https://" + path
-	resp, err := Client.Get(url)
+	if err != nil {
+		return nil, resolver.DocumentMetadata{}, err
+	}
+	if state.ID != did {
+		return nil, resolver.DocumentMetadata{}, fmt.Errorf("id does not match requested did")
+	}
+	if len(state.VerificationMethod) < 1 {
+		return nil, resolver.DocumentMetadata{}, fmt.Errorf("no verification methods")
+	}
+	var metadata resolver.DocumentMetadata
+	if updated, err := http.ParseTime(entry.lastModified); err == nil {
+		metadata.Updated = updated.UTC().Format(time.RFC3339)
+	}
+	return &state, metadata, nil
+}
+
+// fetch returns the document at url, from the cache while it is fresh.
+func (r *Resolver) fetch(url string) (*cacheEntry, error) {
+	cached, fresh := r.cache.get(url)
+	if fresh {
+		return cached, nil
+	}
+	resp, err := r.get(url, cached.validators())
 	if err != nil {
 		return nil, err
 	}
+	if resp.Body != nil {
+		defer resp.Body.Close()
+	}
+	if resp.StatusCode == http.StatusNotModified && cached != nil {
+		return r.cache.refresh(url, cached, resp.Header), nil
+	}
 	if resp.StatusCode != http.StatusOK {
+		r.cache.remove(url)
+		if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+			return nil, resolver.NewError(resolver.NotFound, "unable to resolve: '%s'", resp.Status)
+		}
 		return nil, fmt.Errorf("unable to resolve: '%s'", resp.Status)
 	}
 	if resp.Body == nil {
 		return nil, fmt.Errorf("empty did document")
 	}
-	defer resp.Body.Close()
-
-	var state resolver.Document
-	err = json.NewDecoder(resp.Body).Decode(&state)
+	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
 	}
-	if state.ID != did {
-		return nil, fmt.Errorf("id does not match requested did")
-	}
-	if len(state.VerificationMethod) < 1 {
-		return nil, fmt.Errorf("no verification methods")
-	}
-	return &state, nil
+	return r.cache.store(url, body, resp.Header), nil
 }
 
-var _ resolver.Resolver = (*Resolver)(nil)
+var _ resolver.MetadataResolver = (*Resolver)(nil)
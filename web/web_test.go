@@ -2,13 +2,17 @@ package web
 
 import (
 	"bytes"
+	"crypto/tls"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/ockam-network/did"
 	"github.com/textileio/go-did-resolver/resolver"
@@ -177,6 +181,9 @@ func TestFailOnStatus(t *testing.T) {
 	if err == nil || err.Error() != "unable to resolve: 'Not Found'" {
 		t.Error("expected Resolve to return error")
 	}
+	if e, ok := err.(*resolver.Error); !ok || e.Code != resolver.NotFound {
+		t.Errorf("expected notFound error: %v", err)
+	}
 }
 
 func TestFailOnBadResponse(t *testing.T) {
@@ -293,3 +300,166 @@ func TestFailNoVerificationMethods(t *testing.T) {
 		t.Error("expected Resolve to return error")
 	}
 }
+
+// docClient is a Getter that serves a single document.
+type docClient struct {
+	doc *resolver.Document
+}
+
+func (c docClient) Get(url string) (*http.Response, error) {
+	data, err := json.Marshal(c.doc)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{
+		StatusCode: 200,
+		Body:       ioutil.NopCloser(bytes.NewReader(data)),
+	}, nil
+}
+
+func testDocument(id, controller string) *resolver.Document {
+	return &resolver.Document{
+		Context:    []string{"https://w3id.org/did/v1"},
+		ID:         id,
+		Controller: []string{controller},
+		VerificationMethod: []resolver.VerificationMethod{
+			{
+				ID:                 fmt.Sprintf("%s#owner", id),
+				Type:               "Secp256k1VerificationKey2018",
+				Controller:         id,
+				PublicKeyMultibase: Key,
+			},
+		},
+	}
+}
+
+func TestClientPerResolver(t *testing.T) {
+	id := "did:web:example.com"
+	parsed, err := did.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	first := New(WithClient(docClient{testDocument(id, "first")}))
+	second := New(WithClient(docClient{testDocument(id, "second")}))
+	var wg sync.WaitGroup
+	for _, r := range []*Resolver{first, second, first, second} {
+		wg.Add(1)
+		go func(r *Resolver) {
+			defer wg.Done()
+			doc, err := r.Resolve(id, parsed, r)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			expected := "first"
+			if r == second {
+				expected = "second"
+			}
+			if doc.Controller[0] != expected {
+				t.Errorf("unexpected document from client: %s", doc.Controller[0])
+			}
+		}(r)
+	}
+	wg.Wait()
+}
+
+func TestMaxBodySize(t *testing.T) {
+	id := "did:web:example.com"
+	parsed, err := did.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := WithClient(docClient{testDocument(id, strings.Repeat("a", 1000))})
+	r := New(client, WithMaxBodySize(1000))
+	_, err = r.Resolve(id, parsed, r)
+	if err == nil || err.Error() != "response body exceeds 1000 bytes" {
+		t.Error("expected Resolve to return error")
+	}
+	r = New(client, WithMaxBodySize(2000))
+	if _, err := r.Resolve(id, parsed, r); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHTTPOptions(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			time.Sleep(100 * time.Millisecond)
+		}
+		w.Write([]byte(r.UserAgent()))
+	}))
+	defer server.Close()
+	tlsConfig := server.Client().Transport.(*http.Transport).TLSClientConfig
+
+	// Without the server's TLS configuration, its certificate is not trusted.
+	if _, err := New(WithTimeout(time.Second)).Get(server.URL); err == nil {
+		t.Error("expected Get to return error")
+	}
+
+	r := New(WithTLSConfig(tlsConfig), WithUserAgent("go-did-resolver/test"))
+	resp, err := r.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "go-did-resolver/test" {
+		t.Errorf("unexpected user agent: %s", body)
+	}
+
+	client := server.Client()
+	r = New(WithClient(client), WithTimeout(10*time.Millisecond))
+	if _, err := r.Get(server.URL + "/slow"); err == nil {
+		t.Error("expected Get to time out")
+	}
+	if client.Timeout != 0 {
+		t.Error("expected client not to be modified")
+	}
+}
+
+// headerTransport sets a header on requests, like an auth or tracing
+// RoundTripper.
+type headerTransport struct {
+	next http.RoundTripper
+}
+
+func (t headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer token")
+	return t.next.RoundTrip(req)
+}
+
+func TestCustomTransport(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("Authorization")))
+	}))
+	defer server.Close()
+	client := &http.Client{Transport: headerTransport{server.Client().Transport}}
+
+	r := New(WithClient(client), WithTimeout(time.Second))
+	resp, err := r.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != "Bearer token" {
+		t.Errorf("expected transport to be kept: %s", body)
+	}
+
+	for _, opt := range []Option{WithProxy(http.ProxyFromEnvironment), WithTLSConfig(&tls.Config{}), WithIPPolicy(PublicOnly)} {
+		r := New(WithClient(client), opt)
+		if r.Err() == nil {
+			t.Error("expected Err to return error")
+		}
+		if _, err := r.Get(server.URL); err == nil || err != r.Err() {
+			t.Errorf("expected Get to return configuration error: %v", err)
+		}
+	}
+}
@@ -0,0 +1,292 @@
+// Package webvh provides tools for resolving the did:webvh method format, a
+// did:web variant with a verifiable history of the did document:
+// https://identity.foundation/didwebvh/
+// Copyright 2021 Textile
+package webvh
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	mbase "github.com/multiformats/go-multibase"
+	mh "github.com/multiformats/go-multihash"
+	"github.com/textileio/go-did-resolver/jcs"
+	"github.com/textileio/go-did-resolver/resolver"
+)
+
+// MethodVersion is the did:webvh specification version supported.
+const MethodVersion = "did:webvh:1.0"
+
+// scidPlaceholder stands in for the SCID when it is calculated.
+const scidPlaceholder = "{SCID}"
+
+// Entry is a did:webvh log entry.
+type Entry struct {
+	VersionID   string                     `json:"versionId"`
+	VersionTime string                     `json:"versionTime"`
+	Parameters  map[string]json.RawMessage `json:"parameters"`
+	State       json.RawMessage            `json:"state"`
+	Proof       json.RawMessage            `json:"proof,omitempty"`
+}
+
+// Parameters are the active did:webvh parameters after a log entry. Each
+// entry only includes the parameters that it changes.
+type Parameters struct {
+	Method        string          `json:"method"`
+	SCID          string          `json:"scid"`
+	UpdateKeys    []string        `json:"updateKeys"`
+	NextKeyHashes []string        `json:"nextKeyHashes"`
+	Portable      bool            `json:"portable"`
+	Deactivated   bool            `json:"deactivated"`
+	TTL           int             `json:"ttl"`
+	Witness       json.RawMessage `json:"witness"`
+	Watchers      []string        `json:"watchers"`
+}
+
+// version is a verified log entry.
+type version struct {
+	number     int
+	id         string
+	time       time.Time
+	parameters Parameters
+	doc        *resolver.Document
+}
+
+// hash returns the base58btc encoded sha2-256 multihash of the canonical
+// json value, without a multibase prefix.
+func hash(data []byte) (string, error) {
+	canonical, err := jcs.Canonicalize(data)
+	if err != nil {
+		return "", err
+	}
+	digest, err := mh.Sum(canonical, mh.SHA2_256, -1)
+	if err != nil {
+		return "", err
+	}
+	encoded, err := mbase.Encode(mbase.Base58BTC, digest)
+	if err != nil {
+		return "", err
+	}
+	return encoded[1:], nil
+}
+
+// withoutProof returns the json encoding of the entry with versionId replaced
+// and the proof removed.
+func (e Entry) withoutProof(versionID string) ([]byte, error) {
+	e.VersionID = versionID
+	e.Proof = nil
+	return json.Marshal(e)
+}
+
+// verifySCID checks that the SCID is the hash of the first entry, with the
+// SCID replaced by a placeholder.
+func verifySCID(scid string, first Entry) error {
+	data, err := first.withoutProof(scidPlaceholder)
+	if err != nil {
+		return err
+	}
+	data = bytes.ReplaceAll(data, []byte(scid), []byte(scidPlaceholder))
+	expected, err := hash(data)
+	if err != nil {
+		return err
+	}
+	if expected != scid {
+		return fmt.Errorf("scid does not match first log entry")
+	}
+	return nil
+}
+
+// nextKeyHash returns the pre-rotation commitment to an update key.
+func nextKeyHash(key string) (string, error) {
+	digest, err := mh.Sum([]byte(key), mh.SHA2_256, -1)
+	if err != nil {
+		return "", err
+	}
+	encoded, err := mbase.Encode(mbase.Base58BTC, digest)
+	if err != nil {
+		return "", err
+	}
+	return encoded[1:], nil
+}
+
+// parseLog decodes a did.jsonl log, one entry per line.
+func parseLog(data []byte) ([]Entry, error) {
+	var entries []Entry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, len(data)+1)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return nil, fmt.Errorf("invalid log entry %d: %v", len(entries)+1, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, resolver.NewError(resolver.NotFound, "empty did log")
+	}
+	return entries, nil
+}
+
+// verifyLog checks the SCID, hash chain and proofs of the log of a did, and
+// returns the verified versions.
+func verifyLog(did, scid string, entries []Entry, now time.Time) ([]version, error) {
+	if err := verifySCID(scid, entries[0]); err != nil {
+		return nil, err
+	}
+	var versions []version
+	active := make(map[string]json.RawMessage)
+	prevID := scid
+	var prev *version
+	for i, entry := range entries {
+		number := i + 1
+		// The version id is the version number and the hash of the entry,
+		// chained to the previous version id.
+		data, err := entry.withoutProof(prevID)
+		if err != nil {
+			return nil, err
+		}
+		entryHash, err := hash(data)
+		if err != nil {
+			return nil, err
+		}
+		if entry.VersionID != strconv.Itoa(number)+"-"+entryHash {
+			return nil, fmt.Errorf("invalid version id: '%s'", entry.VersionID)
+		}
+		versionTime, err := time.Parse(time.RFC3339, entry.VersionTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid version time: '%s'", entry.VersionTime)
+		}
+		if versionTime.After(now) || (prev != nil && versionTime.Before(prev.time)) {
+			return nil, fmt.Errorf("version time out of order: '%s'", entry.VersionTime)
+		}
+
+		for name, value := range entry.Parameters {
+			active[name] = value
+		}
+		parameters, err := decodeParameters(active)
+		if err != nil {
+			return nil, err
+		}
+		if number == 1 {
+			if parameters.Method != MethodVersion {
+				return nil, fmt.Errorf("unsupported method version: '%s'", parameters.Method)
+			}
+			if parameters.SCID != scid {
+				return nil, fmt.Errorf("scid parameter does not match did")
+			}
+		} else if _, ok := entry.Parameters["scid"]; ok {
+			return nil, fmt.Errorf("scid parameter may only be set in the first log entry")
+		}
+		if prev != nil && prev.parameters.Deactivated {
+			return nil, fmt.Errorf("log entry after deactivation: '%s'", entry.VersionID)
+		}
+		if len(parameters.Witness) > 0 && string(parameters.Witness) != "null" && string(parameters.Witness) != "{}" {
+			return nil, fmt.Errorf("witnessed dids are not supported")
+		}
+
+		authorized, err := authorizedKeys(prev, parameters, number)
+		if err != nil {
+			return nil, err
+		}
+		if err := verifyEntryProofs(entry, authorized); err != nil {
+			return nil, err
+		}
+
+		var doc resolver.Document
+		if err := json.Unmarshal(entry.State, &doc); err != nil {
+			return nil, fmt.Errorf("invalid did document: %v", err)
+		}
+		if doc.ID != did {
+			return nil, fmt.Errorf("id does not match requested did")
+		}
+		versions = append(versions, version{
+			number:     number,
+			id:         entry.VersionID,
+			time:       versionTime,
+			parameters: *parameters,
+			doc:        &doc,
+		})
+		prev = &versions[len(versions)-1]
+		prevID = entry.VersionID
+	}
+	return versions, nil
+}
+
+// decodeParameters decodes the active parameters.
+func decodeParameters(active map[string]json.RawMessage) (*Parameters, error) {
+	data, err := json.Marshal(active)
+	if err != nil {
+		return nil, err
+	}
+	var parameters Parameters
+	if err := json.Unmarshal(data, &parameters); err != nil {
+		return nil, fmt.Errorf("invalid parameters: %v", err)
+	}
+	return &parameters, nil
+}
+
+// authorizedKeys returns the update keys that may sign a log entry. The first
+// entry is signed by its own update keys, and later entries by those of the
+// previous entry. With pre-rotation, an entry is signed by its own update
+// keys, which must have been committed to by the previous entry.
+func authorizedKeys(prev *version, parameters *Parameters, number int) ([]string, error) {
+	if prev == nil {
+		return parameters.UpdateKeys, nil
+	}
+	if len(prev.parameters.NextKeyHashes) == 0 {
+		return prev.parameters.UpdateKeys, nil
+	}
+	for _, key := range parameters.UpdateKeys {
+		h, err := nextKeyHash(key)
+		if err != nil {
+			return nil, err
+		}
+		if !contains(prev.parameters.NextKeyHashes, h) {
+			return nil, fmt.Errorf("update key was not pre-rotated in version %d: '%s'", number-1, key)
+		}
+	}
+	return parameters.UpdateKeys, nil
+}
+
+// verifyEntryProofs checks that the entry has at least one proof, and that
+// all of its proofs are valid and by an authorized update key.
+func verifyEntryProofs(entry Entry, authorized []string) error {
+	list, err := proofs(entry.Proof)
+	if err != nil {
+		return err
+	}
+	unsecured, err := entry.withoutProof(entry.VersionID)
+	if err != nil {
+		return err
+	}
+	for _, proof := range list {
+		key, err := verifyProof(proof, unsecured)
+		if err != nil {
+			return err
+		}
+		if !contains(authorized, key) {
+			return fmt.Errorf("proof is not by an authorized update key: '%s'", key)
+		}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,121 @@
+// Package webvh provides tools for resolving the did:webvh method format, a
+// did:web variant with a verifiable history of the did document:
+// https://identity.foundation/didwebvh/
+// Copyright 2021 Textile
+package webvh
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	mbase "github.com/multiformats/go-multibase"
+	codec "github.com/multiformats/go-multicodec"
+	"github.com/textileio/go-did-resolver/jcs"
+	"github.com/textileio/go-did-resolver/multikey"
+)
+
+// Proof is a Data Integrity proof, as used to sign log entries.
+// See https://www.w3.org/TR/vc-di-eddsa/#eddsa-jcs-2022
+type Proof struct {
+	Type               string `json:"type"`
+	Cryptosuite        string `json:"cryptosuite"`
+	VerificationMethod string `json:"verificationMethod"`
+	Created            string `json:"created,omitempty"`
+	ProofPurpose       string `json:"proofPurpose"`
+	ProofValue         string `json:"proofValue"`
+}
+
+// proofs decodes the proof member of a log entry, which may be a single proof
+// or a non-empty list of proofs.
+func proofs(data json.RawMessage) ([]json.RawMessage, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return nil, fmt.Errorf("log entry has no proof")
+	}
+	var list []json.RawMessage
+	if err := json.Unmarshal(data, &list); err != nil {
+		return []json.RawMessage{data}, nil
+	}
+	if len(list) == 0 {
+		return nil, fmt.Errorf("log entry has no proof")
+	}
+	return list, nil
+}
+
+// proofKey returns the multibase encoded key of a proof's did:key
+// verification method.
+func proofKey(vm string) (string, error) {
+	parts := strings.SplitN(vm, "#", 2)
+	if !strings.HasPrefix(parts[0], "did:key:") || len(parts) != 2 || parts[1] != strings.TrimPrefix(parts[0], "did:key:") {
+		return "", fmt.Errorf("unsupported proof verification method: '%s'", vm)
+	}
+	return parts[1], nil
+}
+
+// verifyProof checks an eddsa-jcs-2022 proof over the unsecured document, and
+// returns the multibase encoded key that made it.
+func verifyProof(data json.RawMessage, unsecured []byte) (string, error) {
+	var proof Proof
+	if err := json.Unmarshal(data, &proof); err != nil {
+		return "", fmt.Errorf("invalid proof: %v", err)
+	}
+	if proof.Type != "DataIntegrityProof" || proof.Cryptosuite != "eddsa-jcs-2022" {
+		return "", fmt.Errorf("unsupported proof type: '%s' '%s'", proof.Type, proof.Cryptosuite)
+	}
+	if proof.ProofPurpose != "assertionMethod" && proof.ProofPurpose != "authentication" {
+		return "", fmt.Errorf("unsupported proof purpose: '%s'", proof.ProofPurpose)
+	}
+	encoded, err := proofKey(proof.VerificationMethod)
+	if err != nil {
+		return "", err
+	}
+	key, err := multikey.Decode(encoded)
+	if err != nil {
+		return "", err
+	}
+	if key.Code != codec.Ed25519Pub {
+		return "", fmt.Errorf("unsupported proof key type: '%s'", key.Name())
+	}
+	_, sig, err := mbase.Decode(proof.ProofValue)
+	if err != nil {
+		return "", fmt.Errorf("invalid proof value: %v", err)
+	}
+	// The proof configuration is the proof without its value.
+	var config map[string]interface{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return "", err
+	}
+	delete(config, "proofValue")
+	configData, err := json.Marshal(config)
+	if err != nil {
+		return "", err
+	}
+	hashData, err := proofHashData(configData, unsecured)
+	if err != nil {
+		return "", err
+	}
+	if err := key.Verify(hashData, sig); err != nil {
+		return "", err
+	}
+	return encoded, nil
+}
+
+// proofHashData returns the data signed by an eddsa-jcs-2022 proof: the
+// hash of the canonical proof configuration followed by the hash of the
+// canonical document.
+func proofHashData(config, unsecured []byte) ([]byte, error) {
+	canonicalConfig, err := jcs.Canonicalize(config)
+	if err != nil {
+		return nil, err
+	}
+	canonicalDoc, err := jcs.Canonicalize(unsecured)
+	if err != nil {
+		return nil, err
+	}
+	configHash := sha256.Sum256(canonicalConfig)
+	docHash := sha256.Sum256(canonicalDoc)
+	return append(configHash[:], docHash[:]...), nil
+}
@@ -0,0 +1,245 @@
+// Package webvh provides tools for resolving the did:webvh method format, a
+// did:web variant with a verifiable history of the did document:
+// https://identity.foundation/didwebvh/
+// Copyright 2021 Textile
+package webvh
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ockam-network/did"
+	"github.com/textileio/go-did-resolver/resolver"
+	"github.com/textileio/go-did-resolver/web"
+)
+
+// LogPath is the default path at which to look for the did.jsonl log.
+const LogPath = "/.well-known/did.jsonl"
+
+// Resolver resolves did:webvh identifiers from their verified did.jsonl log.
+// Logs are fetched with a web.Resolver. Witnessed dids are not supported.
+type Resolver struct {
+	web *web.Resolver
+}
+
+// New creates and returns a new webvh Resolver, whose logs and parallel did:web
+// documents are fetched with a web.Resolver configured by opts.
+func New(opts ...web.Option) *Resolver {
+	return &Resolver{web: web.New(opts...)}
+}
+
+// Method returns the method that this resolver is capable of resolving.
+func (r *Resolver) Method() string {
+	return "webvh"
+}
+
+// Resolve is the primary resolution method for this resolver.
+func (r *Resolver) Resolve(did string, parsed *did.DID, res resolver.Resolver) (*resolver.Document, error) {
+	doc, _, err := r.ResolveWithMetadata(did, parsed, res, nil)
+	return doc, err
+}
+
+// ResolveWithMetadata resolves the did from its verified log. The versionId,
+// versionTime and versionNumber query parameters select an earlier version.
+func (r *Resolver) ResolveWithMetadata(did string, parsed *did.DID, res resolver.Resolver, options *resolver.ResolutionOptions) (*resolver.Document, resolver.DocumentMetadata, error) {
+	if parsed.Method != r.Method() {
+		return nil, resolver.DocumentMetadata{}, fmt.Errorf("unknown did method: '%s'", parsed.Method)
+	}
+	id := fmt.Sprintf("did:webvh:%s", parsed.ID)
+	scid, location, err := logURL(parsed.IDStrings)
+	if err != nil {
+		return nil, resolver.DocumentMetadata{}, err
+	}
+	query, err := url.ParseQuery(parsed.Query)
+	if err != nil {
+		return nil, resolver.DocumentMetadata{}, err
+	}
+	data, err := fetch(r.web, location)
+	if err != nil {
+		return nil, resolver.DocumentMetadata{}, err
+	}
+	entries, err := parseLog(data)
+	if err != nil {
+		return nil, resolver.DocumentMetadata{}, err
+	}
+	versions, err := verifyLog(id, scid, entries, time.Now())
+	if err != nil {
+		return nil, resolver.DocumentMetadata{}, err
+	}
+	v, err := selectVersion(versions, query)
+	if err != nil {
+		return nil, resolver.DocumentMetadata{}, err
+	}
+	metadata := resolver.DocumentMetadata{
+		Created:     versions[0].time.UTC().Format(time.RFC3339),
+		Updated:     v.time.UTC().Format(time.RFC3339),
+		VersionID:   v.id,
+		Deactivated: v.parameters.Deactivated,
+	}
+	return v.doc, metadata, nil
+}
+
+// ResolveWeb resolves the parallel did:web of a did:webvh, through the
+// web.Resolver. The did:web document must be the current did:webvh document
+// with its ids rewritten, and must list the did:webvh in alsoKnownAs.
+func (r *Resolver) ResolveWeb(id string) (*resolver.Document, error) {
+	parsed, err := resolver.Parse(id)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Method != r.Method() || len(parsed.IDStrings) < 2 {
+		return nil, fmt.Errorf("invalid did:webvh: '%s'", id)
+	}
+	current, err := r.Resolve(id, parsed, r)
+	if err != nil {
+		return nil, err
+	}
+	webID := "did:web:" + strings.Join(parsed.IDStrings[1:], ":")
+	webParsed, err := resolver.Parse(webID)
+	if err != nil {
+		return nil, err
+	}
+	doc, err := r.web.Resolve(webID, webParsed, r.web)
+	if err != nil {
+		return nil, err
+	}
+	if !contains(doc.AlsoKnownAs, current.ID) {
+		return nil, fmt.Errorf("did:web document is not also known as: '%s'", current.ID)
+	}
+	// Rewrite the did:webvh document, and compare it without alsoKnownAs.
+	expected := webDocument(current, webID)
+	observed := *doc
+	expected.AlsoKnownAs, observed.AlsoKnownAs = nil, nil
+	if !reflect.DeepEqual(expected, observed) {
+		return nil, fmt.Errorf("did:web document does not match did:webvh document")
+	}
+	return doc, nil
+}
+
+// webDocument returns a copy of doc with the did:webvh replaced by webID in
+// its id, controllers, and the ids of its verification methods, relationships
+// and services. Other values, such as service endpoints, are kept as is.
+func webDocument(doc *resolver.Document, webID string) resolver.Document {
+	rewrite := func(id string) string {
+		if id == doc.ID {
+			return webID
+		}
+		for _, sep := range []string{"#", "?", "/"} {
+			if strings.HasPrefix(id, doc.ID+sep) {
+				return webID + id[len(doc.ID):]
+			}
+		}
+		return id
+	}
+	methods := func(vms []resolver.VerificationMethod) []resolver.VerificationMethod {
+		if vms == nil {
+			return nil
+		}
+		rewritten := make([]resolver.VerificationMethod, len(vms))
+		for i, vm := range vms {
+			vm.ID = rewrite(vm.ID)
+			vm.Controller = rewrite(vm.Controller)
+			rewritten[i] = vm
+		}
+		return rewritten
+	}
+	result := *doc
+	result.ID = webID
+	if doc.Controller != nil {
+		result.Controller = make([]string, len(doc.Controller))
+		for i, controller := range doc.Controller {
+			result.Controller[i] = rewrite(controller)
+		}
+	}
+	result.VerificationMethod = methods(doc.VerificationMethod)
+	result.Authentication = methods(doc.Authentication)
+	result.AssertionMethod = methods(doc.AssertionMethod)
+	result.CapabilityInvocation = methods(doc.CapabilityInvocation)
+	result.CapabilityDelegation = methods(doc.CapabilityDelegation)
+	result.KeyAgreement = methods(doc.KeyAgreement)
+	if doc.Service != nil {
+		result.Service = make([]resolver.ServiceEndpoint, len(doc.Service))
+		for i, service := range doc.Service {
+			service.ID = rewrite(service.ID)
+			result.Service[i] = service
+		}
+	}
+	return result
+}
+
+// logURL returns the SCID and the url of the log for a did:webvh. The first
+// id string is the SCID, followed by the percent encoded host and the path.
+func logURL(ids []string) (string, string, error) {
+	if len(ids) < 2 || ids[0] == "" {
+		return "", "", fmt.Errorf("invalid did:webvh: missing scid or domain")
+	}
+	location, err := web.URL(ids[1:], "did.jsonl")
+	if err != nil {
+		return "", "", err
+	}
+	return ids[0], location, nil
+}
+
+// fetch gets the log at location.
+func fetch(client *web.Resolver, location string) ([]byte, error) {
+	resp, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Body == nil {
+		return nil, fmt.Errorf("empty did log")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, resolver.NewError(resolver.NotFound, "did log not found: '%s'", location)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to resolve: '%s'", resp.Status)
+	}
+	return ioutil.ReadAll(resp.Body)
+}
+
+// selectVersion returns the version selected by the query, or the latest.
+func selectVersion(versions []version, query url.Values) (*version, error) {
+	if versionID := query.Get("versionId"); versionID != "" {
+		for i := range versions {
+			if versions[i].id == versionID {
+				return &versions[i], nil
+			}
+		}
+		return nil, resolver.NewError(resolver.NotFound, "unknown versionId: '%s'", versionID)
+	}
+	if number := query.Get("versionNumber"); number != "" {
+		n, err := strconv.Atoi(number)
+		if err != nil || n < 1 || n > len(versions) {
+			return nil, resolver.NewError(resolver.NotFound, "unknown versionNumber: '%s'", number)
+		}
+		return &versions[n-1], nil
+	}
+	if versionTime := query.Get("versionTime"); versionTime != "" {
+		t, err := time.Parse(time.RFC3339, versionTime)
+		if err != nil {
+			return nil, fmt.Errorf("invalid versionTime: '%s'", versionTime)
+		}
+		// The version in effect at the time is the last one before it.
+		var selected *version
+		for i := range versions {
+			if !versions[i].time.After(t) {
+				selected = &versions[i]
+			}
+		}
+		if selected == nil {
+			return nil, resolver.NewError(resolver.NotFound, "no version at time: '%s'", versionTime)
+		}
+		return selected, nil
+	}
+	return &versions[len(versions)-1], nil
+}
+
+var _ resolver.MetadataResolver = (*Resolver)(nil)
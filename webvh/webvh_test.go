@@ -0,0 +1,461 @@
+// Package webvh provides tools for resolving the did:webvh method format, a
+// did:web variant with a verifiable history of the did document:
+// https://identity.foundation/didwebvh/
+// Copyright 2021 Textile
+package webvh
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	mbase "github.com/multiformats/go-multibase"
+	codec "github.com/multiformats/go-multicodec"
+	"github.com/textileio/go-did-resolver/multikey"
+	"github.com/textileio/go-did-resolver/resolver"
+	"github.com/textileio/go-did-resolver/web"
+)
+
+// MockClient serves files by url.
+type MockClient struct {
+	files map[string]string
+}
+
+// Get is the mock client's `Get` func
+func (m *MockClient) Get(url string) (*http.Response, error) {
+	body, ok := m.files[url]
+	if !ok {
+		return &http.Response{StatusCode: http.StatusNotFound, Status: "404 Not Found"}, nil
+	}
+	return &http.Response{
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+	}, nil
+}
+
+// signer is an ed25519 update key.
+type signer struct {
+	private ed25519.PrivateKey
+	key     string
+}
+
+func newSigner(t *testing.T) *signer {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := (&multikey.Key{Code: codec.Ed25519Pub, Bytes: public}).Encode()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &signer{private, key}
+}
+
+// sign adds an eddsa-jcs-2022 proof to the entry.
+func (s *signer) sign(t *testing.T, entry *Entry) {
+	config, err := json.Marshal(map[string]string{
+		"type":               "DataIntegrityProof",
+		"cryptosuite":        "eddsa-jcs-2022",
+		"verificationMethod": "did:key:" + s.key + "#" + s.key,
+		"created":            entry.VersionTime,
+		"proofPurpose":       "assertionMethod",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unsecured, err := entry.withoutProof(entry.VersionID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := proofHashData(config, unsecured)
+	if err != nil {
+		t.Fatal(err)
+	}
+	value, err := mbase.Encode(mbase.Base58BTC, ed25519.Sign(s.private, data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var proof map[string]string
+	json.Unmarshal(config, &proof)
+	proof["proofValue"] = value
+	entry.Proof, _ = json.Marshal([]interface{}{proof})
+}
+
+// log builds a did:webvh log for example.com.
+type log struct {
+	did     string
+	scid    string
+	entries []Entry
+}
+
+func rawParameters(parameters map[string]interface{}) map[string]json.RawMessage {
+	raw := make(map[string]json.RawMessage)
+	for name, value := range parameters {
+		raw[name], _ = json.Marshal(value)
+	}
+	return raw
+}
+
+// state creates a did document with a single verification method.
+func state(did string, key *signer, endpoint string) json.RawMessage {
+	data, _ := json.Marshal(resolver.Document{
+		Context: []string{"https://www.w3.org/ns/did/v1"},
+		ID:      did,
+		VerificationMethod: []resolver.VerificationMethod{
+			{
+				ID:                 did + "#key-1",
+				Type:               "Multikey",
+				Controller:         did,
+				PublicKeyMultibase: key.key,
+			},
+		},
+		Service: []resolver.ServiceEndpoint{
+			{ID: did + "#files", Type: "LinkedDomains", ServiceEndpoint: endpoint},
+		},
+	})
+	return data
+}
+
+// create starts a log with the first entry, signed by key.
+func create(t *testing.T, key *signer, parameters map[string]interface{}) *log {
+	parameters["method"] = MethodVersion
+	parameters["scid"] = scidPlaceholder
+	did := "did:webvh:" + scidPlaceholder + ":example.com"
+	entry := Entry{
+		VersionID:   scidPlaceholder,
+		VersionTime: "2021-06-01T00:00:00Z",
+		Parameters:  rawParameters(parameters),
+		State:       state(did, key, "https://example.com/a"),
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		t.Fatal(err)
+	}
+	scid, err := hash(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data = bytes.ReplaceAll(data, []byte(scidPlaceholder), []byte(scid))
+	if err := json.Unmarshal(data, &entry); err != nil {
+		t.Fatal(err)
+	}
+	l := &log{did: "did:webvh:" + scid + ":example.com", scid: scid}
+	l.add(t, key, entry)
+	return l
+}
+
+// update appends an entry with the given parameters and service endpoint.
+func (l *log) update(t *testing.T, key *signer, parameters map[string]interface{}, endpoint string) {
+	l.add(t, key, Entry{
+		VersionTime: "2021-06-0" + string(rune('1'+len(l.entries))) + "T00:00:00Z",
+		Parameters:  rawParameters(parameters),
+		State:       state(l.did, key, endpoint),
+	})
+}
+
+// add chains the entry to the log, and signs it.
+func (l *log) add(t *testing.T, key *signer, entry Entry) {
+	prevID := l.scid
+	if len(l.entries) > 0 {
+		prevID = l.entries[len(l.entries)-1].VersionID
+	}
+	data, err := entry.withoutProof(prevID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := hash(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry.VersionID = string(rune('1'+len(l.entries))) + "-" + h
+	key.sign(t, &entry)
+	l.entries = append(l.entries, entry)
+}
+
+// fork returns a copy of the log with its first n entries.
+func (l *log) fork(n int) *log {
+	return &log{did: l.did, scid: l.scid, entries: append([]Entry{}, l.entries[:n]...)}
+}
+
+func (l *log) jsonl() string {
+	var lines []string
+	for _, entry := range l.entries {
+		data, _ := json.Marshal(entry)
+		lines = append(lines, string(data))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func resolve(t *testing.T, l *log, id string) (*resolver.Document, resolver.DocumentMetadata, error) {
+	client := &MockClient{files: map[string]string{
+		"https://example.com" + LogPath: l.jsonl(),
+	}}
+	parsed, err := resolver.Parse(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := New(web.WithClient(client))
+	return r.ResolveWithMetadata(id, parsed, r, nil)
+}
+
+func TestUnknownMethod(t *testing.T) {
+	_, _, err := resolve(t, &log{}, "did:borg:example.com")
+	if err == nil || err.Error() != "unknown did method: 'borg'" {
+		t.Error("expected Resolve to return error")
+	}
+}
+
+func TestLogURL(t *testing.T) {
+	tests := []struct {
+		ids      []string
+		location string
+	}{
+		{[]string{"QmScid", "example.com"}, "https://example.com/.well-known/did.jsonl"},
+		{[]string{"QmScid", "example.com%3A3000"}, "https://example.com:3000/.well-known/did.jsonl"},
+		{[]string{"QmScid", "example.com", "dids", "alice"}, "https://example.com/dids/alice/did.jsonl"},
+	}
+	for _, tt := range tests {
+		scid, location, err := logURL(tt.ids)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if scid != "QmScid" || location != tt.location {
+			t.Errorf("unexpected log url: %s", location)
+		}
+	}
+	if _, _, err := logURL([]string{"example.com"}); err == nil {
+		t.Error("expected logURL to return error")
+	}
+}
+
+func TestNotFound(t *testing.T) {
+	l := &log{}
+	_, _, err := resolve(t, l, "did:webvh:QmScid:example.com")
+	var rerr *resolver.Error
+	if !errors.As(err, &rerr) || rerr.Code != resolver.NotFound {
+		t.Errorf("expected notFound error: %v", err)
+	}
+}
+
+func TestResolve(t *testing.T) {
+	key := newSigner(t)
+	l := create(t, key, map[string]interface{}{"updateKeys": []string{key.key}})
+	l.update(t, key, map[string]interface{}{}, "https://example.com/b")
+
+	doc, metadata, err := resolve(t, l, l.did)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var expected resolver.Document
+	json.Unmarshal(state(l.did, key, "https://example.com/b"), &expected)
+	if !reflect.DeepEqual(*doc, expected) {
+		t.Errorf("unexpected document: %+v", doc)
+	}
+	if metadata.Created != "2021-06-01T00:00:00Z" || metadata.Updated != "2021-06-02T00:00:00Z" || metadata.VersionID != l.entries[1].VersionID {
+		t.Errorf("unexpected metadata: %+v", metadata)
+	}
+
+	for _, query := range []string{
+		"?versionId=" + l.entries[0].VersionID,
+		"?versionNumber=1",
+		"?versionTime=2021-06-01T12:00:00Z",
+	} {
+		doc, metadata, err := resolve(t, l, l.did+query)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if doc.Service[0].ServiceEndpoint != "https://example.com/a" || metadata.VersionID != l.entries[0].VersionID {
+			t.Errorf("%s: unexpected version: %+v", query, metadata)
+		}
+	}
+	for _, query := range []string{"?versionNumber=3", "?versionTime=2021-05-01T00:00:00Z"} {
+		_, _, err := resolve(t, l, l.did+query)
+		var rerr *resolver.Error
+		if !errors.As(err, &rerr) || rerr.Code != resolver.NotFound {
+			t.Errorf("%s: expected notFound error: %v", query, err)
+		}
+	}
+}
+
+func TestTamperedLog(t *testing.T) {
+	key, other := newSigner(t), newSigner(t)
+	l := create(t, key, map[string]interface{}{"updateKeys": []string{key.key}})
+	l.update(t, key, map[string]interface{}{}, "https://example.com/b")
+
+	// A changed entry no longer matches its version id.
+	tampered := l.fork(len(l.entries))
+	tampered.entries[1].State = state(l.did, key, "https://mallory.example.com")
+	_, _, err := resolve(t, tampered, l.did)
+	if err == nil || !strings.HasPrefix(err.Error(), "invalid version id") {
+		t.Errorf("expected Resolve to return error, got: %v", err)
+	}
+
+	// A rehashed entry no longer matches its proof.
+	tampered = l.fork(1)
+	tampered.update(t, key, map[string]interface{}{}, "https://mallory.example.com")
+	tampered.entries[1].Proof = l.entries[1].Proof
+	_, _, err = resolve(t, tampered, l.did)
+	if err == nil {
+		t.Error("expected Resolve to return error")
+	}
+
+	// An entry signed by a key that is not an update key.
+	tampered = l.fork(1)
+	tampered.update(t, other, map[string]interface{}{}, "https://mallory.example.com")
+	_, _, err = resolve(t, tampered, l.did)
+	if err == nil || !strings.HasPrefix(err.Error(), "proof is not by an authorized update key") {
+		t.Errorf("expected Resolve to return error, got: %v", err)
+	}
+
+	// An entry that takes over the update keys, without a proof.
+	for _, proof := range []string{`[]`, `null`, ` [ ] `} {
+		tampered = l.fork(1)
+		tampered.update(t, other, map[string]interface{}{"updateKeys": []string{other.key}}, "https://mallory.example.com")
+		tampered.entries[1].Proof = json.RawMessage(proof)
+		_, _, err = resolve(t, tampered, l.did)
+		if err == nil || err.Error() != "log entry has no proof" {
+			t.Errorf("expected Resolve to return error for proof %s, got: %v", proof, err)
+		}
+	}
+	tampered = l.fork(1)
+	tampered.entries[0].Proof = json.RawMessage(`[]`)
+	_, _, err = resolve(t, tampered, l.did)
+	if err == nil || err.Error() != "log entry has no proof" {
+		t.Errorf("expected Resolve to return error, got: %v", err)
+	}
+
+	// The log of another did.
+	_, _, err = resolve(t, create(t, other, map[string]interface{}{"updateKeys": []string{other.key}}), l.did)
+	if err == nil || err.Error() != "scid does not match first log entry" {
+		t.Errorf("expected Resolve to return error, got: %v", err)
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	key, next := newSigner(t), newSigner(t)
+	l := create(t, key, map[string]interface{}{"updateKeys": []string{key.key}})
+	// The rotation is signed by the previous update key.
+	l.update(t, key, map[string]interface{}{"updateKeys": []string{next.key}}, "https://example.com/b")
+	l.update(t, next, map[string]interface{}{}, "https://example.com/c")
+	if _, _, err := resolve(t, l, l.did); err != nil {
+		t.Fatal(err)
+	}
+
+	l.update(t, key, map[string]interface{}{}, "https://example.com/d")
+	_, _, err := resolve(t, l, l.did)
+	if err == nil || !strings.HasPrefix(err.Error(), "proof is not by an authorized update key") {
+		t.Errorf("expected Resolve to return error, got: %v", err)
+	}
+}
+
+func TestPreRotation(t *testing.T) {
+	key, next, other := newSigner(t), newSigner(t), newSigner(t)
+	nextHash, err := nextKeyHash(next.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := create(t, key, map[string]interface{}{
+		"updateKeys":    []string{key.key},
+		"nextKeyHashes": []string{nextHash},
+	})
+	rotated := l.fork(1)
+	rotated.update(t, next, map[string]interface{}{
+		"updateKeys":    []string{next.key},
+		"nextKeyHashes": []string{},
+	}, "https://example.com/b")
+	if _, _, err := resolve(t, rotated, l.did); err != nil {
+		t.Fatal(err)
+	}
+
+	// The new update key must have been committed to.
+	rotated = l.fork(1)
+	rotated.update(t, other, map[string]interface{}{"updateKeys": []string{other.key}}, "https://example.com/b")
+	_, _, err = resolve(t, rotated, l.did)
+	if err == nil || !strings.HasPrefix(err.Error(), "update key was not pre-rotated") {
+		t.Errorf("expected Resolve to return error, got: %v", err)
+	}
+}
+
+func TestDeactivated(t *testing.T) {
+	key := newSigner(t)
+	l := create(t, key, map[string]interface{}{"updateKeys": []string{key.key}})
+	l.update(t, key, map[string]interface{}{"deactivated": true}, "https://example.com/b")
+	_, metadata, err := resolve(t, l, l.did)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !metadata.Deactivated {
+		t.Errorf("expected deactivated metadata: %+v", metadata)
+	}
+
+	l.update(t, key, map[string]interface{}{"deactivated": false}, "https://example.com/c")
+	_, _, err = resolve(t, l, l.did)
+	if err == nil || !strings.HasPrefix(err.Error(), "log entry after deactivation") {
+		t.Errorf("expected Resolve to return error, got: %v", err)
+	}
+}
+
+func TestFutureEntry(t *testing.T) {
+	key := newSigner(t)
+	l := create(t, key, map[string]interface{}{"updateKeys": []string{key.key}})
+	if _, err := verifyLog(l.did, l.scid, l.entries, time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)); err == nil {
+		t.Error("expected verifyLog to return error")
+	}
+}
+
+func TestResolveWeb(t *testing.T) {
+	key := newSigner(t)
+	l := create(t, key, map[string]interface{}{"updateKeys": []string{key.key}})
+	webDID := "did:web:example.com"
+	webDoc := func(endpoint string) string {
+		var doc resolver.Document
+		json.Unmarshal(state(webDID, key, endpoint), &doc)
+		doc.AlsoKnownAs = []string{l.did}
+		data, _ := json.Marshal(doc)
+		return string(data)
+	}
+
+	files := map[string]string{
+		"https://example.com" + LogPath:     l.jsonl(),
+		"https://example.com" + web.DocPath: webDoc("https://example.com/a"),
+	}
+	r := New(web.WithClient(&MockClient{files: files}))
+	doc, err := r.ResolveWeb(l.did)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.ID != webDID {
+		t.Errorf("unexpected document: %+v", doc)
+	}
+
+	// The did:web document must match the current did:webvh document.
+	files["https://example.com"+web.DocPath] = webDoc("https://mallory.example.com")
+	if _, err := r.ResolveWeb(l.did); err == nil {
+		t.Error("expected ResolveWeb to return error")
+	}
+
+	// Only ids are rewritten, not values that mention the did:webvh.
+	endpoint := "https://resolver.example.com/1.0/identifiers/" + l.did
+	l.update(t, key, map[string]interface{}{}, endpoint)
+	files["https://example.com"+LogPath] = l.jsonl()
+	files["https://example.com"+web.DocPath] = webDoc(endpoint)
+	doc, err = r.ResolveWeb(l.did)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Service[0].ServiceEndpoint != endpoint {
+		t.Errorf("unexpected service endpoint: %s", doc.Service[0].ServiceEndpoint)
+	}
+	files["https://example.com"+web.DocPath] = webDoc(strings.Replace(endpoint, "did:webvh:"+l.scid+":", "did:web:", 1))
+	if _, err := r.ResolveWeb(l.did); err == nil {
+		t.Error("expected ResolveWeb to return error")
+	}
+}